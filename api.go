@@ -0,0 +1,354 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sberserker/dcagdax/exchanges"
+)
+
+// confirmTTL is how long a forced run confirmation token stays valid.
+const confirmTTL = 5 * time.Minute
+
+// controlAPI lets a dashboard inspect and control the daemon. Every request
+// needs the bearer token read from --api-token-file. With several profiles
+// requests select one with the profile parameter.
+type controlAPI struct {
+	schedules []*gdaxSchedule
+	token     string
+
+	mu      sync.Mutex
+	confirm map[string]pendingConfirmation // pending forced run confirmations by token
+}
+
+// pendingConfirmation is a forced run of profile waiting for its token.
+type pendingConfirmation struct {
+	profile string
+	expires time.Time
+}
+
+type apiConfig struct {
+	Currency  string             `json:"currency"`
+	Amount    float64            `json:"amount"`
+	Coins     map[string]float64 `json:"coins"`
+	Cadence   string             `json:"cadence"`
+	OrderType string             `json:"order_type"`
+	Trade     bool               `json:"trade"`
+	AutoFund  bool               `json:"autofund"`
+	After     *time.Time         `json:"after,omitempty"`
+	Until     *time.Time         `json:"until,omitempty"`
+	Hours     string             `json:"hours,omitempty"`
+	Jitter    string             `json:"jitter,omitempty"`
+}
+
+type apiStatus struct {
+	Profile  string     `json:"profile,omitempty"`
+	Config   apiConfig  `json:"config"`
+	Paused   bool       `json:"paused"`
+	ResumeAt *time.Time `json:"resume_at,omitempty"`
+	NextRun  *time.Time `json:"next_run,omitempty"`
+	LastRun  *runResult `json:"last_run,omitempty"`
+}
+
+type apiConfirmation struct {
+	Message string    `json:"message"`
+	Confirm string    `json:"confirm"`
+	Expires time.Time `json:"expires"`
+}
+
+// newControlAPI reads the bearer token and connects the API to the daemon
+// loops of schedules.
+func newControlAPI(schedules []*gdaxSchedule, tokenFile string) (*controlAPI, error) {
+	b, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, err
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return nil, fmt.Errorf("api token file %s is empty", tokenFile)
+	}
+
+	for _, s := range schedules {
+		s.triggers = make(chan runTrigger)
+	}
+
+	return &controlAPI{
+		schedules: schedules,
+		token:     token,
+		confirm:   map[string]pendingConfirmation{},
+	}, nil
+}
+
+func (a *controlAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/status", a.method(http.MethodGet, a.status))
+	mux.HandleFunc("/api/runs", a.method(http.MethodGet, a.runs))
+	mux.HandleFunc("/api/run", a.method(http.MethodPost, a.run))
+	mux.HandleFunc("/api/pause", a.method(http.MethodPost, a.pause(true)))
+	mux.HandleFunc("/api/resume", a.method(http.MethodPost, a.pause(false)))
+
+	return a.authorize(mux)
+}
+
+func (a *controlAPI) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// scheduleHandler handles requests for the schedule of one profile.
+type scheduleHandler func(w http.ResponseWriter, r *http.Request, s *gdaxSchedule)
+
+// method serves fn for the schedule given by the profile parameter, which
+// may be left out when there is a single schedule.
+func (a *controlAPI) method(method string, fn scheduleHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, "use "+method)
+			return
+		}
+
+		name := r.URL.Query().Get("profile")
+		if name == "" && len(a.schedules) == 1 {
+			fn(w, r, a.schedules[0])
+			return
+		}
+		if name == "" {
+			writeError(w, http.StatusBadRequest, "profile is required")
+			return
+		}
+
+		for _, s := range a.schedules {
+			if s.profile == name {
+				fn(w, r, s)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "unknown profile "+name)
+	}
+}
+
+func (a *controlAPI) status(w http.ResponseWriter, r *http.Request, s *gdaxSchedule) {
+	next, last := s.state.snapshot()
+
+	// unlike status, load leaves an expired pause file for the next run to remove
+	pause, err := s.pause.load()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if pause != nil && pause.ResumeAt != nil && !time.Now().Before(*pause.ResumeAt) {
+		pause = nil
+	}
+
+	status := apiStatus{
+		Profile: s.profile,
+		Config: apiConfig{
+			Currency:  s.req.currency,
+			Amount:    s.req.usd,
+			Coins:     map[string]float64{},
+			Cadence:   s.cadence(),
+			OrderType: "market",
+			Trade:     !s.debug,
+			AutoFund:  s.req.autoFund,
+			Hours:     s.req.hours.String(),
+		},
+		Paused:  pause != nil,
+		LastRun: last,
+	}
+
+	if s.req.orderType == exchanges.Limit {
+		status.Config.OrderType = "limit"
+	}
+	for coin, order := range s.coins {
+		status.Config.Coins[coin] = order.amount
+	}
+	if !s.req.after.IsZero() {
+		status.Config.After = &s.req.after
+	}
+	if !s.req.until.IsZero() {
+		status.Config.Until = &s.req.until
+	}
+	if s.req.jitter > 0 {
+		status.Config.Jitter = s.req.jitter.String()
+	}
+	if pause != nil {
+		status.ResumeAt = pause.ResumeAt
+	}
+	if !next.IsZero() {
+		status.NextRun = &next
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// runs returns the most recent ledger entries first, 20 unless limit is given.
+func (a *controlAPI) runs(w http.ResponseWriter, r *http.Request, s *gdaxSchedule) {
+	if s.ledger == nil {
+		writeError(w, http.StatusNotFound, "no ledger configured, use --ledger")
+		return
+	}
+
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = n
+	}
+
+	entries, err := s.ledger.entries()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	recent := []ledgerEntry{}
+	for i := len(entries) - 1; i >= 0 && len(recent) < limit; i-- {
+		recent = append(recent, entries[i])
+	}
+
+	writeJSON(w, http.StatusOK, recent)
+}
+
+// run triggers a run now. Like --force on the command line a forced run
+// needs a confirmation: the first request returns a token which has to be
+// sent back as confirm.
+func (a *controlAPI) run(w http.ResponseWriter, r *http.Request, s *gdaxSchedule) {
+	q := r.URL.Query()
+	force := q.Get("force") == "true"
+
+	if force {
+		token := q.Get("confirm")
+		if token == "" {
+			c, err := a.newConfirmation(s.profile)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			writeJSON(w, http.StatusConflict, c)
+			return
+		}
+		if !a.confirmed(token, s.profile) {
+			writeError(w, http.StatusForbidden, "confirmation token is invalid or expired")
+			return
+		}
+	}
+
+	done := make(chan *runResult, 1)
+	select {
+	case s.triggers <- runTrigger{force: force, done: done}:
+	case <-r.Context().Done():
+		return
+	}
+
+	select {
+	case result := <-done:
+		writeJSON(w, http.StatusOK, result)
+	case <-r.Context().Done():
+	}
+}
+
+// pause turns the kill switch on, until the optional resume date, or off.
+func (a *controlAPI) pause(paused bool) scheduleHandler {
+	return func(w http.ResponseWriter, r *http.Request, s *gdaxSchedule) {
+		var err error
+		if paused {
+			var resumeAt time.Time
+			if v := r.URL.Query().Get("resume"); v != "" {
+				resumeAt, err = time.ParseInLocation("2006-01-02", v, time.Local)
+				if err != nil {
+					writeError(w, http.StatusBadRequest, "resume must be a date, e.g. 2017-12-31")
+					return
+				}
+			}
+			err = s.pause.pause(time.Now(), resumeAt)
+			s.logger.Warnw("Purchases paused through the control API")
+		} else {
+			err = s.pause.resume()
+			s.logger.Infow("Purchases resumed through the control API")
+		}
+
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]bool{"paused": paused})
+	}
+}
+
+// newConfirmation issues a token confirming a forced run of profile.
+func (a *controlAPI) newConfirmation(profile string) (apiConfirmation, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return apiConfirmation{}, err
+	}
+	token := hex.EncodeToString(b)
+	now := time.Now()
+	expires := now.Add(confirmTTL)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.purgeConfirmations(now)
+	a.confirm[token] = pendingConfirmation{profile: profile, expires: expires}
+
+	return apiConfirmation{
+		Message: "Force method is used, repeat the request with confirm to proceed",
+		Confirm: token,
+		Expires: expires,
+	}, nil
+}
+
+// confirmed consumes a confirmation token issued for profile.
+func (a *controlAPI) confirmed(token string, profile string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.purgeConfirmations(time.Now())
+
+	c, ok := a.confirm[token]
+	if !ok || c.profile != profile {
+		return false
+	}
+	delete(a.confirm, token)
+	return true
+}
+
+// purgeConfirmations drops the expired tokens, a.mu must be held.
+func (a *controlAPI) purgeConfirmations(now time.Time) {
+	for t, c := range a.confirm {
+		if now.After(c.expires) {
+			delete(a.confirm, t)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAPI(t *testing.T, s *gdaxSchedule) http.Handler {
+	path := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(path, []byte("secret\n"), 0600)
+
+	api, err := newControlAPI([]*gdaxSchedule{s}, path)
+	assert.Nil(t, err)
+
+	return api.handler()
+}
+
+func apiRequest(h http.Handler, method string, path string, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNewControlAPI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(path, []byte(" \n"), 0600)
+
+	_, err := newControlAPI([]*gdaxSchedule{{}}, path)
+	assert.EqualError(t, err, "api token file "+path+" is empty")
+
+	_, err = newControlAPI([]*gdaxSchedule{{}}, filepath.Join(t.TempDir(), "missing"))
+	assert.NotNil(t, err)
+}
+
+func TestControlAPIAuthorization(t *testing.T) {
+	h := newTestAPI(t, &gdaxSchedule{})
+
+	assert.Equal(t, http.StatusUnauthorized, apiRequest(h, "GET", "/api/status", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, apiRequest(h, "GET", "/api/status", "wrong").Code)
+	assert.Equal(t, http.StatusOK, apiRequest(h, "GET", "/api/status", "secret").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, apiRequest(h, "GET", "/api/pause", "secret").Code)
+
+	// the token without the bearer scheme is rejected
+	req := httptest.NewRequest("GET", "/api/status", nil)
+	req.Header.Set("Authorization", "secret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestControlAPIStatus(t *testing.T) {
+	s := &gdaxSchedule{}
+	s.req = syncRequest{every: 24 * time.Hour, currency: "USD", usd: 100, autoFund: true}
+	s.coins = map[string]orderDetails{"BTC": {symbol: "BTC-USD", amount: 70}, "ETH": {symbol: "ETH-USD", amount: 30}}
+	s.debug = true
+	next := time.Date(2023, 3, 6, 14, 0, 0, 0, time.UTC)
+	s.state.setNext(next)
+	s.state.finished(&runResult{Status: statusSkipped, Reason: skipRecentPurchase})
+
+	h := newTestAPI(t, s)
+	rec := apiRequest(h, "GET", "/api/status", "secret")
+
+	var status apiStatus
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &status))
+
+	assert.Equal(t, "24h0m0s", status.Config.Cadence)
+	assert.Equal(t, map[string]float64{"BTC": 70, "ETH": 30}, status.Config.Coins)
+	assert.Equal(t, "market", status.Config.OrderType)
+	assert.False(t, status.Config.Trade)
+	assert.True(t, status.Config.AutoFund)
+	assert.False(t, status.Paused)
+	assert.Equal(t, next, *status.NextRun)
+	assert.Equal(t, statusSkipped, status.LastRun.Status)
+}
+
+func TestControlAPIPause(t *testing.T) {
+	s := &gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.pause.path = filepath.Join(t.TempDir(), "pause")
+	h := newTestAPI(t, s)
+
+	rec := apiRequest(h, "POST", "/api/pause?resume=2099-01-02", "secret")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"paused":true}`, rec.Body.String())
+	assert.FileExists(t, s.pause.path)
+
+	var status apiStatus
+	json.Unmarshal(apiRequest(h, "GET", "/api/status", "secret").Body.Bytes(), &status)
+	assert.True(t, status.Paused)
+	assert.Equal(t, time.Date(2099, 1, 2, 0, 0, 0, 0, time.Local), status.ResumeAt.Local())
+
+	apiRequest(h, "POST", "/api/resume", "secret")
+	assert.NoFileExists(t, s.pause.path)
+
+	t.Run("when the pause expired", func(t *testing.T) {
+		assert.Nil(t, s.pause.pause(time.Now().Add(-time.Hour), time.Now().Add(-time.Minute)))
+
+		var status apiStatus
+		json.Unmarshal(apiRequest(h, "GET", "/api/status", "secret").Body.Bytes(), &status)
+		assert.False(t, status.Paused)
+		assert.FileExists(t, s.pause.path, "reading the status changes nothing")
+	})
+
+	assert.Equal(t, http.StatusBadRequest, apiRequest(h, "POST", "/api/pause?resume=soon", "secret").Code)
+}
+
+func TestControlAPIProfiles(t *testing.T) {
+	alice := &gdaxSchedule{profile: "alice"}
+	alice.logger = loggerStub(t).Sugar()
+	bob := &gdaxSchedule{profile: "bob"}
+	bob.logger = loggerStub(t).Sugar()
+
+	path := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(path, []byte("secret\n"), 0600)
+	api, err := newControlAPI([]*gdaxSchedule{alice, bob}, path)
+	assert.Nil(t, err)
+	h := api.handler()
+
+	assert.NotNil(t, alice.triggers)
+	assert.NotNil(t, bob.triggers)
+
+	assert.Equal(t, http.StatusBadRequest, apiRequest(h, "GET", "/api/status", "secret").Code)
+	assert.Equal(t, http.StatusNotFound, apiRequest(h, "GET", "/api/status?profile=carol", "secret").Code)
+
+	var status apiStatus
+	json.Unmarshal(apiRequest(h, "GET", "/api/status?profile=bob", "secret").Body.Bytes(), &status)
+	assert.Equal(t, "bob", status.Profile)
+
+	assert.Equal(t, http.StatusOK, apiRequest(h, "POST", "/api/pause?profile=bob", "secret").Code)
+
+	paused, _ := bob.pause.status(time.Now())
+	assert.NotNil(t, paused)
+	paused, _ = alice.pause.status(time.Now())
+	assert.Nil(t, paused)
+
+	// a confirmation token only confirms a forced run of its profile
+	rec := apiRequest(h, "POST", "/api/run?force=true&profile=bob", "secret")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	var c apiConfirmation
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &c))
+	assert.Equal(t, http.StatusForbidden, apiRequest(h, "POST", "/api/run?force=true&profile=alice&confirm="+c.Confirm, "secret").Code)
+}
+
+func TestControlAPIConfirmationsExpire(t *testing.T) {
+	api := &controlAPI{confirm: map[string]pendingConfirmation{
+		"old": {expires: time.Now().Add(-time.Minute)},
+	}}
+
+	c, err := api.newConfirmation("")
+	assert.Nil(t, err)
+
+	assert.Len(t, api.confirm, 1)
+	assert.True(t, api.confirmed(c.Confirm, ""))
+	assert.False(t, api.confirmed("old", ""))
+}
+
+func TestControlAPIRuns(t *testing.T) {
+	s := &gdaxSchedule{}
+	h := newTestAPI(t, s)
+
+	assert.Equal(t, http.StatusNotFound, apiRequest(h, "GET", "/api/runs", "secret").Code)
+
+	s.ledger = newLedger(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	s.ledger.record(ledgerEntry{Coin: "BTC", Kind: ledgerPurchase, Amount: 10, OrderID: "1"})
+	s.ledger.record(ledgerEntry{Coin: "BTC", Kind: ledgerSkipped, Amount: 10, Reason: "price"})
+	s.ledger.record(ledgerEntry{Coin: "BTC", Kind: ledgerPurchase, Amount: 20, OrderID: "2"})
+
+	rec := apiRequest(h, "GET", "/api/runs?limit=2", "secret")
+
+	var entries []ledgerEntry
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &entries))
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "2", entries[0].OrderID)
+	assert.Equal(t, ledgerSkipped, entries[1].Kind)
+
+	assert.Equal(t, http.StatusBadRequest, apiRequest(h, "GET", "/api/runs?limit=x", "secret").Code)
+}
+
+func TestControlAPIRun(t *testing.T) {
+	s := &gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{every: time.Hour, after: time.Now().Add(time.Hour)}
+	h := newTestAPI(t, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runDaemon(ctx, s)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	rec := apiRequest(h, "POST", "/api/run", "secret")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var result runResult
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, statusSkipped, result.Status)
+	assert.Equal(t, skipNotStarted, result.Reason)
+
+	t.Run("when forced", func(t *testing.T) {
+		rec := apiRequest(h, "POST", "/api/run?force=true", "secret")
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		var c apiConfirmation
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &c))
+		assert.NotEmpty(t, c.Confirm)
+
+		assert.Equal(t, http.StatusForbidden, apiRequest(h, "POST", "/api/run?force=true&confirm=wrong", "secret").Code)
+		assert.Equal(t, http.StatusOK, apiRequest(h, "POST", "/api/run?force=true&confirm="+c.Confirm, "secret").Code)
+
+		// tokens are single use
+		assert.Equal(t, http.StatusForbidden, apiRequest(h, "POST", "/api/run?force=true&confirm="+c.Confirm, "secret").Code)
+	})
+}
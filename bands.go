@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// priceBand limits purchases of a coin to a price range.
+type priceBand struct {
+	max   float64 // skip the purchase above this price, 0 disables
+	min   float64 // buy extra below this price, 0 disables
+	extra float64 // percentage added to the purchase below min
+}
+
+// parseBands builds per coin price bands from --max-price COIN:PRICE and
+// --min-price COIN:PRICE[:PERCENT] values.
+func parseBands(maxPrices []string, minPrices []string) (map[string]priceBand, error) {
+	bands := map[string]priceBand{}
+
+	for _, v := range maxPrices {
+		arr := strings.Split(v, ":")
+		if len(arr) != 2 {
+			return nil, fmt.Errorf("--max-price misformatted: %s", v)
+		}
+
+		price, err := strconv.ParseFloat(arr[1], 64)
+		if err != nil || price <= 0 {
+			return nil, fmt.Errorf("--max-price misformatted: %s", v)
+		}
+
+		band := bands[arr[0]]
+		band.max = price
+		bands[arr[0]] = band
+	}
+
+	for _, v := range minPrices {
+		arr := strings.Split(v, ":")
+		if len(arr) != 2 && len(arr) != 3 {
+			return nil, fmt.Errorf("--min-price misformatted: %s", v)
+		}
+
+		price, err := strconv.ParseFloat(arr[1], 64)
+		if err != nil || price <= 0 {
+			return nil, fmt.Errorf("--min-price misformatted: %s", v)
+		}
+
+		extra := 100.0
+		if len(arr) == 3 {
+			extra, err = strconv.ParseFloat(arr[2], 64)
+			if err != nil || extra < 0 {
+				return nil, fmt.Errorf("--min-price misformatted: %s", v)
+			}
+		}
+
+		band := bands[arr[0]]
+		band.min = price
+		band.extra = extra
+		bands[arr[0]] = band
+	}
+
+	for coin, band := range bands {
+		if band.max > 0 && band.min > band.max {
+			return nil, fmt.Errorf("%s minimum price %.2f is above maximum price %.2f", coin, band.min, band.max)
+		}
+	}
+
+	return bands, nil
+}
+
+// planPurchases applies price bands and carried forward amounts to the
+// scheduled orders. Coins priced above their band are left out and recorded
+// as skipped windows.
+func (s *gdaxSchedule) planPurchases(ctx context.Context, now time.Time) (map[string]orderDetails, error) {
+	planned := map[string]orderDetails{}
+
+	for coin, order := range s.coins {
+		carry := 0.0
+		if s.req.carryCap > 0 {
+			c, err := s.ledger.carry(coin)
+			if err != nil {
+				return nil, err
+			}
+			carry = c
+		}
+
+		amount := decimal.NewFromFloat(order.amount)
+
+		if band, ok := s.req.bands[coin]; ok {
+			ticker, err := s.exchange.GetTicker(ctx, order.symbol)
+			if err != nil {
+				return nil, err
+			}
+
+			if band.max > 0 && ticker.Price > band.max {
+				reason := fmt.Sprintf("price %.2f above maximum %.2f", ticker.Price, band.max)
+				if err := s.skipWindow(now, coin, order.amount, carry, ticker.Price, reason); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			if band.min > 0 && ticker.Price < band.min {
+				amount = amount.Mul(decimal.NewFromFloat(100 + band.extra)).Div(decimal.NewFromInt(100))
+
+				s.logger.Infow(
+					"Price below band, buying extra",
+					"coin", coin,
+					"price", ticker.Price,
+					"minimum", band.min,
+					"percent", band.extra,
+				)
+			}
+		}
+
+		windows, err := s.catchUpWindows(coin, now)
+		if err != nil {
+			return nil, err
+		}
+
+		if windows > 0 {
+			amount = amount.Add(decimal.NewFromFloat(order.amount).Mul(decimal.NewFromInt(int64(windows))))
+		}
+
+		if carry > 0 {
+			s.logger.Infow(
+				"Adding carried forward amount",
+				"coin", coin,
+				"carry", carry,
+			)
+			amount = amount.Add(decimal.NewFromFloat(carry))
+		}
+
+		amountf, _ := amount.Truncate(2).Float64()
+		planned[coin] = orderDetails{symbol: order.symbol, amount: amountf, windows: windows}
+	}
+
+	return planned, nil
+}
+
+// skipWindow records a skipped purchase window, carrying the unspent amount
+// forward up to the configured cap.
+func (s *gdaxSchedule) skipWindow(now time.Time, coin string, amount float64, carry float64, price float64, reason string) error {
+	nextCarry := 0.0
+	if s.req.carryCap > 0 {
+		nextCarry = math.Min(carry+amount, s.req.carryCap)
+	}
+
+	s.logger.Infow(
+		"Skipping purchase window",
+		"coin", coin,
+		"reason", reason,
+		"carry", nextCarry,
+	)
+
+	if s.dryRun() {
+		return nil
+	}
+
+	return s.ledger.record(ledgerEntry{
+		Time:   now,
+		Coin:   coin,
+		Kind:   ledgerSkipped,
+		Amount: amount,
+		Price:  price,
+		Reason: reason,
+		Carry:  nextCarry,
+	})
+}
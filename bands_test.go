@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sberserker/dcagdax/exchanges"
+	"github.com/sberserker/dcagdax/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBands(t *testing.T) {
+	t.Run("when valid", func(t *testing.T) {
+		bands, err := parseBands([]string{"BTC:70000"}, []string{"BTC:40000:50", "ETH:1000"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, priceBand{max: 70000, min: 40000, extra: 50}, bands["BTC"])
+		assert.Equal(t, priceBand{min: 1000, extra: 100}, bands["ETH"])
+	})
+
+	t.Run("when misformatted", func(t *testing.T) {
+		_, err := parseBands([]string{"BTC"}, nil)
+		assert.EqualError(t, err, "--max-price misformatted: BTC")
+
+		_, err = parseBands(nil, []string{"BTC:abc"})
+		assert.EqualError(t, err, "--min-price misformatted: BTC:abc")
+	})
+
+	t.Run("when min above max", func(t *testing.T) {
+		_, err := parseBands([]string{"BTC:100"}, []string{"BTC:200"})
+		assert.EqualError(t, err, "BTC minimum price 200.00 is above maximum price 100.00")
+	})
+}
+
+func TestPlanPurchases(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	now := time.Now()
+
+	m := mocks.NewMockExchange(ctrl)
+
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.exchange = m
+	s.coins = map[string]orderDetails{"BTC": {symbol: "BTC-USD", amount: 40}, "ETH": {symbol: "ETH-USD", amount: 10}}
+	s.ledger = newLedger(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	s.req = syncRequest{
+		currency: "USD",
+		carryCap: 60,
+		bands: map[string]priceBand{
+			"BTC": {max: 70000},
+			"ETH": {min: 2000, extra: 50},
+		},
+	}
+
+	t.Run("when above max and below min", func(t *testing.T) {
+		m.EXPECT().GetTicker(ctx, "BTC-USD").Return(&exchanges.Ticker{Price: 80000}, nil)
+		m.EXPECT().GetTicker(ctx, "ETH-USD").Return(&exchanges.Ticker{Price: 1500}, nil)
+
+		planned, err := s.planPurchases(ctx, now)
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]orderDetails{"ETH": {symbol: "ETH-USD", amount: 15}}, planned)
+
+		last, _ := s.ledger.last("BTC")
+		assert.Equal(t, ledgerSkipped, last.Kind)
+		assert.Equal(t, 40.0, last.Carry)
+	})
+
+	t.Run("when carry is capped", func(t *testing.T) {
+		m.EXPECT().GetTicker(ctx, "BTC-USD").Return(&exchanges.Ticker{Price: 80000}, nil)
+		m.EXPECT().GetTicker(ctx, "ETH-USD").Return(&exchanges.Ticker{Price: 2500}, nil)
+
+		planned, err := s.planPurchases(ctx, now)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 10.0, planned["ETH"].amount)
+
+		carry, _ := s.ledger.carry("BTC")
+		assert.Equal(t, 60.0, carry)
+	})
+
+	t.Run("when back in band", func(t *testing.T) {
+		m.EXPECT().GetTicker(ctx, "BTC-USD").Return(&exchanges.Ticker{Price: 60000}, nil)
+		m.EXPECT().GetTicker(ctx, "ETH-USD").Return(&exchanges.Ticker{Price: 2500}, nil)
+
+		planned, err := s.planPurchases(ctx, now)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 100.0, planned["BTC"].amount)
+	})
+}
+
+func TestSyncRecordsPurchasesAndSkippedWindows(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	m := mocks.NewMockExchange(ctrl)
+
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, currency: "USD", usd: 50, carryCap: 100, bands: map[string]priceBand{"BTC": {max: 70000}}}
+	s.coins = map[string]orderDetails{"BTC": {symbol: "BTC-USD", amount: 50}}
+	s.markerCoin = "BTC"
+	s.sleepFunc = func(ctx context.Context, d time.Duration) error { return nil }
+	s.exchange = m
+	s.ledger = newLedger(filepath.Join(t.TempDir(), "ledger.jsonl"))
+
+	t.Run("when above max", func(t *testing.T) {
+		m.EXPECT().LastPurchaseTime(ctx, "BTC", "USD", gomock.Any()).Return(nil, nil)
+		m.EXPECT().GetTicker(ctx, "BTC-USD").Return(&exchanges.Ticker{Price: 80000}, nil)
+
+		_, err := s.Sync()
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("when skipped window is recent", func(t *testing.T) {
+		m.EXPECT().LastPurchaseTime(ctx, "BTC", "USD", gomock.Any()).Return(nil, nil)
+
+		r, err := s.Sync()
+
+		assert.Nil(t, err)
+		assert.ErrorIs(t, r.Skip, errRecentPurchase)
+	})
+
+	t.Run("when next window is in band", func(t *testing.T) {
+		entries, _ := s.ledger.entries()
+		entries[0].Time = time.Now().Add(-25 * time.Hour)
+		s.ledger = newLedger(filepath.Join(t.TempDir(), "ledger.jsonl"))
+		assert.Nil(t, s.ledger.record(entries[0]))
+
+		result := exchanges.Order{OrderID: "1"}
+		m.EXPECT().LastPurchaseTime(ctx, "BTC", "USD", gomock.Any()).Return(nil, nil)
+		m.EXPECT().GetTicker(ctx, "BTC-USD").Return(&exchanges.Ticker{Price: 60000}, nil)
+		m.EXPECT().GetFiatAccount(ctx, "USD").Return(&exchanges.Account{Available: 100}, nil)
+		m.EXPECT().CreateOrder(ctx, "BTC-USD", 100.0, exchanges.Market, gomock.Any()).Return(&result, nil)
+
+		_, err := s.Sync()
+
+		assert.Nil(t, err)
+
+		last, _ := s.ledger.last("BTC")
+		assert.Equal(t, ledgerPurchase, last.Kind)
+		assert.Equal(t, "1", last.OrderID)
+		assert.Equal(t, 0.0, last.Carry)
+	})
+}
@@ -0,0 +1,157 @@
+// Package calendar evaluates calendar based purchase schedules: cron
+// expressions and simple phrases such as "every friday 14:00 America/New_York"
+// or "every 1st and 15th 09:00".
+package calendar
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule reports activation times of a calendar schedule.
+type Schedule interface {
+	// Next returns the first activation strictly after t.
+	Next(t time.Time) time.Time
+
+	// Prev returns the last activation at or before t.
+	Prev(t time.Time) time.Time
+
+	// Location returns the time zone the schedule is evaluated in.
+	Location() *time.Location
+
+	String() string
+}
+
+var (
+	timeOfDay = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+	ordinal   = regexp.MustCompile(`^(\d{1,2})(st|nd|rd|th)?$`)
+
+	fullWeekdays = map[string]string{
+		"sunday": "sun", "monday": "mon", "tuesday": "tue", "wednesday": "wed",
+		"thursday": "thu", "friday": "fri", "saturday": "sat",
+	}
+)
+
+// Parse parses a schedule. Supported formats:
+//
+//	0 14 * * 5                              cron expression evaluated in local time
+//	CRON_TZ=America/New_York 0 14 * * 5     cron expression in a time zone, TZ= works too
+//	@daily, @weekly, @monthly               cron descriptors
+//	every friday 14:00 America/New_York     weekdays with an optional time and time zone
+//	every 1st and 15th 09:00                days of the month
+//	every day 20:00                         every day
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty schedule")
+	}
+
+	fields := strings.Fields(spec)
+	if strings.EqualFold(fields[0], "every") {
+		return parsePhrase(spec, fields[1:])
+	}
+
+	loc := time.Local
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if strings.HasPrefix(fields[0], prefix) {
+			var err error
+			loc, err = time.LoadLocation(strings.TrimPrefix(fields[0], prefix))
+			if err != nil {
+				return nil, err
+			}
+			fields = fields[1:]
+			break
+		}
+	}
+
+	return parseCron(strings.Join(fields, " "), loc)
+}
+
+// parsePhrase translates "every <days> [HH:MM] [time zone]" into a cron expression.
+func parsePhrase(spec string, fields []string) (Schedule, error) {
+	loc := time.Local
+	hour, minute := 0, 0
+	weekdays := []string{}
+	days := []string{}
+	everyDay := false
+
+	for i := 0; i < len(fields); i++ {
+		raw := strings.Trim(fields[i], ",")
+		word := strings.ToLower(raw)
+
+		switch {
+		case word == "" || word == "and" || word == "at" || word == "on":
+		case word == "of" && i+2 < len(fields) && strings.EqualFold(fields[i+2], "month"):
+			i += 2 // of the month
+		case word == "day":
+			everyDay = true
+		case weekday(word) != "":
+			weekdays = append(weekdays, weekday(word))
+		case timeOfDay.MatchString(word):
+			m := timeOfDay.FindStringSubmatch(word)
+			hour, _ = strconv.Atoi(m[1])
+			minute, _ = strconv.Atoi(m[2])
+			if hour > 23 || minute > 59 {
+				return nil, fmt.Errorf("invalid time %s in schedule %q", raw, spec)
+			}
+		case ordinal.MatchString(word):
+			m := ordinal.FindStringSubmatch(word)
+			day, _ := strconv.Atoi(m[1])
+			if day < 1 || day > 31 {
+				return nil, fmt.Errorf("invalid day %s in schedule %q", raw, spec)
+			}
+			days = append(days, m[1])
+		default:
+			l, err := time.LoadLocation(raw)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected %q in schedule %q", raw, spec)
+			}
+			loc = l
+		}
+	}
+
+	dom, dow := "*", "*"
+	switch {
+	case everyDay && (len(weekdays) > 0 || len(days) > 0):
+		return nil, fmt.Errorf("schedule %q mixes every day with specific days", spec)
+	case len(weekdays) > 0 && len(days) > 0:
+		return nil, fmt.Errorf("schedule %q mixes weekdays and days of the month", spec)
+	case len(weekdays) > 0:
+		dow = strings.Join(weekdays, ",")
+	case len(days) > 0:
+		dom = strings.Join(days, ",")
+	case !everyDay:
+		return nil, fmt.Errorf("schedule %q has no days", spec)
+	}
+
+	s, err := parseCron(fmt.Sprintf("%d %d %s * %s", minute, hour, dom, dow), loc)
+	if err != nil {
+		return nil, err
+	}
+	s.spec = spec
+
+	return s, nil
+}
+
+// weekday returns the short name of a weekday such as "friday", "fridays" or "fri".
+func weekday(word string) string {
+	if short, ok := fullWeekdays[strings.TrimSuffix(word, "s")]; ok {
+		return short
+	}
+	if _, ok := weekdayNames[word]; ok {
+		return word
+	}
+	return ""
+}
+
+// Count returns the number of activations in (from, to].
+func Count(s Schedule, from time.Time, to time.Time) int {
+	count := 0
+	for t := s.Next(from); !t.IsZero() && !t.After(to); t = s.Next(t) {
+		count++
+	}
+	return count
+}
@@ -0,0 +1,91 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	return loc
+}
+
+func TestParsePhrase(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+
+	type test struct {
+		spec string
+		from time.Time
+		next time.Time
+	}
+
+	tests := []test{
+		{
+			spec: "every friday 14:00 America/New_York",
+			from: time.Date(2024, 3, 6, 12, 0, 0, 0, ny), // wednesday
+			next: time.Date(2024, 3, 8, 14, 0, 0, 0, ny),
+		},
+		{
+			spec: "every Monday, Thursday at 9:30 UTC",
+			from: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), // tuesday
+			next: time.Date(2024, 3, 7, 9, 30, 0, 0, time.UTC),
+		},
+		{
+			spec: "every 1st and 15th of the month 09:00 UTC",
+			from: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+			next: time.Date(2024, 3, 15, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "every day 20:00 UTC",
+			from: time.Date(2024, 12, 31, 20, 0, 0, 0, time.UTC),
+			next: time.Date(2025, 1, 1, 20, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range tests {
+		s, err := Parse(tc.spec)
+		if !assert.Nil(t, err, tc.spec) {
+			continue
+		}
+
+		assert.True(t, tc.next.Equal(s.Next(tc.from)), "%s: next %s, got %s", tc.spec, tc.next, s.Next(tc.from))
+		assert.Equal(t, tc.spec, s.String())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	specs := []string{
+		"",
+		"every",
+		"every friday 25:00",
+		"every 32nd",
+		"every day friday",
+		"every friday 15th",
+		"every friday Mars/Olympus",
+		"0 14 * *",
+		"61 * * * *",
+		"* * * * 1-x",
+		"TZ=Nowhere/Land 0 0 * * *",
+	}
+
+	for _, spec := range specs {
+		_, err := Parse(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
+
+func TestCount(t *testing.T) {
+	s, err := Parse("every 1st, 15th 00:00 UTC")
+	assert.Nil(t, err)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	// jan 15, feb 1, feb 15, mar 1, mar 15
+	assert.Equal(t, 5, Count(s, from, to))
+}
@@ -0,0 +1,65 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronNext(t *testing.T) {
+	type test struct {
+		spec string
+		from time.Time
+		next time.Time
+	}
+
+	tests := []test{
+		{spec: "00 20 * * *", from: time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC), next: time.Date(2024, 1, 2, 20, 0, 0, 0, time.UTC)},
+		{spec: "*/15 * * * *", from: time.Date(2024, 1, 1, 10, 16, 30, 0, time.UTC), next: time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)},
+		{spec: "0 9 * * mon-fri", from: time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC), next: time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC)},
+		{spec: "0 0 31 * *", from: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), next: time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 29 feb *", from: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), next: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 12 1 * 7", from: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), next: time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC)},
+		{spec: "@weekly", from: time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC), next: time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range tests {
+		s, err := parseCron(tc.spec, time.UTC)
+		if !assert.Nil(t, err, tc.spec) {
+			continue
+		}
+
+		assert.Equal(t, tc.next, s.Next(tc.from), tc.spec)
+	}
+}
+
+func TestCronPrev(t *testing.T) {
+	s, err := parseCron("0 14 * * fri", time.UTC)
+	assert.Nil(t, err)
+
+	at := time.Date(2024, 3, 8, 14, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, at, s.Prev(at))
+	assert.Equal(t, at, s.Prev(at.Add(3*24*time.Hour)))
+	assert.Equal(t, at.Add(-7*24*time.Hour), s.Prev(at.Add(-time.Second)))
+}
+
+func TestCronDaylightSaving(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+
+	s, err := Parse("CRON_TZ=America/New_York 30 2 * * *")
+	assert.Nil(t, err)
+
+	// 2:30 does not exist on 2024-03-10, the next run is on the following day
+	from := time.Date(2024, 3, 9, 3, 0, 0, 0, ny)
+	assert.Equal(t, time.Date(2024, 3, 11, 2, 30, 0, 0, ny), s.Next(from))
+
+	daily, err := Parse("every day 14:00 America/New_York")
+	assert.Nil(t, err)
+
+	next := daily.Next(time.Date(2024, 3, 9, 15, 0, 0, 0, ny))
+	assert.Equal(t, 14, next.Hour())
+	assert.Equal(t, 10, next.Day())
+	assert.Equal(t, 23*time.Hour, next.Sub(daily.Prev(next.Add(-time.Minute))))
+}
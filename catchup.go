@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	catchUpSkip = "skip"
+	catchUpFull = "full"
+)
+
+// catchUpPolicy decides how many missed purchase windows are bought in the
+// current window. The zero value skips missed windows.
+type catchUpPolicy struct {
+	full    bool
+	windows int // maximum number of missed windows to catch up when not full
+}
+
+func parseCatchUp(value string) (catchUpPolicy, error) {
+	switch value {
+	case "", catchUpSkip:
+		return catchUpPolicy{}, nil
+	case catchUpFull:
+		return catchUpPolicy{full: true}, nil
+	}
+
+	windows, err := strconv.Atoi(value)
+	if err != nil || windows < 0 {
+		return catchUpPolicy{}, fmt.Errorf("--catch-up misformatted: %s", value)
+	}
+
+	return catchUpPolicy{windows: windows}, nil
+}
+
+func (p catchUpPolicy) enabled() bool {
+	return p.full || p.windows > 0
+}
+
+// limit caps the number of missed windows according to the policy.
+func (p catchUpPolicy) limit(missed int) int {
+	if missed <= 0 || !p.enabled() {
+		return 0
+	}
+	if p.full || missed < p.windows {
+		return missed
+	}
+	return p.windows
+}
+
+// plannedWindows returns the number of purchase windows from start up to and
+// including now.
+func plannedWindows(start time.Time, now time.Time, every time.Duration) int {
+	if every <= 0 || now.Before(start) {
+		return 0
+	}
+	return int(now.Sub(start)/every) + 1
+}
+
+// catchUpWindows returns the number of missed windows of coin to buy in
+// addition to the current one. Windows are counted from --after and compared
+// with the windows recorded in the ledger. Windows before the first ledger
+// entry of coin are not counted, a ledger created long after --after would
+// otherwise buy all the windows since then at once.
+func (s *gdaxSchedule) catchUpWindows(coin string, now time.Time) (int, error) {
+	if !s.req.catchUp.enabled() {
+		return 0, nil
+	}
+
+	entries, err := s.ledger.entries()
+	if err != nil {
+		return 0, err
+	}
+
+	var first *ledgerEntry
+	handled := 0
+	for i, e := range entries {
+		if e.Coin != coin || e.Time.Before(s.req.after) {
+			continue
+		}
+		if first == nil {
+			first = &entries[i]
+		}
+		handled += e.windows()
+	}
+
+	if first == nil {
+		return 0, nil
+	}
+
+	// windows from the ones handled by the first entry up to now, the current
+	// window is not handled yet
+	planned := s.plannedWindowsUntil(now) - s.plannedWindowsUntil(first.Time) + first.windows()
+	missed := planned - handled - 1
+
+	windows := s.req.catchUp.limit(missed)
+	if missed > 0 {
+		s.logger.Infow(
+			"Missed purchase windows",
+			"coin", coin,
+			"missed", missed,
+			"catchUp", windows,
+		)
+	}
+
+	return windows, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sberserker/dcagdax/exchanges"
+	"github.com/sberserker/dcagdax/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCatchUp(t *testing.T) {
+	type test struct {
+		value  string
+		policy catchUpPolicy
+		err    string
+	}
+
+	tests := []test{
+		{value: "skip", policy: catchUpPolicy{}},
+		{value: "full", policy: catchUpPolicy{full: true}},
+		{value: "3", policy: catchUpPolicy{windows: 3}},
+		{value: "-1", err: "--catch-up misformatted: -1"},
+		{value: "some", err: "--catch-up misformatted: some"},
+	}
+
+	for _, tc := range tests {
+		policy, err := parseCatchUp(tc.value)
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, tc.policy, policy)
+	}
+}
+
+func TestCatchUpLimit(t *testing.T) {
+	assert.Equal(t, 0, catchUpPolicy{}.limit(5))
+	assert.Equal(t, 5, catchUpPolicy{full: true}.limit(5))
+	assert.Equal(t, 2, catchUpPolicy{windows: 2}.limit(5))
+	assert.Equal(t, 1, catchUpPolicy{windows: 2}.limit(1))
+	assert.Equal(t, 0, catchUpPolicy{full: true}.limit(-1))
+}
+
+func TestPlannedWindows(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	assert.Equal(t, 0, plannedWindows(start, start.Add(-time.Hour), day))
+	assert.Equal(t, 1, plannedWindows(start, start, day))
+	assert.Equal(t, 1, plannedWindows(start, start.Add(23*time.Hour), day))
+	assert.Equal(t, 8, plannedWindows(start, start.Add(7*day), day))
+}
+
+func TestSyncCatchesUpMissedWindows(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	m := mocks.NewMockExchange(ctrl)
+	now := time.Now()
+	after := now.Add(-5*24*time.Hour - time.Hour) // 6 windows including the current one
+
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{every: 24 * time.Hour, after: after, orderType: exchanges.Market, currency: "USD", usd: 10, catchUp: catchUpPolicy{windows: 3}}
+	s.coins = map[string]orderDetails{"BTC": {symbol: "BTC-USD", amount: 10}}
+	s.markerCoin = "BTC"
+	s.sleepFunc = func(ctx context.Context, d time.Duration) error { return nil }
+	s.exchange = m
+	s.ledger = newLedger(filepath.Join(t.TempDir(), "ledger.jsonl"))
+
+	// only the first window was bought
+	assert.Nil(t, s.ledger.record(ledgerEntry{Time: after.Add(time.Minute), Coin: "BTC", Kind: ledgerPurchase, Amount: 10}))
+
+	t.Run("when capped", func(t *testing.T) {
+		windows, err := s.catchUpWindows("BTC", now)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 3, windows)
+	})
+
+	t.Run("when purchased", func(t *testing.T) {
+		result := exchanges.Order{OrderID: "1"}
+		m.EXPECT().LastPurchaseTime(ctx, "BTC", "USD", gomock.Any()).Return(nil, nil)
+		m.EXPECT().GetFiatAccount(ctx, "USD").Return(&exchanges.Account{Available: 100}, nil)
+		m.EXPECT().CreateOrder(ctx, "BTC-USD", 40.0, exchanges.Market, gomock.Any()).Return(&result, nil)
+
+		_, err := s.Sync()
+
+		assert.Nil(t, err)
+
+		last, _ := s.ledger.last("BTC")
+		assert.Equal(t, 4, last.Windows)
+	})
+
+	t.Run("when caught up partially", func(t *testing.T) {
+		windows, err := s.catchUpWindows("BTC", now)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, windows)
+
+		windows, err = s.catchUpWindows("BTC", now.Add(24*time.Hour))
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, windows)
+	})
+}
+
+func TestCatchUpStartsAtTheFirstLedgerEntry(t *testing.T) {
+	now := time.Now()
+	after := now.Add(-365 * 24 * time.Hour)
+
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{every: 24 * time.Hour, after: after, currency: "USD", usd: 10, catchUp: catchUpPolicy{full: true}}
+	s.ledger = newLedger(filepath.Join(t.TempDir(), "ledger.jsonl"))
+
+	t.Run("when the ledger is empty", func(t *testing.T) {
+		windows, err := s.catchUpWindows("BTC", now)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, windows)
+	})
+
+	t.Run("when the ledger starts after --after", func(t *testing.T) {
+		// the ledger was added three days ago, the windows of the year before are not caught up
+		assert.Nil(t, s.ledger.record(ledgerEntry{Time: now.Add(-3 * 24 * time.Hour), Coin: "BTC", Kind: ledgerPurchase, Amount: 10}))
+
+		windows, err := s.catchUpWindows("BTC", now)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, windows)
+	})
+}
@@ -2,6 +2,7 @@ package coinbase
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,12 +10,17 @@ import (
 	"net/http"
 
 	"github.com/sberserker/dcagdax/clients/coinbasev3"
+	"github.com/sberserker/dcagdax/clients/transport"
 )
 
+// rateLimit is the v2 API limit of 10,000 requests per hour.
+var rateLimit = transport.Limit{Rate: 10000.0 / 3600, Burst: 10}
+
 type Client struct {
-	BaseURL string
-	Secret  string
-	Key     string
+	BaseURL    string
+	Secret     string
+	Key        string
+	HTTPClient *http.Client
 }
 
 func NewClient(secret, key, passphrase string) *Client {
@@ -23,11 +29,36 @@ func NewClient(secret, key, passphrase string) *Client {
 		Secret:  secret,
 		Key:     key,
 	}
+	client.SetTransport(nil)
 
 	return &client
 }
 
-func (c *Client) Request(method string, url string,
+// SetTransport sends the requests of the client through base, http.DefaultTransport when nil, e.g. a
+// transport.Recorder replaying fixtures. The rate limit, retries and signing wrap base.
+func (c *Client) SetTransport(base http.RoundTripper) {
+	c.HTTPClient = &http.Client{
+		Transport: transport.New(base, transport.Config{
+			Limiter: transport.NewLimiter(map[string]transport.Limit{"": rateLimit}, nil),
+			Sign:    c.sign,
+		}),
+	}
+}
+
+// sign signs every attempt of a request with a fresh JWT.
+func (c *Client) sign(req *http.Request) error {
+	uri := fmt.Sprintf("%s %s%s", req.Method, req.URL.Host, req.URL.RequestURI())
+	jwt, err := coinbasev3.BuildJWT(uri, c.Key, c.Secret)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", jwt))
+	return nil
+}
+
+// Request sends a request to the v2 API and decodes its response into result. Cancelling ctx aborts the request and its
+// retries.
+func (c *Client) Request(ctx context.Context, method string, url string,
 	params, result interface{}) (res *http.Response, err error) {
 	var data []byte
 	body := bytes.NewReader(make([]byte, 0))
@@ -42,19 +73,15 @@ func (c *Client) Request(method string, url string,
 	}
 
 	fullURL := fmt.Sprintf("%s%s", c.BaseURL, url)
-	req, err := http.NewRequest(method, fullURL, body)
+	req, err := http.NewRequestWithContext(ctx, method, fullURL, body)
 	if err != nil {
 		return res, err
 	}
 
-	uri := fmt.Sprintf("%s %s/v2%s", method, req.Host, url)
-	jwt, err := coinbasev3.BuildJWT(uri, c.Key, c.Secret)
-	if err != nil {
-		return res, err
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{Transport: transport.New(nil, transport.Config{Sign: c.sign})}
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", jwt))
-
-	client := http.Client{}
 	res, err = client.Do(req)
 	if err != nil {
 		return res, err
@@ -1,6 +1,9 @@
 package coinbase
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 type FiatCurrencies struct {
 	Data []struct {
@@ -10,10 +13,10 @@ type FiatCurrencies struct {
 	} `json:"data"`
 }
 
-func (c *Client) Currencies(id string) (FiatCurrencies, error) {
+func (c *Client) Currencies(ctx context.Context, id string) (FiatCurrencies, error) {
 	var response FiatCurrencies
 
-	_, err := c.Request("GET", fmt.Sprintf("/currencies"), nil, &response)
+	_, err := c.Request(ctx, "GET", fmt.Sprintf("/currencies"), nil, &response)
 
 	return response, err
 }
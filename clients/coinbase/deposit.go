@@ -1,6 +1,7 @@
 package coinbase
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -29,17 +30,17 @@ type DepositResponse struct {
 	Data Deposit `json:"transfer"`
 }
 
-func (c *Client) ListPaymentMethods() ([]PaymentMethod, error) {
+func (c *Client) ListPaymentMethods(ctx context.Context) ([]PaymentMethod, error) {
 	paymentMethods := ListPaymentMethod{}
 
-	_, err := c.Request("GET", "/payment-methods", nil, &paymentMethods)
+	_, err := c.Request(ctx, "GET", "/payment-methods", nil, &paymentMethods)
 	return paymentMethods.Data, err
 }
 
-func (c *Client) Deposit(accountId string, deposit DepositParams) (DepositResponse, error) {
+func (c *Client) Deposit(ctx context.Context, accountId string, deposit DepositParams) (DepositResponse, error) {
 	response := DepositResponse{}
 
-	_, err := c.Request("POST", fmt.Sprintf("/accounts/%s/deposits", accountId), deposit, &response)
+	_, err := c.Request(ctx, "POST", fmt.Sprintf("/accounts/%s/deposits", accountId), deposit, &response)
 	return response, err
 }
 
@@ -67,9 +68,9 @@ type Amount struct {
 	Currency string  `json:"currency"`
 }
 
-func (c *Client) ListDeposits(id string) ([]Deposit, error) {
+func (c *Client) ListDeposits(ctx context.Context, id string) ([]Deposit, error) {
 	var response ListDeposits
-	_, err := c.Request("GET", fmt.Sprintf("/accounts/%s/deposits", id), nil, &response)
+	_, err := c.Request(ctx, "GET", fmt.Sprintf("/accounts/%s/deposits", id), nil, &response)
 
 	return response.Data, err
 }
@@ -1,8 +1,11 @@
 package coinbasev3
 
 import (
+	"context"
+	"fmt"
 	"github.com/coinbase-samples/advanced-trade-sdk-go/accounts"
 	"github.com/coinbase-samples/advanced-trade-sdk-go/model"
+	"strconv"
 	"time"
 )
 
@@ -32,7 +35,7 @@ type AccountHold struct {
 }
 
 // ListAccounts gets a list of authenticated accounts for the current user.
-func (c *ApiClient) ListAccounts(limit int, cursor string) (*accounts.ListAccountsResponse, error) {
+func (c *ApiClient) ListAccounts(ctx context.Context, limit int, cursor string) (*accounts.ListAccountsResponse, error) {
 	// A pagination limit with default of 49 and maximum of 250.
 	if limit < 49 {
 		limit = 49
@@ -43,10 +46,10 @@ func (c *ApiClient) ListAccounts(limit int, cursor string) (*accounts.ListAccoun
 
 	accountService := accounts.NewAccountsService(c.restClient)
 	//resp, err := c.restClient.R().SetSuccessResult(&data).Get(u)
-	resp, err := accountService.ListAccounts(nil, &accounts.ListAccountsRequest{
+	resp, err := accountService.ListAccounts(ctx, &accounts.ListAccountsRequest{
 		Pagination: &model.PaginationParams{
 			Cursor: cursor,
-			Limit:  string(limit),
+			Limit:  strconv.Itoa(limit),
 		},
 	})
 	if err != nil {
@@ -56,6 +59,21 @@ func (c *ApiClient) ListAccounts(limit int, cursor string) (*accounts.ListAccoun
 	return resp, nil
 }
 
+// listAccounts gets a page of accounts. Unlike ListAccounts it decodes has_next and cursor, which the SDK response
+// leaves out.
+func (c *ApiClient) listAccounts(ctx context.Context, limit int, cursor string) (ListAccountsData, error) {
+	q := fmt.Sprintf("?limit=%d", limit)
+	if cursor != "" {
+		q += fmt.Sprintf("&cursor=%s", cursor)
+	}
+	u := c.makeV3Url(fmt.Sprintf("/brokerage/accounts%s", q))
+	var data ListAccountsData
+	if err := c.get(ctx, u, &data); err != nil {
+		return data, err
+	}
+	return data, nil
+}
+
 type ListAccountsData struct {
 	Accounts []Account `json:"accounts"`
 	HasNext  bool      `json:"has_next"`
@@ -64,7 +82,7 @@ type ListAccountsData struct {
 }
 
 // GetAccount get a list of information about an account, given an account UUID.
-func (c *ApiClient) GetAccount(uuid string) (*model.Account, error) {
+func (c *ApiClient) GetAccount(ctx context.Context, uuid string) (*model.Account, error) {
 	//u := fmt.Sprintf("https://api.coinbase.com/api/v3/brokerage/accounts/%s", uuid)
 
 	/*var data GetAccountData
@@ -78,7 +96,7 @@ func (c *ApiClient) GetAccount(uuid string) (*model.Account, error) {
 	}*/
 	client := c.GetClient()
 	accountService := accounts.NewAccountsService(client)
-	data, err := accountService.GetAccount(nil, &accounts.GetAccountRequest{
+	data, err := accountService.GetAccount(ctx, &accounts.GetAccountRequest{
 		AccountUuid: uuid,
 	})
 	if err != nil {
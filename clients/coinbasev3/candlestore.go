@@ -0,0 +1,483 @@
+package coinbasev3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const defaultCandlePageSize = 300 // candles per GetProductCandles request, the API allows 350
+
+var (
+	ErrUnknownGranularity = fmt.Errorf("unknown granularity")
+)
+
+// granularities are the candle durations supported by GetProductCandles.
+var granularities = map[Granularity]time.Duration{
+	GranularityOneMin:     time.Minute,
+	GranularityFiveMin:    5 * time.Minute,
+	GranularityFifteenMin: 15 * time.Minute,
+	GranularityThirtyMin:  30 * time.Minute,
+	GranularityOneHour:    time.Hour,
+	GranularityTwoHour:    2 * time.Hour,
+	GranularitySixHour:    6 * time.Hour,
+	GranularityOneDay:     24 * time.Hour,
+}
+
+// Duration returns the duration of a candle, 0 for unknown granularities.
+func (g Granularity) Duration() time.Duration {
+	return granularities[g]
+}
+
+// CandleGetter fetches candles to backfill the store. It is implemented by ApiClient.
+type CandleGetter interface {
+	GetProductCandles(ctx context.Context, productId, start, end string, granularity Granularity) ([]ProductCandles, error)
+}
+
+// CandleStoreConfig is the configuration struct for creating a new candle store.
+type CandleStoreConfig struct {
+	Dir      string      // optional. directory the candles are persisted in, kept in memory only when empty
+	PageSize int         // optional. defaults to 300 candles per request
+	OnError  func(error) // optional. called with the errors Run continues after
+}
+
+// OHLC is a candle with parsed prices, Start is in UTC.
+type OHLC struct {
+	Start  time.Time       `json:"start"`
+	Open   decimal.Decimal `json:"open"`
+	High   decimal.Decimal `json:"high"`
+	Low    decimal.Decimal `json:"low"`
+	Close  decimal.Decimal `json:"close"`
+	Volume decimal.Decimal `json:"volume"`
+}
+
+// CandleStore caches the candles of products by granularity. Candles missing from a requested range are backfilled
+// with GetProductCandles, live candles of the candles channel are merged in. With a directory the candles are
+// persisted in a file per product and granularity and loaded on first use.
+type CandleStore struct {
+	api      CandleGetter
+	dir      string
+	pageSize int
+	onError  func(error)
+
+	mu     sync.Mutex
+	series map[string]*candleSeries // by file name
+}
+
+// candleSeries are the candles of a product and granularity. Covered are the backfilled ranges, periods in them
+// without a candle had no trades.
+type candleSeries struct {
+	Candles map[int64]OHLC `json:"candles"` // by start unix time
+	Covered []timeRange    `json:"covered"` // sorted and merged
+	dirty   bool
+}
+
+type timeRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// NewCandleStore creates a candle store.
+func NewCandleStore(api CandleGetter, cfg CandleStoreConfig) *CandleStore {
+	if cfg.PageSize <= 0 {
+		cfg.PageSize = defaultCandlePageSize
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(error) {}
+	}
+
+	return &CandleStore{
+		api:      api,
+		dir:      cfg.Dir,
+		pageSize: cfg.PageSize,
+		onError:  cfg.OnError,
+		series:   map[string]*candleSeries{},
+	}
+}
+
+// Candles returns the candles starting in [start, end), oldest first. Missing ranges are backfilled first.
+func (s *CandleStore) Candles(ctx context.Context, productId string, granularity Granularity, start, end time.Time) ([]OHLC, error) {
+	d := granularity.Duration()
+	if d == 0 {
+		return nil, fmt.Errorf("%w %s", ErrUnknownGranularity, granularity)
+	}
+
+	if err := s.Backfill(ctx, productId, granularity, start, end); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	series, err := s.load(productId, granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	candles := []OHLC{}
+	for _, c := range series.Candles {
+		if !c.Start.Before(start) && c.Start.Before(end) {
+			candles = append(candles, c)
+		}
+	}
+	sortCandles(candles)
+	return candles, nil
+}
+
+// Backfill fetches the candles of [start, end) which are not in the store yet and persists them. The range is
+// widened to whole candles and ends with the current period, whose unfinished candle is fetched again every time.
+func (s *CandleStore) Backfill(ctx context.Context, productId string, granularity Granularity, start, end time.Time) error {
+	d := granularity.Duration()
+	if d == 0 {
+		return fmt.Errorf("%w %s", ErrUnknownGranularity, granularity)
+	}
+
+	// candles of the current period are unfinished, later ones do not exist yet
+	complete := time.Now().UTC().Truncate(d)
+	start = start.UTC().Truncate(d)
+	end = minTime(ceilTime(end.UTC(), d), complete.Add(d))
+
+	s.mu.Lock()
+	series, err := s.load(productId, granularity)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	missing := series.missing(start, end)
+	s.mu.Unlock()
+
+	page := time.Duration(s.pageSize) * d
+	for _, r := range missing {
+		for from := r.Start; from.Before(r.End); from = from.Add(page) {
+			to := from.Add(page)
+			if to.After(r.End) {
+				to = r.End
+			}
+
+			// the end is inclusive, a candle starting at to belongs to the next page
+			data, err := s.api.GetProductCandles(ctx, productId, unixString(from), unixString(to.Add(-time.Second)), granularity)
+			if err != nil {
+				return fmt.Errorf("candles %s %s: %w", productId, granularity, err)
+			}
+
+			candles := make([]OHLC, 0, len(data))
+			for _, c := range data {
+				candle, err := parseProductCandle(c)
+				if err != nil {
+					return err
+				}
+				candles = append(candles, candle)
+			}
+
+			s.mu.Lock()
+			for _, c := range candles {
+				series.Candles[c.Start.Unix()] = c
+			}
+			if covered := minTime(to, complete); covered.After(from) {
+				series.cover(from, covered)
+			}
+			series.dirty = true
+			s.mu.Unlock()
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+	return s.Save()
+}
+
+// Run merges the live candles of readCh until the context is cancelled or readCh is closed and saves the store
+// before it returns. Messages of other channels are ignored, errors are passed to OnError.
+func (s *CandleStore) Run(ctx context.Context, readCh <-chan []byte) error {
+	defer func() {
+		if err := s.Save(); err != nil {
+			s.onError(err)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-readCh:
+			if !ok {
+				return nil
+			}
+			if err := s.Apply(msg); err != nil {
+				s.onError(err)
+			}
+		}
+	}
+}
+
+// Apply decodes a websocket message and merges it when it is a candles channel message.
+func (s *CandleStore) Apply(msg []byte) error {
+	var evt Event
+	if err := json.Unmarshal(msg, &evt); err != nil {
+		return err
+	}
+	if !evt.IsCandlesEvent() {
+		return nil
+	}
+
+	candles, err := evt.GetCandlesEvent()
+	if err != nil {
+		return err
+	}
+	return s.ApplyCandlesEvent(candles)
+}
+
+// ApplyCandlesEvent merges the five minute candles of a candles channel event, it can be registered with
+// Dispatcher.OnCandles. A live candle completes the one before it, so the backfilled range is extended when it
+// reaches the previous candle. Call Save to persist the merged candles.
+func (s *CandleStore) ApplyCandlesEvent(evt CandlesEvent) error {
+	d := GranularityFiveMin.Duration()
+
+	for _, e := range evt.Events {
+		for _, c := range e.Candles {
+			candle, err := parseProductCandle(ProductCandles{
+				Start:  c.Start,
+				Low:    c.Low,
+				High:   c.High,
+				Open:   c.Open,
+				Close:  c.Close,
+				Volume: c.Volume,
+			})
+			if err != nil {
+				return err
+			}
+
+			s.mu.Lock()
+			series, err := s.load(c.ProductId, GranularityFiveMin)
+			if err != nil {
+				s.mu.Unlock()
+				return err
+			}
+			series.Candles[candle.Start.Unix()] = candle
+			previous := candle.Start.Add(-d)
+			if series.reaches(previous) {
+				series.cover(previous, candle.Start)
+			}
+			series.dirty = true
+			s.mu.Unlock()
+		}
+	}
+	return nil
+}
+
+// Save persists the changed series when the store has a directory.
+func (s *CandleStore) Save() error {
+	if s.dir == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+
+	for name, series := range s.series {
+		if !series.dirty {
+			continue
+		}
+
+		data, err := json.Marshal(series)
+		if err != nil {
+			return err
+		}
+
+		if err := writeFile(s.dir, name, data); err != nil {
+			return err
+		}
+		series.dirty = false
+	}
+	return nil
+}
+
+// writeFile writes data to a temporary file next to name and renames it, a crash does not leave a truncated file. The
+// temporary file is unique, so stores sharing dir, e.g. of several profiles, do not write the same one.
+func writeFile(dir, name string, data []byte) error {
+	f, err := os.CreateTemp(dir, name+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// load returns the series of a product and granularity, read from its file on first use. The caller holds mu.
+func (s *CandleStore) load(productId string, granularity Granularity) (*candleSeries, error) {
+	name := fmt.Sprintf("%s_%s.json", productId, granularity)
+	if series, ok := s.series[name]; ok {
+		return series, nil
+	}
+
+	series := &candleSeries{Candles: map[int64]OHLC{}}
+	if s.dir != "" {
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, err
+		default:
+			if err := json.Unmarshal(data, series); err != nil {
+				return nil, fmt.Errorf("candle cache %s: %w", name, err)
+			}
+			if series.Candles == nil {
+				series.Candles = map[int64]OHLC{}
+			}
+		}
+	}
+
+	s.series[name] = series
+	return series, nil
+}
+
+// missing returns the parts of [start, end) which are not covered.
+func (c *candleSeries) missing(start, end time.Time) []timeRange {
+	missing := []timeRange{}
+	for _, r := range c.Covered {
+		if !r.End.After(start) {
+			continue
+		}
+		if !r.Start.Before(end) {
+			break
+		}
+		if r.Start.After(start) {
+			missing = append(missing, timeRange{Start: start, End: r.Start})
+		}
+		start = maxTime(start, r.End)
+	}
+	if start.Before(end) {
+		missing = append(missing, timeRange{Start: start, End: end})
+	}
+	return missing
+}
+
+// reaches returns true when t is in a covered range or at its end.
+func (c *candleSeries) reaches(t time.Time) bool {
+	for _, r := range c.Covered {
+		if !t.Before(r.Start) && !t.After(r.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// cover adds [start, end) to the covered ranges, merging overlapping and adjacent ones.
+func (c *candleSeries) cover(start, end time.Time) {
+	ranges := append(c.Covered, timeRange{Start: start, End: end})
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start.Before(ranges[j].Start) })
+
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start.After(last.End) {
+			merged = append(merged, r)
+			continue
+		}
+		last.End = maxTime(last.End, r.End)
+	}
+	c.Covered = merged
+}
+
+// Resample aggregates candles into candles of period, e.g. hourly candles into daily or weekly ones. Periods are
+// aligned to the zero time in UTC, so weeks start on Mondays. The result is oldest first.
+func Resample(candles []OHLC, period time.Duration) []OHLC {
+	sorted := append([]OHLC(nil), candles...)
+	sortCandles(sorted)
+
+	resampled := []OHLC{}
+	for _, c := range sorted {
+		start := c.Start.UTC().Truncate(period)
+
+		if n := len(resampled); n > 0 && resampled[n-1].Start.Equal(start) {
+			last := &resampled[n-1]
+			last.High = decimal.Max(last.High, c.High)
+			last.Low = decimal.Min(last.Low, c.Low)
+			last.Close = c.Close
+			last.Volume = last.Volume.Add(c.Volume)
+			continue
+		}
+
+		c.Start = start
+		resampled = append(resampled, c)
+	}
+	return resampled
+}
+
+func parseProductCandle(c ProductCandles) (OHLC, error) {
+	var candle OHLC
+
+	start, err := strconv.ParseInt(c.Start, 10, 64)
+	if err != nil {
+		return candle, err
+	}
+	candle.Start = time.Unix(start, 0).UTC()
+
+	err = parseDecimals([]decimalValue{
+		{c.Open, &candle.Open},
+		{c.High, &candle.High},
+		{c.Low, &candle.Low},
+		{c.Close, &candle.Close},
+		{c.Volume, &candle.Volume},
+	})
+	return candle, err
+}
+
+func sortCandles(candles []OHLC) {
+	sort.Slice(candles, func(i, j int) bool { return candles[i].Start.Before(candles[j].Start) })
+}
+
+func unixString(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+// ceilTime rounds t up to a multiple of d.
+func ceilTime(t time.Time, d time.Duration) time.Time {
+	truncated := t.Truncate(d)
+	if truncated.Equal(t) {
+		return t
+	}
+	return truncated.Add(d)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
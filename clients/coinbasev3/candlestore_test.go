@@ -0,0 +1,221 @@
+package coinbasev3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// fakeCandles returns a candle for every period of the requested range, newest first like the API.
+type fakeCandles struct {
+	calls    int
+	maxPages int // candles of the largest page
+	err      error
+}
+
+func (f *fakeCandles) GetProductCandles(ctx context.Context, productId, start, end string, granularity Granularity) ([]ProductCandles, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	from, _ := strconv.ParseInt(start, 10, 64)
+	to, _ := strconv.ParseInt(end, 10, 64)
+	step := int64(granularity.Duration().Seconds())
+
+	candles := []ProductCandles{}
+	for t := from; t <= to; t += step {
+		price := strconv.FormatInt(t/step%100, 10)
+		candles = append([]ProductCandles{{Start: strconv.FormatInt(t, 10), Open: price, High: price, Low: price, Close: price, Volume: "1"}}, candles...)
+	}
+	if len(candles) > f.maxPages {
+		f.maxPages = len(candles)
+	}
+	return candles, nil
+}
+
+var candleEpoch = time.Date(2023, 3, 6, 0, 0, 0, 0, time.UTC) // a Monday
+
+func TestCandleStore_Backfill(t *testing.T) {
+	api := &fakeCandles{}
+	store := NewCandleStore(api, CandleStoreConfig{PageSize: 100})
+
+	end := candleEpoch.Add(10 * 24 * time.Hour)
+	candles, err := store.Candles(context.Background(), "BTC-USD", GranularityOneHour, candleEpoch, end)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(candles) != 240 || !candles[0].Start.Equal(candleEpoch) || !candles[239].Start.Equal(end.Add(-time.Hour)) {
+		t.Fatalf("Expected 240 candles oldest first, got %d", len(candles))
+	}
+	if api.calls != 3 || api.maxPages != 100 {
+		t.Errorf("Expected 3 pages of at most 100 candles, got %d pages of up to %d", api.calls, api.maxPages)
+	}
+
+	// the cached range is not fetched again, only the missing days
+	candles, err = store.Candles(context.Background(), "BTC-USD", GranularityOneHour, candleEpoch.Add(-24*time.Hour), candleEpoch.Add(24*time.Hour+30*time.Minute))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(candles) != 49 {
+		t.Errorf("Expected 49 candles, got %d", len(candles))
+	}
+	if api.calls != 4 {
+		t.Errorf("Expected 1 more request for the missing day, got %d", api.calls-3)
+	}
+
+	if _, err := store.Candles(context.Background(), "BTC-USD", GranularityOneHour, candleEpoch, end); err != nil || api.calls != 4 {
+		t.Errorf("Expected the range to be cached, got %d requests and %v", api.calls, err)
+	}
+}
+
+func TestCandleStore_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	api := &fakeCandles{}
+
+	store := NewCandleStore(api, CandleStoreConfig{Dir: dir})
+	if err := store.Backfill(context.Background(), "ETH-USD", GranularityOneDay, candleEpoch, candleEpoch.Add(30*24*time.Hour)); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	reloaded := NewCandleStore(api, CandleStoreConfig{Dir: dir})
+	candles, err := reloaded.Candles(context.Background(), "ETH-USD", GranularityOneDay, candleEpoch, candleEpoch.Add(30*24*time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(candles) != 30 || api.calls != 1 {
+		t.Errorf("Expected 30 candles from the file, got %d with %d requests", len(candles), api.calls)
+	}
+	if candles[1].Close.String() != fmt.Sprint(candleEpoch.Unix()/86400%100+1) {
+		t.Errorf("Expected the saved close, got %s", candles[1].Close)
+	}
+}
+
+func TestCandleStore_SharedDir(t *testing.T) {
+	dir := t.TempDir()
+	end := candleEpoch.Add(30 * 24 * time.Hour)
+
+	// the stores of two profiles save the same product at once
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store := NewCandleStore(&fakeCandles{}, CandleStoreConfig{Dir: dir})
+			errs <- store.Backfill(context.Background(), "ETH-USD", GranularityOneDay, candleEpoch, end)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the cache file, got %d files", len(entries))
+	}
+	api := &fakeCandles{}
+	candles, err := NewCandleStore(api, CandleStoreConfig{Dir: dir}).Candles(context.Background(), "ETH-USD", GranularityOneDay, candleEpoch, end)
+	if err != nil || len(candles) != 30 || api.calls != 0 {
+		t.Errorf("Expected 30 candles from the file, got %d with %d requests and %v", len(candles), api.calls, err)
+	}
+}
+
+func TestCandleStore_Errors(t *testing.T) {
+	store := NewCandleStore(&fakeCandles{err: errors.New("rate limited")}, CandleStoreConfig{})
+
+	if _, err := store.Candles(context.Background(), "BTC-USD", GranularityOneHour, candleEpoch, candleEpoch.Add(time.Hour)); err == nil {
+		t.Errorf("Expected the request error")
+	}
+	if _, err := store.Candles(context.Background(), "BTC-USD", "ONE_WEEK", candleEpoch, candleEpoch.Add(time.Hour)); !errors.Is(err, ErrUnknownGranularity) {
+		t.Errorf("Expected %s, got %v", ErrUnknownGranularity, err)
+	}
+}
+
+func candlesMessage(productId string, start time.Time, close string) []byte {
+	return []byte(fmt.Sprintf(`{"channel":"candles","client_id":"","timestamp":"2023-06-09T20:19:35.39625135Z","sequence_num":0,"events":[{"type":"update","candles":[{"start":"%d","high":"%s","low":"%s","open":"%s","close":"%s","volume":"2","product_id":"%s"}]}]}`, start.Unix(), close, close, close, close, productId))
+}
+
+func TestCandleStore_LiveCandles(t *testing.T) {
+	api := &fakeCandles{}
+	store := NewCandleStore(api, CandleStoreConfig{})
+
+	end := candleEpoch.Add(time.Hour)
+	if err := store.Backfill(context.Background(), "BTC-USD", GranularityFiveMin, candleEpoch, end); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	// the candle after the backfilled range completes it, a later one leaves a gap
+	for _, msg := range [][]byte{
+		candlesMessage("BTC-USD", end, "101"),
+		candlesMessage("BTC-USD", end, "102"),
+		candlesMessage("BTC-USD", end.Add(5*time.Minute), "103"),
+		candlesMessage("BTC-USD", end.Add(20*time.Minute), "104"),
+		heartbeatMessage(1),
+	} {
+		if err := store.Apply(msg); err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+	}
+
+	candles, err := store.Candles(context.Background(), "BTC-USD", GranularityFiveMin, candleEpoch, end.Add(5*time.Minute))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if api.calls != 1 {
+		t.Errorf("Expected the live candles to extend the cached range, got %d requests", api.calls)
+	}
+	if len(candles) != 13 || candles[12].Close.String() != "102" {
+		t.Errorf("Expected the live candle to be merged, got %d candles", len(candles))
+	}
+
+	if _, err := store.Candles(context.Background(), "BTC-USD", GranularityFiveMin, candleEpoch, end.Add(25*time.Minute)); err != nil || api.calls != 2 {
+		t.Errorf("Expected the gap to be backfilled, got %d requests and %v", api.calls, err)
+	}
+}
+
+func TestResample(t *testing.T) {
+	hourly := []OHLC{}
+	for h := 0; h < 14*24; h++ {
+		price := decimalFromInt(int64(h % 24))
+		hourly = append(hourly, OHLC{
+			Start:  candleEpoch.Add(time.Duration(h) * time.Hour),
+			Open:   price,
+			High:   price.Add(decimalFromInt(1)),
+			Low:    price.Sub(decimalFromInt(1)),
+			Close:  price,
+			Volume: decimalFromInt(1),
+		})
+	}
+	// the order of the input does not matter
+	hourly[0], hourly[5] = hourly[5], hourly[0]
+
+	daily := Resample(hourly, 24*time.Hour)
+	if len(daily) != 14 {
+		t.Fatalf("Expected 14 daily candles, got %d", len(daily))
+	}
+	d := daily[1]
+	if !d.Start.Equal(candleEpoch.Add(24*time.Hour)) || d.Open.String() != "0" || d.Close.String() != "23" || d.High.String() != "24" || d.Low.String() != "-1" || d.Volume.String() != "24" {
+		t.Errorf("Expected the daily candle of the hours, got %+v", d)
+	}
+
+	weekly := Resample(daily, 7*24*time.Hour)
+	if len(weekly) != 2 || !weekly[0].Start.Equal(candleEpoch) || weekly[1].Volume.String() != "168" {
+		t.Errorf("Expected 2 weekly candles starting on Mondays, got %+v", weekly)
+	}
+}
+
+func decimalFromInt(i int64) decimal.Decimal {
+	return decimal.NewFromInt(i)
+}
@@ -1,13 +1,15 @@
 package coinbasev3
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
 	"github.com/coinbase-samples/advanced-trade-sdk-go/client"
 	"github.com/coinbase-samples/advanced-trade-sdk-go/credentials"
 	"github.com/imroc/req/v3"
+	"github.com/sberserker/dcagdax/clients/transport"
+	"github.com/sberserker/dcagdax/metrics"
 	"log"
+	"net/http"
 	"net/url"
 	"strings"
 	"time"
@@ -17,9 +19,10 @@ var (
 	ErrFailedToUnmarshal = fmt.Errorf("failed to unmarshal response")
 )
 
+// HttpClient makes the requests of ApiClient. The context cancels the request and its deadline bounds it.
 type HttpClient interface {
-	Get(url string) (*req.Response, error)
-	Post(url string, data []byte) (*req.Response, error)
+	Get(ctx context.Context, url string) (*req.Response, error)
+	Post(ctx context.Context, url string, data []byte) (*req.Response, error)
 	GetClient() *req.Client
 }
 
@@ -34,7 +37,7 @@ type ApiClient struct {
 	baseExchangeUrl string
 }
 
-// NewApiClient creates a new Coinbase API restClient. The API key and secret key are used to sign requests. The default timeout is 10 seconds. The default retry count is 3. The default retry backoff interval is 1 second to 5 seconds.
+// NewApiClient creates a new Coinbase API restClient. The API key and secret key are used to sign requests. The default timeout is 10 seconds. Requests are rate limited to the Advanced Trade API limits, failed requests are retried 3 times with a backoff of 0.5 to 10 seconds.
 func NewApiClient(apiKey string, secretKey string, portfolioId string, clients ...HttpClient) *ApiClient {
 	credentials := &credentials.Credentials{
 		AccessKey:     apiKey,
@@ -47,6 +50,9 @@ func NewApiClient(apiKey string, secretKey string, portfolioId string, clients .
 		log.Fatalf("unable to load default http restClient: %v", err)
 	}
 
+	// the rest client signs its requests itself
+	limiter := newLimiter()
+	httpClient.Transport = newTransport(httpClient.Transport, limiter, nil)
 	restClient := client.NewRestClient(credentials, httpClient)
 
 	if len(clients) > 0 {
@@ -54,94 +60,133 @@ func NewApiClient(apiKey string, secretKey string, portfolioId string, clients .
 			apiKey:     apiKey,
 			secretKey:  secretKey,
 			restClient: restClient,
-			client:     newClient(apiKey, secretKey),
+			client:     newClient(apiKey, secretKey, limiter, nil),
 			httpClient: clients[0],
 		}
 		ac.setBaseUrls()
 		return ac
 	}
 
+	reqClient := newClient(apiKey, secretKey, limiter, nil)
 	ac := &ApiClient{
 		apiKey:     apiKey,
 		secretKey:  secretKey,
 		restClient: restClient,
-		httpClient: &ReqClient{client: newClient(apiKey, secretKey)},
+		client:     reqClient,
+		httpClient: &ReqClient{client: reqClient},
 	}
 	ac.setBaseUrls()
 	return ac
 }
 
-func newClient(apiKey, secretKey string) *req.Client {
+// newClient creates the req client of an ApiClient, sending its requests through base instead of its own transport
+// when base is not nil.
+func newClient(apiKey, secretKey string, limiter *transport.Limiter, base http.RoundTripper) *req.Client {
 	client := req.C().
 		SetTimeout(time.Second * 10).
 		SetUserAgent("GoCoinbaseV3/1.0.0")
 
-	// TODO: figure out how to do this where we can use PathParam, QueryParam, etc.
-	client.OnBeforeRequest(func(client *req.Client, req *req.Request) error {
-		// create a secret key from: `timestamp + method + requestPath + body`
-		path := ""
-		host := ""
-		if req.RawURL != "" {
-			u, err := url.Parse(req.RawURL)
-			if err != nil {
-				return err
-			}
-			path = u.Path
-			host = u.Host
-		} else {
-			return fmt.Errorf("no path found")
+	// every attempt is signed in the transport, a retry needs a fresh JWT
+	client.GetTransport().WrapRoundTrip(func(rt http.RoundTripper) http.RoundTripper {
+		if base != nil {
+			rt = base
 		}
-
-		uri := fmt.Sprintf("%s %s%s", req.Method, host, path)
-		jwt, err := BuildJWT(uri, apiKey, secretKey)
-		if err != nil {
-			return err
-		}
-		client.Headers.Set("Authorization", fmt.Sprintf("Bearer %s", jwt))
-		return nil
+		return newTransport(rt, limiter, jwtSigner(apiKey, secretKey))
 	})
 
 	return client
 }
+
+// SetTransport sends the requests of the client through base, e.g. a transport.Recorder replaying fixtures. The rate
+// limits, retries and signing still apply, they wrap base.
+func (c *ApiClient) SetTransport(base http.RoundTripper) {
+	limiter := newLimiter()
+	c.client = newClient(c.apiKey, c.secretKey, limiter, base)
+	if _, ok := c.httpClient.(*ReqClient); ok {
+		c.httpClient = &ReqClient{client: c.client}
+	}
+	c.restClient.HttpClient().Transport = newTransport(base, limiter, nil)
+}
+
 func (c *ApiClient) GetClient() client.RestClient {
 	return c.restClient
 }
-func (c *ApiClient) get(url string, out interface{}) ([]byte, error) {
-	resp, err := c.httpClient.Get(url)
+
+// get makes a GET request and unmarshals the response into out. A non-success response is returned as ResponseError.
+func (c *ApiClient) get(ctx context.Context, url string, out interface{}) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveRequest("coinbase", endpoint(url), start, err)
+	}()
+
+	resp, err := c.httpClient.Get(ctx, url)
 	if err != nil {
-		return resp.Bytes(), err
+		return err
 	}
+	return unmarshalResponse(resp, out)
+}
 
-	if !resp.IsSuccessState() {
-		return resp.Bytes(), ErrFailedToUnmarshal
+// post makes a POST request and unmarshals the response into out. A non-success response is returned as ResponseError.
+func (c *ApiClient) post(ctx context.Context, url string, data []byte, out interface{}) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveRequest("coinbase", endpoint(url), start, err)
+	}()
+
+	resp, err := c.httpClient.Post(ctx, url, data)
+	if err != nil {
+		return err
 	}
+	return unmarshalResponse(resp, out)
+}
 
-	// data := string(resp.Bytes())
-	// println(data)
+func unmarshalResponse(resp *req.Response, out interface{}) error {
+	if !resp.IsSuccessState() {
+		return newResponseError(resp)
+	}
 
-	err = resp.Unmarshal(&out)
-	if err != nil {
-		return resp.Bytes(), err
+	if err := resp.Unmarshal(&out); err != nil {
+		return fmt.Errorf("%w: %v", ErrFailedToUnmarshal, err)
 	}
+	return nil
+}
 
-	return resp.Bytes(), nil
+// idSegments are the path segments followed by an id, mapped to the segments which are no ids, e.g.
+// /brokerage/orders/historical/fills.
+var idSegments = map[string][]string{
+	"accounts":        nil,
+	"historical":      {"fills", "batch"},
+	"payment_methods": nil,
+	"portfolios":      nil,
+	"products":        nil,
 }
 
-func (c *ApiClient) post(url string, data []byte, out interface{}) ([]byte, error) {
-	resp, err := c.httpClient.Post(url, data)
+// endpoint returns the path template of url for metric labels, ids are replaced with :id so every order or product
+// does not create a time series of its own.
+func endpoint(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
 	if err != nil {
-		return resp.Bytes(), err
+		return "unknown"
 	}
 
-	if !resp.IsSuccessState() {
-		return resp.Bytes(), ErrFailedToUnmarshal
+	segments := strings.Split(u.Path, "/")
+	for i := 1; i < len(segments); i++ {
+		names, found := idSegments[segments[i-1]]
+		if !found || segments[i] == "" || contains(names, segments[i]) {
+			continue
+		}
+		segments[i] = ":id"
 	}
+	return strings.Join(segments, "/")
+}
 
-	err = resp.Unmarshal(&out)
-	if err != nil {
-		return resp.Bytes(), err
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
 	}
-	return resp.Bytes(), nil
+	return false
 }
 
 func (c *ApiClient) setBaseUrls() {
@@ -204,8 +249,8 @@ func (c *ReqClient) GetClient() *req.Client {
 }
 
 // Get makes a GET request to the given URL.
-func (c *ReqClient) Get(url string) (*req.Response, error) {
-	resp, err := c.client.R().Get(url)
+func (c *ReqClient) Get(ctx context.Context, url string) (*req.Response, error) {
+	resp, err := c.client.R().SetContext(ctx).Get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -214,75 +259,11 @@ func (c *ReqClient) Get(url string) (*req.Response, error) {
 }
 
 // Post makes a POST request to the given URL.
-func (c *ReqClient) Post(url string, data []byte) (*req.Response, error) {
-	resp, err := c.client.R().SetBody(data).Post(url)
+func (c *ReqClient) Post(ctx context.Context, url string, data []byte) (*req.Response, error) {
+	resp, err := c.client.R().SetContext(ctx).SetBody(data).Post(url)
 	if err != nil {
 		return nil, err
 	}
 
 	return resp, nil
 }
-
-type ResponseError struct {
-	Message       string        `json:"message"`
-	CoinbaseError CoinbaseError `json:"coinbase_error"`
-}
-
-// Implement the Error() method for MyCustomError.
-// This method makes MyCustomError satisfy the error interface.
-func (e ResponseError) Error() string {
-	return e.Message
-}
-
-func newResponseError(res []byte) error {
-	resErr := newCoinbaseError(res)
-	return ResponseError{
-		Message:       resErr.Message,
-		CoinbaseError: resErr.CoinbaseError,
-	}
-}
-
-type CoinbaseError struct {
-	Error        string       `json:"error"`
-	Code         string       `json:"code"`
-	Message      string       `json:"message"`
-	ErrorDetails string       `json:"error_details"`
-	Details      ErrorDetails `json:"details"`
-}
-
-func newCoinbaseError(res []byte) ResponseError {
-	var errRes CoinbaseError
-	err := json.Unmarshal(res, &errRes)
-	if err != nil {
-		return ResponseError{
-			Message:       err.Error(),
-			CoinbaseError: errRes,
-		}
-	}
-	return ResponseError{
-		Message:       errRes.Message,
-		CoinbaseError: errRes,
-	}
-}
-
-type ErrorDetail struct {
-	TypeUrl string `json:"type_url"`
-	Value   string `json:"value"`
-}
-
-type ErrorDetails []ErrorDetail
-
-// UnmarshalJSON implements the json.Unmarshaler interface. Required because Coinbase returns an array of error details or a single error detail object.
-func (ed *ErrorDetails) UnmarshalJSON(data []byte) error {
-	var details []ErrorDetail
-	if err := json.Unmarshal(data, &details); err == nil {
-		*ed = details
-		return nil
-	}
-	var detail ErrorDetail
-	if err := json.Unmarshal(data, &detail); err == nil {
-		*ed = ErrorDetails{detail}
-		return nil
-	}
-	return errors.New("error details should be an array or a single object")
-}
@@ -1,6 +1,13 @@
 package coinbasev3
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
 
 func TestNewApiClient(t *testing.T) {
 	api := NewApiClient("api_key", "secret_key", "portfolio_id")
@@ -112,3 +119,47 @@ func TestApiClient_SetSandboxUrls(t *testing.T) {
 		}
 	}
 }
+
+func TestApiClient_Context(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	api := NewApiClient("api_key", testSecretKey(t), "portfolio_id")
+	api.SetBaseUrlV3(srv.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := api.GetListOrders(ctx, ListOrdersQuery{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the request to be aborted at the deadline, took %s", elapsed)
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	tests := map[string]string{
+		"https://api.coinbase.com/api/v3/brokerage/orders/historical/0b8cf6a3-4d1c":               "/api/v3/brokerage/orders/historical/:id",
+		"https://api.coinbase.com/api/v3/brokerage/orders/historical/fills?product_id=BTC-USD":    "/api/v3/brokerage/orders/historical/fills",
+		"https://api.coinbase.com/api/v3/brokerage/orders/historical/batch?limit=1":               "/api/v3/brokerage/orders/historical/batch",
+		"https://api.coinbase.com/api/v3/brokerage/products/BTC-USD/candles?granularity=ONE_HOUR": "/api/v3/brokerage/products/:id/candles",
+		"https://api.coinbase.com/api/v3/brokerage/products/ETH-USD":                              "/api/v3/brokerage/products/:id",
+		"https://api.coinbase.com/api/v3/brokerage/orders":                                        "/api/v3/brokerage/orders",
+		"https://api.exchange.coinbase.com/products":                                              "/products",
+	}
+	for url, expected := range tests {
+		if got := endpoint(url); got != expected {
+			t.Errorf("Expected %s, got %s", expected, got)
+		}
+	}
+}
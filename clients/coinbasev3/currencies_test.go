@@ -1,29 +1,39 @@
 package coinbasev3
 
 import (
+	"context"
 	"testing"
 )
 
 func TestApiClient_GetFiatCurrencies(t *testing.T) {
-	api := NewApiClient("api_key", "secret_key", "portfolio_id")
-	_, err := api.GetFiatCurrencies()
+	api := replayClient(t, "fiat_currencies")
+	fiats, err := api.GetFiatCurrencies(context.Background())
 	if err != nil {
-		t.Errorf("Expected no error, got %s", err)
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(fiats.Data) != 3 || fiats.Data[2].Id != "USD" {
+		t.Errorf("Expected 3 currencies ending with USD, got %v", fiats.Data)
 	}
 }
 
 func TestApiClient_GetCurrencies(t *testing.T) {
-	api := NewApiClient("api_key", "secret_key", "portfolio_id")
-	_, err := api.GetCurrencies()
+	api := replayClient(t, "currencies")
+	curr, err := api.GetCurrencies(context.Background())
 	if err != nil {
-		t.Errorf("Expected no error, got %s", err)
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(curr.Data) != 2 || curr.Data[0].Code != "BTC" || curr.Data[0].Exponent != 8 {
+		t.Errorf("Expected BTC and ETH, got %v", curr.Data)
 	}
 }
 
 func TestApiClient_GetExchangeRates(t *testing.T) {
-	api := NewApiClient("api_key", "secret_key", "portfolio_id")
-	_, err := api.GetExchangeRates("BTC")
+	api := replayClient(t, "exchange_rates")
+	rates, err := api.GetExchangeRates(context.Background(), "BTC")
 	if err != nil {
-		t.Errorf("Expected no error, got %s", err)
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if rates.Data.Currency != "BTC" || rates.Data.Rates["USD"] != "37527.15" {
+		t.Errorf("Expected the BTC rates, got %v", rates.Data)
 	}
 }
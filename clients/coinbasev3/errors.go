@@ -0,0 +1,199 @@
+package coinbasev3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/imroc/req/v3"
+)
+
+// Common failures, compare a ResponseError with them using errors.Is.
+var (
+	ErrInsufficientFunds = fmt.Errorf("insufficient funds")
+	ErrInvalidProduct    = fmt.Errorf("invalid product")
+	ErrUnauthorized      = fmt.Errorf("unauthorized")
+	ErrRateLimited       = fmt.Errorf("rate limited")
+)
+
+// requestIdHeaders are the response headers carrying the id of a request, which Coinbase support asks for.
+var requestIdHeaders = []string{"X-Request-Id", "Cb-Request-Id"}
+
+// ResponseError is a failed request. StatusCode is 200 for orders Coinbase accepted the request of but failed to
+// place, see NewOrderError.
+type ResponseError struct {
+	StatusCode    int           `json:"status_code"`
+	Code          string        `json:"code"` // e.g. INVALID_ARGUMENT or INSUFFICIENT_FUND
+	Message       string        `json:"message"`
+	Details       ErrorDetails  `json:"details"`
+	RequestId     string        `json:"request_id"`
+	CoinbaseError CoinbaseError `json:"coinbase_error"`
+}
+
+// Error implements the error interface.
+func (e ResponseError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("coinbase: ")
+	if e.StatusCode != 0 {
+		sb.WriteString(strconv.Itoa(e.StatusCode))
+		sb.WriteString(" ")
+	}
+	if e.Code != "" {
+		sb.WriteString(e.Code)
+		sb.WriteString(" ")
+	}
+	sb.WriteString(e.Message)
+	if e.RequestId != "" {
+		sb.WriteString(" (request id ")
+		sb.WriteString(e.RequestId)
+		sb.WriteString(")")
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// Is reports whether the error is one of ErrInsufficientFunds, ErrInvalidProduct, ErrUnauthorized or ErrRateLimited.
+func (e ResponseError) Is(target error) bool {
+	switch target {
+	case ErrInsufficientFunds:
+		return e.hasCode("INSUFFICIENT_FUND", "INSUFFICIENT_FUNDS", "PREVIEW_INSUFFICIENT_FUND")
+	case ErrInvalidProduct:
+		return e.hasCode("INVALID_PRODUCT_ID", "PREVIEW_INVALID_PRODUCT_ID", "UNKNOWN_PRODUCT_ID") ||
+			((e.StatusCode == http.StatusNotFound || e.hasCode("NOT_FOUND", "INVALID_ARGUMENT")) &&
+				strings.Contains(strings.ToLower(e.Message), "product"))
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden ||
+			e.hasCode("UNAUTHENTICATED", "PERMISSION_DENIED", "UNAUTHORIZED")
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests || e.hasCode("RESOURCE_EXHAUSTED", "RATE_LIMIT_EXCEEDED")
+	}
+	return false
+}
+
+func (e ResponseError) hasCode(codes ...string) bool {
+	for _, code := range codes {
+		if strings.EqualFold(e.Code, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// newResponseError decodes the error of a non-success response.
+func newResponseError(resp *req.Response) error {
+	resErr := newCoinbaseError(resp.Bytes())
+	resErr.StatusCode = resp.StatusCode
+	for _, h := range requestIdHeaders {
+		if id := resp.Header.Get(h); id != "" {
+			resErr.RequestId = id
+			break
+		}
+	}
+	return resErr
+}
+
+// NewOrderError returns the failure of an order Coinbase accepted the request of but did not place.
+func NewOrderError(failureReason string, resp CreatOrderErrorResponse) error {
+	code := resp.Error
+	for _, reason := range []string{resp.NewOrderFailureReason, resp.PreviewFailureReason, failureReason} {
+		if code == "" || code == "UNKNOWN_FAILURE_REASON" {
+			code = reason
+		}
+	}
+
+	msg := resp.Message
+	if msg == "" {
+		msg = resp.ErrorDetails
+	}
+	if msg == "" {
+		msg = "order failed"
+	}
+
+	return ResponseError{
+		StatusCode: http.StatusOK,
+		Code:       code,
+		Message:    msg,
+		CoinbaseError: CoinbaseError{
+			Error:        resp.Error,
+			Message:      resp.Message,
+			ErrorDetails: resp.ErrorDetails,
+		},
+	}
+}
+
+type CoinbaseError struct {
+	Error        string       `json:"error"`
+	Code         ErrorCode    `json:"code"`
+	Message      string       `json:"message"`
+	ErrorDetails string       `json:"error_details"`
+	Details      ErrorDetails `json:"details"`
+}
+
+func newCoinbaseError(res []byte) ResponseError {
+	var errRes CoinbaseError
+	err := json.Unmarshal(res, &errRes)
+	if err != nil {
+		// not a json error, e.g. from a proxy
+		return ResponseError{
+			Message:       strings.TrimSpace(string(res)),
+			CoinbaseError: errRes,
+		}
+	}
+
+	code := errRes.Error
+	if code == "" {
+		code = string(errRes.Code)
+	}
+	msg := errRes.Message
+	if msg == "" {
+		msg = errRes.ErrorDetails
+	}
+	return ResponseError{
+		Code:          code,
+		Message:       msg,
+		Details:       errRes.Details,
+		CoinbaseError: errRes,
+	}
+}
+
+// ErrorCode is the code of a Coinbase error. The Advanced Trade API returns numeric gRPC codes, other APIs strings.
+type ErrorCode string
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (c *ErrorCode) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*c = ErrorCode(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return errors.New("error code should be a string or a number")
+	}
+	*c = ErrorCode(n.String())
+	return nil
+}
+
+type ErrorDetail struct {
+	TypeUrl string `json:"type_url"`
+	Value   string `json:"value"`
+}
+
+type ErrorDetails []ErrorDetail
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Required because Coinbase returns an array of error details or a single error detail object.
+func (ed *ErrorDetails) UnmarshalJSON(data []byte) error {
+	var details []ErrorDetail
+	if err := json.Unmarshal(data, &details); err == nil {
+		*ed = details
+		return nil
+	}
+	var detail ErrorDetail
+	if err := json.Unmarshal(data, &detail); err == nil {
+		*ed = ErrorDetails{detail}
+		return nil
+	}
+	return errors.New("error details should be an array or a single object")
+}
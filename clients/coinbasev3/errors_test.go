@@ -0,0 +1,115 @@
+package coinbasev3
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestApiClient_ResponseError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-1")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"NOT_FOUND","code":5,"message":"ProductID BTC-XYZ is not a valid product","details":[{"type_url":"type.googleapis.com/coinbase.public_api.NotFound","value":"abc"}]}`))
+	}))
+	defer srv.Close()
+
+	api := NewApiClient("api_key", testSecretKey(t), "portfolio_id")
+	api.SetBaseUrlV3(srv.URL)
+
+	_, err := api.GetProductBook(context.Background(), "BTC-XYZ", 10)
+	var resErr ResponseError
+	if !errors.As(err, &resErr) {
+		t.Fatalf("Expected a ResponseError, got %v", err)
+	}
+
+	if resErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", resErr.StatusCode)
+	}
+	if resErr.Code != "NOT_FOUND" {
+		t.Errorf("Expected code NOT_FOUND, got %s", resErr.Code)
+	}
+	if resErr.Message != "ProductID BTC-XYZ is not a valid product" {
+		t.Errorf("Expected the message of the response, got %s", resErr.Message)
+	}
+	if len(resErr.Details) != 1 || resErr.Details[0].Value != "abc" {
+		t.Errorf("Expected the details of the response, got %v", resErr.Details)
+	}
+	if resErr.RequestId != "req-1" {
+		t.Errorf("Expected request id req-1, got %s", resErr.RequestId)
+	}
+	if resErr.CoinbaseError.Code != "5" {
+		t.Errorf("Expected the numeric code 5, got %s", resErr.CoinbaseError.Code)
+	}
+	if !errors.Is(err, ErrInvalidProduct) || errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected only ErrInvalidProduct to match %v", err)
+	}
+	if err.Error() != "coinbase: 404 NOT_FOUND ProductID BTC-XYZ is not a valid product (request id req-1)" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+}
+
+func TestApiClient_ResponseErrorNotJson(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte("Unauthorized\n"))
+	}))
+	defer srv.Close()
+
+	api := NewApiClient("api_key", testSecretKey(t), "portfolio_id")
+	api.SetBaseUrlV3(srv.URL)
+
+	_, err := api.GetOrder(context.Background(), "abc")
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized, got %v", err)
+	}
+	if err == nil || err.Error() != "coinbase: 401 Unauthorized" {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestApiClient_CreateOrderFailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"success":false,"failure_reason":"UNKNOWN_FAILURE_REASON","error_response":{"error":"INSUFFICIENT_FUND","message":"Insufficient balance in source account","preview_failure_reason":"PREVIEW_INSUFFICIENT_FUND"}}`))
+	}))
+	defer srv.Close()
+
+	api := NewApiClient("api_key", testSecretKey(t), "portfolio_id")
+	api.SetBaseUrlV3(srv.URL)
+
+	data, err := api.CreateOrder(context.Background(), CreateOrderRequest{ClientOrderID: "client-1", ProductID: "BTC-USD", Side: "BUY"})
+	if data.Success {
+		t.Errorf("Expected the order to fail")
+	}
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Expected ErrInsufficientFunds, got %v", err)
+	}
+}
+
+func TestResponseError_Is(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      ResponseError
+		expected error
+	}{
+		{"insufficient funds", ResponseError{StatusCode: 200, Code: "INSUFFICIENT_FUND"}, ErrInsufficientFunds},
+		{"insufficient funds preview", NewOrderError("", CreatOrderErrorResponse{PreviewFailureReason: "PREVIEW_INSUFFICIENT_FUND"}).(ResponseError), ErrInsufficientFunds},
+		{"invalid product id", ResponseError{StatusCode: 400, Code: "INVALID_PRODUCT_ID"}, ErrInvalidProduct},
+		{"unknown product", ResponseError{StatusCode: 404, Message: "Product not found"}, ErrInvalidProduct},
+		{"unauthenticated", ResponseError{StatusCode: 401}, ErrUnauthorized},
+		{"permission denied", ResponseError{StatusCode: 400, Code: "PERMISSION_DENIED"}, ErrUnauthorized},
+		{"too many requests", ResponseError{StatusCode: 429}, ErrRateLimited},
+		{"resource exhausted", ResponseError{Code: "RESOURCE_EXHAUSTED"}, ErrRateLimited},
+	}
+
+	targets := []error{ErrInsufficientFunds, ErrInvalidProduct, ErrUnauthorized, ErrRateLimited}
+	for _, tt := range tests {
+		for _, target := range targets {
+			if errors.Is(tt.err, target) != (target == tt.expected) {
+				t.Errorf("%s: Expected errors.Is(%v) to be %v", tt.name, target, target == tt.expected)
+			}
+		}
+	}
+}
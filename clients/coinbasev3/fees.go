@@ -1,6 +1,7 @@
 package coinbasev3
 
 import (
+	"context"
 	"fmt"
 	"strings"
 )
@@ -33,7 +34,7 @@ type TransactionSummaryRequest struct {
 }
 
 // GetTransactionSummary get a summary of transactions with fee tiers, total volume, and fees.
-func (c *ApiClient) GetTransactionSummary(req TransactionSummaryRequest) (TransactionSummaryData, error) {
+func (c *ApiClient) GetTransactionSummary(ctx context.Context, req TransactionSummaryRequest) (TransactionSummaryData, error) {
 	sb := strings.Builder{}
 	if req.StartDate != "" {
 		sb.WriteString(fmt.Sprintf("&start_date=%s", req.StartDate))
@@ -58,8 +59,8 @@ func (c *ApiClient) GetTransactionSummary(req TransactionSummaryRequest) (Transa
 	}
 	u := c.makeV3Url(fmt.Sprintf("/brokerage/transaction_summary%s", query))
 	var data TransactionSummaryData
-	if res, err := c.get(u, &data); err != nil {
-		return data, newResponseError(res)
+	if err := c.get(ctx, u, &data); err != nil {
+		return data, err
 	}
 	return data, nil
 }
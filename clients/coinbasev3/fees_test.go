@@ -1,22 +1,14 @@
 package coinbasev3
 
 import (
-	"github.com/jarcoal/httpmock"
-	"net/http"
+	"context"
 	"testing"
 )
 
 func TestApiClient_GetTransactionSummary(t *testing.T) {
-	api := NewApiClient("api_key", "secret_key")
-	httpmock.ActivateNonDefault(api.GetClient().HttpClient())
-	httpmock.RegisterResponder("GET", "https://api.coinbase.com/api/v3/brokerage/transaction_summary", func(request *http.Request) (*http.Response, error) {
-		respBody := `{"advanced_trade_only_fees":0,"advanced_trade_only_volume":0,"coinbase_pro_fees":0,"coinbase_pro_volume":0,"fee_tier":{"aop_from":"","aop_to":"","maker_fee_rate":"0.006","pricing_tier":"Advanced 1","taker_fee_rate":"0.008","usd_from":"0","usd_to":"1000"},"goods_and_services_tax":{"rate":"","type":""},"margin_rate":{"value":""},"total_fees":0,"total_volume":0}`
-		resp := httpmock.NewStringResponse(http.StatusOK, respBody)
-		resp.Header.Set("Content-Type", "application/json; charset=utf-8")
-		return resp, nil
-	})
+	api := replayClient(t, "transaction_summary")
 
-	data, err := api.GetTransactionSummary(TransactionSummaryRequest{})
+	data, err := api.GetTransactionSummary(context.Background(), TransactionSummaryRequest{})
 	if err != nil {
 		t.Fatalf("Expected no error, got %s", err)
 	}
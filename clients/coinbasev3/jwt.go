@@ -15,7 +15,7 @@ import (
 
 type APIKeyClaims struct {
 	*jwt.Claims
-	URI string `json:"uri"`
+	URI string `json:"uri,omitempty"` // empty for websocket messages
 }
 
 func BuildJWT(uri, keyName, keySecret string) (string, error) {
@@ -0,0 +1,385 @@
+package coinbasev3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const defaultResyncDepth = 1000 // levels per side requested from the product book on resync
+
+var (
+	ErrNoProductId       = fmt.Errorf("no product id provided")
+	ErrBookNotSynced     = fmt.Errorf("order book is not synced")
+	ErrInsufficientDepth = fmt.Errorf("order book is not deep enough")
+)
+
+// BookSide is a side of the order book.
+type BookSide string
+
+const (
+	BookSideBid BookSide = "bid"
+	BookSideAsk BookSide = "offer" // the level2 channel calls asks offers
+)
+
+// ProductBookGetter fetches the product book used to resync the order book. It is implemented by ApiClient.
+type ProductBookGetter interface {
+	GetProductBook(ctx context.Context, productId string, limit int32) (ProductBookData, error)
+}
+
+// OrderBookConfig is the configuration struct for creating a new order book.
+type OrderBookConfig struct {
+	ProductId   string      // required
+	ResyncDepth int32       // optional. defaults to 1000 levels per side
+	OnResync    func()      // optional. called after the book was resynced from the product book
+	OnError     func(error) // optional. called with the errors Run continues after
+}
+
+// BookLevel is the quantity available at a price.
+type BookLevel struct {
+	Price decimal.Decimal
+	Size  decimal.Decimal
+}
+
+// BookFill describes how a market buy would fill against the book.
+type BookFill struct {
+	Size         decimal.Decimal // base currency bought
+	Cost         decimal.Decimal // quote currency spent
+	AveragePrice decimal.Decimal
+	WorstPrice   decimal.Decimal // price of the last level reached, a limit price filling the whole order
+}
+
+// OrderBook maintains a local copy of the book of one product from the level2 channel. Snapshots replace the book,
+// updates change single levels. A gap in the sequence numbers of the websocket messages means messages were lost,
+// the book is then resynced from the product book.
+//
+// The sequence numbers count all messages of a connection, so the book has to be given every message read from it.
+type OrderBook struct {
+	productId   string
+	api         ProductBookGetter
+	resyncDepth int32
+	onResync    func()
+	onError     func(error)
+
+	mu        sync.RWMutex
+	bids      []BookLevel // best (highest) price first
+	asks      []BookLevel // best (lowest) price first
+	synced    bool
+	stale     bool // messages were lost or failed to apply, the book needs a resync
+	sequence  int
+	seen      bool // a message was applied, sequence is known
+	updatedAt time.Time
+}
+
+// NewOrderBook creates an empty order book. It is synced by the first level2 snapshot or a resync.
+func NewOrderBook(api ProductBookGetter, cfg OrderBookConfig) (*OrderBook, error) {
+	if cfg.ProductId == "" {
+		return nil, ErrNoProductId
+	}
+	if cfg.ResyncDepth == 0 {
+		cfg.ResyncDepth = defaultResyncDepth
+	}
+	if cfg.OnResync == nil {
+		cfg.OnResync = func() {}
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(error) {}
+	}
+
+	return &OrderBook{
+		productId:   cfg.ProductId,
+		api:         api,
+		resyncDepth: cfg.ResyncDepth,
+		onResync:    cfg.OnResync,
+		onError:     cfg.OnError,
+	}, nil
+}
+
+// Run applies the messages of readCh until the context is cancelled or readCh is closed. Errors are passed to
+// OnError, a failed resync is retried with the next message.
+func (b *OrderBook) Run(ctx context.Context, readCh <-chan []byte) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-readCh:
+			if !ok {
+				return nil
+			}
+			if err := b.Apply(ctx, msg); err != nil {
+				b.onError(err)
+			}
+		}
+	}
+}
+
+// Apply decodes and applies a websocket message.
+func (b *OrderBook) Apply(ctx context.Context, msg []byte) error {
+	var evt Event
+	if err := json.Unmarshal(msg, &evt); err != nil {
+		return err
+	}
+	return b.ApplyEvent(ctx, evt)
+}
+
+// ApplyEvent applies a websocket event. Events of other channels and products only advance the sequence number. A gap
+// resyncs the book with ctx, cancelling ctx aborts the resync.
+func (b *OrderBook) ApplyEvent(ctx context.Context, evt Event) error {
+	b.mu.Lock()
+	gap := b.seen && evt.SequenceNum != b.sequence+1
+	b.sequence = evt.SequenceNum
+	b.seen = true
+
+	snapshot := false
+	if evt.IsLevel2Event() {
+		l2, err := evt.GetLevel2Event()
+		if err != nil {
+			b.synced, b.stale = false, true
+			b.mu.Unlock()
+			return err
+		}
+
+		for _, e := range l2.Events {
+			if e.ProductId != b.productId {
+				continue
+			}
+
+			if e.Type == "snapshot" {
+				b.bids, b.asks = nil, nil
+				b.synced, b.stale = true, false
+				snapshot = true
+			}
+
+			// a snapshot in the same message covers the gap, the updates are applied
+			if !b.synced || (gap && !snapshot) {
+				continue
+			}
+
+			if err := b.applyUpdates(e.Updates); err != nil {
+				b.synced, b.stale = false, true
+				b.mu.Unlock()
+				return err
+			}
+			b.updatedAt = evt.Timestamp
+		}
+	}
+
+	// before the first snapshot there is nothing to resync
+	if gap && !snapshot && b.synced {
+		b.synced, b.stale = false, true
+	}
+	stale := b.stale
+	b.mu.Unlock()
+
+	if stale {
+		return b.Resync(ctx)
+	}
+	return nil
+}
+
+// Resync replaces the book with the product book from the REST API.
+func (b *OrderBook) Resync(ctx context.Context) error {
+	data, err := b.api.GetProductBook(ctx, b.productId, b.resyncDepth)
+	if err != nil {
+		return fmt.Errorf("order book resync: %w", err)
+	}
+
+	bids, err := bookLevels(data.PriceBook.Bids)
+	if err != nil {
+		return err
+	}
+	asks, err := bookLevels(data.PriceBook.Asks)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(bids, func(i, j int) bool { return bids[i].Price.GreaterThan(bids[j].Price) })
+	sort.Slice(asks, func(i, j int) bool { return asks[i].Price.LessThan(asks[j].Price) })
+
+	updatedAt, _ := time.Parse(time.RFC3339Nano, data.PriceBook.Time)
+
+	b.mu.Lock()
+	b.bids, b.asks = bids, asks
+	b.synced, b.stale = true, false
+	b.updatedAt = updatedAt
+	b.mu.Unlock()
+
+	b.onResync()
+	return nil
+}
+
+func bookLevels(orders []PriceBookOrder) ([]BookLevel, error) {
+	levels := make([]BookLevel, 0, len(orders))
+	for _, o := range orders {
+		price, err := decimal.NewFromString(o.Price)
+		if err != nil {
+			return nil, err
+		}
+		size, err := decimal.NewFromString(o.Size)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, BookLevel{Price: price, Size: size})
+	}
+	return levels, nil
+}
+
+// applyUpdates sets the quantity of the updated levels, a zero quantity removes the level.
+func (b *OrderBook) applyUpdates(updates []Level2Update) error {
+	for _, u := range updates {
+		price, err := decimal.NewFromString(u.PriceLevel)
+		if err != nil {
+			return err
+		}
+		size, err := decimal.NewFromString(u.NewQuantity)
+		if err != nil {
+			return err
+		}
+
+		switch BookSide(u.Side) {
+		case BookSideBid:
+			b.bids = setLevel(b.bids, price, size, func(a, b decimal.Decimal) bool { return a.GreaterThan(b) })
+		case BookSideAsk, "ask":
+			b.asks = setLevel(b.asks, price, size, func(a, b decimal.Decimal) bool { return a.LessThan(b) })
+		default:
+			return fmt.Errorf("unknown order book side %s", u.Side)
+		}
+	}
+	return nil
+}
+
+// setLevel inserts, replaces or removes the level at price of levels sorted by better.
+func setLevel(levels []BookLevel, price, size decimal.Decimal, better func(a, b decimal.Decimal) bool) []BookLevel {
+	i := sort.Search(len(levels), func(i int) bool { return !better(levels[i].Price, price) })
+	found := i < len(levels) && levels[i].Price.Equal(price)
+
+	switch {
+	case found && size.IsZero():
+		return append(levels[:i], levels[i+1:]...)
+	case found:
+		levels[i].Size = size
+	case !size.IsZero():
+		levels = append(levels, BookLevel{})
+		copy(levels[i+1:], levels[i:])
+		levels[i] = BookLevel{Price: price, Size: size}
+	}
+	return levels
+}
+
+// Synced returns true while the book reflects the exchange, i.e. after a snapshot or resync and without gaps since.
+func (b *OrderBook) Synced() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.synced
+}
+
+// UpdatedAt returns the time of the last applied update.
+func (b *OrderBook) UpdatedAt() time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.updatedAt
+}
+
+// BestBid returns the highest bid. The second value is false when there are no bids or the book is not synced.
+func (b *OrderBook) BestBid() (BookLevel, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.synced || len(b.bids) == 0 {
+		return BookLevel{}, false
+	}
+	return b.bids[0], true
+}
+
+// BestAsk returns the lowest ask. The second value is false when there are no asks or the book is not synced.
+func (b *OrderBook) BestAsk() (BookLevel, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.synced || len(b.asks) == 0 {
+		return BookLevel{}, false
+	}
+	return b.asks[0], true
+}
+
+// SizeAt returns the quantity at exactly price.
+func (b *OrderBook) SizeAt(side BookSide, price decimal.Decimal) (decimal.Decimal, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.synced {
+		return decimal.Zero, ErrBookNotSynced
+	}
+
+	for _, l := range b.side(side) {
+		if l.Price.Equal(price) {
+			return l.Size, nil
+		}
+	}
+	return decimal.Zero, nil
+}
+
+// DepthAt returns the quantity of all levels at price or better: bids at or above, asks at or below the price.
+func (b *OrderBook) DepthAt(side BookSide, price decimal.Decimal) (decimal.Decimal, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.synced {
+		return decimal.Zero, ErrBookNotSynced
+	}
+
+	depth := decimal.Zero
+	for _, l := range b.side(side) {
+		if (side == BookSideBid && l.Price.LessThan(price)) || (side != BookSideBid && l.Price.GreaterThan(price)) {
+			break
+		}
+		depth = depth.Add(l.Size)
+	}
+	return depth, nil
+}
+
+// CostToBuy walks the asks to spend quote in the quote currency. It returns ErrInsufficientDepth when the book
+// cannot fill the whole amount.
+func (b *OrderBook) CostToBuy(quote decimal.Decimal) (BookFill, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.synced {
+		return BookFill{}, ErrBookNotSynced
+	}
+
+	var fill BookFill
+	remaining := quote
+	for _, l := range b.asks {
+		if !remaining.IsPositive() {
+			break
+		}
+
+		size := l.Size
+		cost := l.Price.Mul(size)
+		if cost.GreaterThan(remaining) {
+			cost = remaining
+			size = remaining.Div(l.Price)
+		}
+
+		fill.Size = fill.Size.Add(size)
+		fill.Cost = fill.Cost.Add(cost)
+		fill.WorstPrice = l.Price
+		remaining = remaining.Sub(cost)
+	}
+
+	if remaining.IsPositive() {
+		return fill, ErrInsufficientDepth
+	}
+	if fill.Size.IsPositive() {
+		fill.AveragePrice = fill.Cost.Div(fill.Size)
+	}
+	return fill, nil
+}
+
+func (b *OrderBook) side(side BookSide) []BookLevel {
+	if side == BookSideBid {
+		return b.bids
+	}
+	return b.asks
+}
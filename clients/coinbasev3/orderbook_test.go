@@ -0,0 +1,259 @@
+package coinbasev3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+type fakeProductBook struct {
+	data  ProductBookData
+	err   error
+	calls int
+	ctx   context.Context
+}
+
+func (f *fakeProductBook) GetProductBook(ctx context.Context, productId string, limit int32) (ProductBookData, error) {
+	f.calls++
+	f.ctx = ctx
+	return f.data, f.err
+}
+
+func l2Message(seq int, typ string, productId string, updates ...string) []byte {
+	u := ""
+	for i := 0; i+2 < len(updates); i += 3 {
+		if u != "" {
+			u += ","
+		}
+		u += fmt.Sprintf(`{"side":"%s","event_time":"2023-02-09T20:32:50.714964855Z","price_level":"%s","new_quantity":"%s"}`, updates[i], updates[i+1], updates[i+2])
+	}
+	return []byte(fmt.Sprintf(`{"channel":"l2_data","client_id":"","timestamp":"2023-02-09T20:32:50.714964855Z","sequence_num":%d,"events":[{"type":"%s","product_id":"%s","updates":[%s]}]}`, seq, typ, productId, u))
+}
+
+func heartbeatMessage(seq int) []byte {
+	return []byte(fmt.Sprintf(`{"channel":"heartbeats","client_id":"","timestamp":"2023-06-23T20:31:26.122969572Z","sequence_num":%d,"events":[{"current_time":"2023-06-23 20:31:56.121961769 +0000 UTC m=+91717.525857105","heartbeat_counter":"3049"}]}`, seq))
+}
+
+func newTestOrderBook(t *testing.T, api ProductBookGetter) *OrderBook {
+	book, err := NewOrderBook(api, OrderBookConfig{ProductId: "BTC-USD"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	return book
+}
+
+func apply(t *testing.T, book *OrderBook, msg []byte) {
+	if err := book.Apply(context.Background(), msg); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+}
+
+func TestNewOrderBook(t *testing.T) {
+	_, err := NewOrderBook(&fakeProductBook{}, OrderBookConfig{})
+	if err != ErrNoProductId {
+		t.Errorf("Expected %s, got %v", ErrNoProductId, err)
+	}
+}
+
+func TestOrderBook_SnapshotAndUpdates(t *testing.T) {
+	api := &fakeProductBook{}
+	book := newTestOrderBook(t, api)
+
+	if _, ok := book.BestBid(); ok {
+		t.Errorf("Expected no best bid before the snapshot")
+	}
+
+	apply(t, book, heartbeatMessage(0))
+	apply(t, book, l2Message(1, "snapshot", "BTC-USD",
+		"bid", "21921.73", "0.5",
+		"bid", "21921.70", "1",
+		"offer", "21921.80", "0.25",
+		"offer", "21922.00", "2",
+	))
+	apply(t, book, l2Message(2, "update", "ETH-USD", "bid", "1600", "10"))
+	apply(t, book, l2Message(3, "update", "BTC-USD",
+		"bid", "21921.75", "0.1",
+		"bid", "21921.70", "0",
+		"offer", "21921.80", "0.3",
+		"offer", "21921.90", "1",
+	))
+
+	if !book.Synced() {
+		t.Errorf("Expected the book to be synced")
+	}
+	if api.calls != 0 {
+		t.Errorf("Expected no resync, got %d", api.calls)
+	}
+
+	bid, _ := book.BestBid()
+	if bid.Price.String() != "21921.75" || bid.Size.String() != "0.1" {
+		t.Errorf("Expected best bid 0.1 at 21921.75, got %s at %s", bid.Size, bid.Price)
+	}
+
+	ask, _ := book.BestAsk()
+	if ask.Price.String() != "21921.8" || ask.Size.String() != "0.3" {
+		t.Errorf("Expected best ask 0.3 at 21921.8, got %s at %s", ask.Size, ask.Price)
+	}
+
+	size, _ := book.SizeAt(BookSideBid, decimal.RequireFromString("21921.70"))
+	if !size.IsZero() {
+		t.Errorf("Expected the removed level to be empty, got %s", size)
+	}
+
+	depth, _ := book.DepthAt(BookSideBid, decimal.RequireFromString("21921.73"))
+	if depth.String() != "0.6" {
+		t.Errorf("Expected bid depth 0.6, got %s", depth)
+	}
+
+	depth, _ = book.DepthAt(BookSideAsk, decimal.RequireFromString("21921.95"))
+	if depth.String() != "1.3" {
+		t.Errorf("Expected ask depth 1.3, got %s", depth)
+	}
+}
+
+func TestOrderBook_ResyncOnGap(t *testing.T) {
+	api := &fakeProductBook{data: ProductBookData{PriceBook: PriceBook{
+		ProductId: "BTC-USD",
+		Bids:      []PriceBookOrder{{Price: "20000.1", Size: "1"}, {Price: "20000.5", Size: "2"}},
+		Asks:      []PriceBookOrder{{Price: "20001", Size: "3"}},
+		Time:      "2023-11-28T16:56:43.770106Z",
+	}}}
+
+	resynced := 0
+	book, _ := NewOrderBook(api, OrderBookConfig{ProductId: "BTC-USD", OnResync: func() { resynced++ }})
+
+	apply(t, book, l2Message(5, "snapshot", "BTC-USD", "bid", "19000", "1"))
+	apply(t, book, heartbeatMessage(6))
+
+	// sequence 7 was lost, the update cannot be applied
+	apply(t, book, l2Message(8, "update", "BTC-USD", "bid", "19500", "1"))
+
+	if api.calls != 1 || resynced != 1 {
+		t.Fatalf("Expected 1 resync, got %d", api.calls)
+	}
+
+	bid, _ := book.BestBid()
+	if bid.Price.String() != "20000.5" {
+		t.Errorf("Expected best bid 20000.5 from the product book, got %s", bid.Price)
+	}
+
+	size, _ := book.SizeAt(BookSideBid, decimal.RequireFromString("19500"))
+	if !size.IsZero() {
+		t.Errorf("Expected the update after the gap to be dropped, got %s", size)
+	}
+
+	apply(t, book, l2Message(9, "update", "BTC-USD", "offer", "20000.9", "1"))
+	ask, _ := book.BestAsk()
+	if ask.Price.String() != "20000.9" {
+		t.Errorf("Expected updates to apply after the resync, got best ask %s", ask.Price)
+	}
+
+	// a new connection starts over with a snapshot
+	apply(t, book, l2Message(0, "snapshot", "BTC-USD", "bid", "21000", "1"))
+	if api.calls != 1 {
+		t.Errorf("Expected the snapshot to cover the gap, got %d resyncs", api.calls)
+	}
+}
+
+func TestOrderBook_ResyncFailure(t *testing.T) {
+	api := &fakeProductBook{err: errors.New("timeout")}
+	book := newTestOrderBook(t, api)
+
+	apply(t, book, l2Message(1, "snapshot", "BTC-USD", "bid", "19000", "1"))
+
+	if err := book.Apply(context.Background(), heartbeatMessage(3)); err == nil {
+		t.Fatalf("Expected the resync to fail")
+	}
+	if book.Synced() {
+		t.Errorf("Expected the book not to be synced")
+	}
+	if _, err := book.CostToBuy(decimal.NewFromInt(10)); err != ErrBookNotSynced {
+		t.Errorf("Expected %s, got %v", ErrBookNotSynced, err)
+	}
+
+	api.err = nil
+	apply(t, book, heartbeatMessage(4))
+	if api.calls != 2 || !book.Synced() {
+		t.Errorf("Expected the resync to be retried with the next message, got %d calls", api.calls)
+	}
+}
+
+func TestOrderBook_CostToBuy(t *testing.T) {
+	book := newTestOrderBook(t, &fakeProductBook{})
+
+	apply(t, book, l2Message(1, "snapshot", "BTC-USD",
+		"offer", "100", "1",
+		"offer", "101", "2",
+		"offer", "102", "1",
+	))
+
+	fill, err := book.CostToBuy(decimal.NewFromInt(201))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	if fill.Size.String() != "2" || fill.Cost.String() != "201" || fill.WorstPrice.String() != "101" || fill.AveragePrice.String() != "100.5" {
+		t.Errorf("Expected 2 for 201 at 100.5 up to 101, got %s for %s at %s up to %s", fill.Size, fill.Cost, fill.AveragePrice, fill.WorstPrice)
+	}
+
+	if _, err := book.CostToBuy(decimal.NewFromInt(1000)); err != ErrInsufficientDepth {
+		t.Errorf("Expected %s, got %v", ErrInsufficientDepth, err)
+	}
+}
+
+func TestOrderBook_Run(t *testing.T) {
+	var errs []error
+	book, _ := NewOrderBook(&fakeProductBook{}, OrderBookConfig{ProductId: "BTC-USD", OnError: func(err error) { errs = append(errs, err) }})
+
+	readCh := make(chan []byte, 3)
+	readCh <- l2Message(1, "snapshot", "BTC-USD", "bid", "19000", "1")
+	readCh <- []byte("not json")
+	readCh <- l2Message(2, "update", "BTC-USD", "bid", "19001", "1")
+	close(readCh)
+
+	if err := book.Run(context.Background(), readCh); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	if len(errs) != 1 {
+		t.Errorf("Expected 1 error, got %v", errs)
+	}
+
+	bid, _ := book.BestBid()
+	if bid.Price.String() != "19001" {
+		t.Errorf("Expected best bid 19001, got %s", bid.Price)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := book.Run(ctx, make(chan []byte)); err != context.Canceled {
+		t.Errorf("Expected %s, got %v", context.Canceled, err)
+	}
+}
+
+func TestOrderBook_RunResyncContext(t *testing.T) {
+	api := &fakeProductBook{}
+	book := newTestOrderBook(t, api)
+
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "run"))
+	defer cancel()
+
+	readCh := make(chan []byte, 2)
+	readCh <- l2Message(1, "snapshot", "BTC-USD", "bid", "19000", "1")
+	readCh <- heartbeatMessage(3)
+	close(readCh)
+
+	if err := book.Run(ctx, readCh); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if api.calls != 1 {
+		t.Fatalf("Expected 1 resync, got %d", api.calls)
+	}
+	if api.ctx.Value(key{}) != "run" {
+		t.Errorf("Expected the resync to use the context of Run")
+	}
+}
@@ -1,6 +1,7 @@
 package coinbasev3
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -77,11 +78,11 @@ func (q ListFillsQuery) BuildQueryString() string {
 }
 
 // GetListFills get a list of fills filtered by optional query parameters (product_id, order_id, etc).
-func (c *ApiClient) GetListFills(q ListFillsQuery) (ListFillsData, error) {
+func (c *ApiClient) GetListFills(ctx context.Context, q ListFillsQuery) (ListFillsData, error) {
 	u := c.makeV3Url(fmt.Sprintf("/brokerage/orders/historical/fills%s", q.BuildQueryString()))
 	var data ListFillsData
-	if res, err := c.get(u, &data); err != nil {
-		return data, newResponseError(res)
+	if err := c.get(ctx, u, &data); err != nil {
+		return data, err
 	}
 	return data, nil
 }
@@ -154,6 +155,8 @@ type ListOrdersQuery struct {
 	OrderPlacementSource OrderPlacementSource `json:"order_placement_source,omitempty"`
 	// ContractExpiryType Only orders matching this contract expiry type are returned. Filter is only applied if ProductType is set to FUTURE in the request.
 	ContractExpiryType ContractExpiryType `json:"contract_expiry_type,omitempty"`
+	// RetailPortfolioId Only orders of this portfolio are returned. Defaults to the default portfolio.
+	RetailPortfolioId string `json:"retail_portfolio_id,omitempty"`
 }
 
 // BuildQueryString creates a query string from the request parameters. If no parameters are set, an empty string is returned.
@@ -195,6 +198,9 @@ func (q *ListOrdersQuery) BuildQueryString() string {
 	if q.ContractExpiryType != "" {
 		sb.WriteString(fmt.Sprintf("&contract_expiry_type=%s", q.ContractExpiryType))
 	}
+	if q.RetailPortfolioId != "" {
+		sb.WriteString(fmt.Sprintf("&retail_portfolio_id=%s", q.RetailPortfolioId))
+	}
 
 	// Remove the first '&' for a clean query string
 	if sb.Len() > 0 {
@@ -204,12 +210,12 @@ func (q *ListOrdersQuery) BuildQueryString() string {
 }
 
 // GetListOrders get a list of orders filtered by optional query parameters (product_id, order_status, etc). Note: You cannot pair open orders with other order types. Example: order_status=OPEN,CANCELLED will return an error.
-func (c *ApiClient) GetListOrders(q ListOrdersQuery) (ListOrdersData, error) {
+func (c *ApiClient) GetListOrders(ctx context.Context, q ListOrdersQuery) (ListOrdersData, error) {
 	u := c.makeV3Url(fmt.Sprintf("/brokerage/orders/historical/batch?%s", q.BuildQueryString()))
 
 	var data ListOrdersData
-	if res, err := c.get(u, &data); err != nil {
-		return data, newResponseError(res)
+	if err := c.get(ctx, u, &data); err != nil {
+		return data, err
 	}
 	return data, nil
 }
@@ -326,12 +332,12 @@ type StopLimitStopLimitGtd struct {
 }
 
 // GetOrder get a single order by order ID.
-func (c *ApiClient) GetOrder(orderId string) (Order, error) {
+func (c *ApiClient) GetOrder(ctx context.Context, orderId string) (Order, error) {
 	u := c.makeV3Url(fmt.Sprintf("/brokerage/orders/historical/%s", orderId))
 
 	var data GetOrderData
-	if res, err := c.get(u, &data); err != nil {
-		return data.Order, newResponseError(res)
+	if err := c.get(ctx, u, &data); err != nil {
+		return data.Order, err
 	}
 	return data.Order, nil
 }
@@ -355,7 +361,7 @@ func (req CreateOrderRequest) ToJson() ([]byte, error) {
 }
 
 // CreateOrder create an order with a specified product_id (asset-pair), side (buy/sell), etc.
-func (c *ApiClient) CreateOrder(req CreateOrderRequest) (CreateOrderData, error) {
+func (c *ApiClient) CreateOrder(ctx context.Context, req CreateOrderRequest) (CreateOrderData, error) {
 	var data CreateOrderData
 
 	u := c.makeV3Url("/brokerage/orders")
@@ -365,8 +371,11 @@ func (c *ApiClient) CreateOrder(req CreateOrderRequest) (CreateOrderData, error)
 		return data, err
 	}
 
-	if res, err := c.post(u, body, &data); err != nil {
-		return data, newResponseError(res)
+	if err := c.post(ctx, u, body, &data); err != nil {
+		return data, err
+	}
+	if !data.Success {
+		return data, NewOrderError(data.FailureReason, data.ErrorResponse)
 	}
 	return data, nil
 }
@@ -395,7 +404,7 @@ type CreateOrderSuccessResponse struct {
 }
 
 // CancelOrders initiate cancel requests for one or more orders.
-func (c *ApiClient) CancelOrders(orderIds []string) (CancelOrdersData, error) {
+func (c *ApiClient) CancelOrders(ctx context.Context, orderIds []string) (CancelOrdersData, error) {
 	var data CancelOrdersData
 
 	u := c.makeV3Url("/brokerage/orders/batch_cancel")
@@ -409,8 +418,8 @@ func (c *ApiClient) CancelOrders(orderIds []string) (CancelOrdersData, error) {
 		return data, err
 	}
 
-	if res, err := c.post(u, body, &data); err != nil {
-		return data, newResponseError(res)
+	if err := c.post(ctx, u, body, &data); err != nil {
+		return data, err
 	}
 	return data, nil
 }
@@ -461,7 +470,7 @@ func (req EditOrderRequest) ToJson() ([]byte, error) {
 }
 
 // EditOrder edit an order with a specified new size, or new price. Only limit order types, with time in force type of good-till-cancelled can be edited.
-func (c *ApiClient) EditOrder(req EditOrderRequest) (EditOrderData, error) {
+func (c *ApiClient) EditOrder(ctx context.Context, req EditOrderRequest) (EditOrderData, error) {
 	var data EditOrderData
 
 	u := c.makeV3Url("/brokerage/orders/edit")
@@ -471,8 +480,8 @@ func (c *ApiClient) EditOrder(req EditOrderRequest) (EditOrderData, error) {
 		return data, err
 	}
 
-	if res, err := c.post(u, body, &data); err != nil {
-		return data, newResponseError(res)
+	if err := c.post(ctx, u, body, &data); err != nil {
+		return data, err
 	}
 	return data, nil
 }
@@ -488,7 +497,7 @@ type EditOrderErrors struct {
 }
 
 // EditOrderPreview edit an order with a specified new size, or new price. Only limit order types, with time in force type of good-till-cancelled can be edited.
-func (c *ApiClient) EditOrderPreview(req EditOrderRequest) (EditOrderPreviewData, error) {
+func (c *ApiClient) EditOrderPreview(ctx context.Context, req EditOrderRequest) (EditOrderPreviewData, error) {
 	var data EditOrderPreviewData
 
 	u := c.makeV3Url("/brokerage/orders/edit_preview")
@@ -498,8 +507,8 @@ func (c *ApiClient) EditOrderPreview(req EditOrderRequest) (EditOrderPreviewData
 		return data, err
 	}
 
-	if res, err := c.post(u, body, &data); err != nil {
-		return data, newResponseError(res)
+	if err := c.post(ctx, u, body, &data); err != nil {
+		return data, err
 	}
 	return data, nil
 }
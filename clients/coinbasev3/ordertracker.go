@@ -0,0 +1,327 @@
+package coinbasev3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const defaultOrderPollInterval = 2 * time.Second // GetOrder polling interval while the websocket is unavailable
+
+var (
+	ErrOrderNotTracked = fmt.Errorf("order is not tracked")
+)
+
+// OrderGetter fetches an order to poll its status. It is implemented by ApiClient.
+type OrderGetter interface {
+	GetOrder(ctx context.Context, orderId string) (Order, error)
+}
+
+// UserChannelSubscriber subscribes to the user channel. It is implemented by WsClient.
+type UserChannelSubscriber interface {
+	Subscribe(channel ChannelType, productIds []string) error
+}
+
+// OrderTrackerConfig is the configuration struct for creating a new order tracker.
+type OrderTrackerConfig struct {
+	PollInterval time.Duration // optional. defaults to 2 seconds. how often GetOrder is polled while the websocket is unavailable
+	OnError      func(error)   // optional. called with the errors Run continues after and failed polls
+}
+
+// OrderFill is the state of a tracked order. FilledSize is in the base currency, TotalFees in the quote currency.
+type OrderFill struct {
+	OrderId       string
+	ClientOrderId string
+	ProductId     string
+	Status        string
+	FilledSize    decimal.Decimal
+	AveragePrice  decimal.Decimal
+	TotalFees     decimal.Decimal
+}
+
+// Terminal returns true when the order will not change anymore.
+func (f OrderFill) Terminal() bool {
+	switch f.Status {
+	case "FILLED", "CANCELLED", "EXPIRED", "FAILED":
+		return true
+	}
+	return false
+}
+
+// OrderTracker follows orders by their client order id through the user channel. While the websocket is
+// unavailable, see SetConnected, the orders are polled with GetOrder instead.
+type OrderTracker struct {
+	api          OrderGetter
+	pollInterval time.Duration
+	onError      func(error)
+
+	mu        sync.Mutex
+	orders    map[string]*trackedOrder // by client order id
+	connected bool
+}
+
+// trackedOrder is the latest state of an order, done is closed once it is terminal.
+type trackedOrder struct {
+	fill      OrderFill
+	needsPoll bool // updates may have been missed, poll once even with the websocket available
+	done      chan struct{}
+}
+
+// NewOrderTracker creates an order tracker. It polls GetOrder until SetConnected reports the websocket as available.
+func NewOrderTracker(api OrderGetter, cfg OrderTrackerConfig) *OrderTracker {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultOrderPollInterval
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(error) {}
+	}
+
+	return &OrderTracker{
+		api:          api,
+		pollInterval: cfg.PollInterval,
+		onError:      cfg.OnError,
+		orders:       map[string]*trackedOrder{},
+	}
+}
+
+// Subscribe subscribes the websocket to the user channel of all products.
+func (t *OrderTracker) Subscribe(ws UserChannelSubscriber) error {
+	return ws.Subscribe(ChannelTypeUser, nil)
+}
+
+// SetConnected reports whether the websocket is available, e.g. from the OnConnect and OnDisconnect callbacks of
+// WsClient. Updates may have been lost while it was not, so every order is polled once after a reconnect.
+func (t *OrderTracker) SetConnected(connected bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if connected && !t.connected {
+		for _, o := range t.orders {
+			o.needsPoll = true
+		}
+	}
+	t.connected = connected
+}
+
+// Track starts tracking an order. Call it with the client order id before placing the order, the user channel
+// may report the order before CreateOrder returns.
+func (t *OrderTracker) Track(clientOrderId string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.orders[clientOrderId]; ok {
+		return
+	}
+	t.orders[clientOrderId] = &trackedOrder{
+		fill: OrderFill{ClientOrderId: clientOrderId},
+		done: make(chan struct{}),
+	}
+}
+
+// Placed sets the order id returned by CreateOrder, which is needed to poll the order.
+func (t *OrderTracker) Placed(clientOrderId, orderId string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	o, ok := t.orders[clientOrderId]
+	if !ok {
+		return
+	}
+	if o.fill.OrderId == "" {
+		o.fill.OrderId = orderId
+	}
+	// the order may have filled before the subscription was active
+	o.needsPoll = true
+}
+
+// Forget stops tracking an order, e.g. when placing it failed.
+func (t *OrderTracker) Forget(clientOrderId string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.orders, clientOrderId)
+}
+
+// Run applies the messages of readCh until the context is cancelled or readCh is closed. Messages of other
+// channels are ignored, errors are passed to OnError.
+func (t *OrderTracker) Run(ctx context.Context, readCh <-chan []byte) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-readCh:
+			if !ok {
+				return nil
+			}
+			if err := t.Apply(msg); err != nil {
+				t.onError(err)
+			}
+		}
+	}
+}
+
+// Apply decodes a websocket message and applies it when it is a user channel message.
+func (t *OrderTracker) Apply(msg []byte) error {
+	var evt Event
+	if err := json.Unmarshal(msg, &evt); err != nil {
+		return err
+	}
+	if !evt.IsUserEvent() {
+		return nil
+	}
+
+	user, err := evt.GetUserEvent()
+	if err != nil {
+		return err
+	}
+	return t.ApplyUserEvent(user)
+}
+
+// ApplyUserEvent applies the order updates of a user channel event, it can be registered with Dispatcher.OnUser.
+// Updates of untracked orders are ignored.
+func (t *OrderTracker) ApplyUserEvent(evt UserEvent) error {
+	for _, e := range evt.Events {
+		for _, u := range e.Orders {
+			fill, err := userOrderFill(u)
+			if err != nil {
+				return err
+			}
+			t.update(fill)
+		}
+	}
+	return nil
+}
+
+// update stores the latest state of a tracked order.
+func (t *OrderTracker) update(fill OrderFill) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	o, ok := t.orders[fill.ClientOrderId]
+	if !ok || o.fill.Terminal() {
+		return
+	}
+
+	o.fill = fill
+	if fill.Terminal() {
+		close(o.done)
+	}
+}
+
+// WaitForTerminal waits until the order is filled, cancelled, expired or failed and stops tracking it. On context
+// cancellation the last known state is returned with the context error and the order stays tracked.
+func (t *OrderTracker) WaitForTerminal(ctx context.Context, clientOrderId string) (OrderFill, error) {
+	t.mu.Lock()
+	o, ok := t.orders[clientOrderId]
+	t.mu.Unlock()
+	if !ok {
+		return OrderFill{}, ErrOrderNotTracked
+	}
+
+	ticker := time.NewTicker(t.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.done:
+			return t.finish(clientOrderId, o), nil
+		case <-ctx.Done():
+			t.mu.Lock()
+			fill := o.fill
+			t.mu.Unlock()
+			return fill, ctx.Err()
+		case <-ticker.C:
+			t.poll(ctx, o)
+		}
+	}
+}
+
+// poll fetches the order with GetOrder when the websocket is unavailable or updates may have been missed.
+func (t *OrderTracker) poll(ctx context.Context, o *trackedOrder) {
+	t.mu.Lock()
+	orderId, clientOrderId := o.fill.OrderId, o.fill.ClientOrderId
+	skip := orderId == "" || (t.connected && !o.needsPoll)
+	o.needsPoll = false
+	t.mu.Unlock()
+	if skip {
+		return
+	}
+
+	order, err := t.api.GetOrder(ctx, orderId)
+	if err != nil {
+		// the websocket may have missed the terminal update, poll again
+		t.mu.Lock()
+		o.needsPoll = true
+		t.mu.Unlock()
+		t.onError(fmt.Errorf("order %s: %w", orderId, err))
+		return
+	}
+
+	fill, err := restOrderFill(order)
+	if err != nil {
+		t.onError(err)
+		return
+	}
+	fill.ClientOrderId = clientOrderId
+	t.update(fill)
+}
+
+func (t *OrderTracker) finish(clientOrderId string, o *trackedOrder) OrderFill {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.orders, clientOrderId)
+	return o.fill
+}
+
+func userOrderFill(u UserOrder) (OrderFill, error) {
+	fill := OrderFill{
+		OrderId:       u.OrderId,
+		ClientOrderId: u.ClientOrderId,
+		ProductId:     u.ProductId,
+		Status:        u.Status,
+	}
+	err := parseDecimals([]decimalValue{
+		{u.CumulativeQuantity, &fill.FilledSize},
+		{u.AvgPrice, &fill.AveragePrice},
+		{u.TotalFees, &fill.TotalFees},
+	})
+	return fill, err
+}
+
+func restOrderFill(o Order) (OrderFill, error) {
+	fill := OrderFill{
+		OrderId:       o.OrderId,
+		ClientOrderId: o.ClientOrderId,
+		ProductId:     o.ProductId,
+		Status:        o.Status,
+	}
+	err := parseDecimals([]decimalValue{
+		{o.FilledSize, &fill.FilledSize},
+		{o.AverageFilledPrice, &fill.AveragePrice},
+		{o.TotalFees, &fill.TotalFees},
+	})
+	return fill, err
+}
+
+type decimalValue struct {
+	raw    string
+	target *decimal.Decimal
+}
+
+// parseDecimals parses the raw values into their targets, empty values are zero.
+func parseDecimals(values []decimalValue) error {
+	for _, v := range values {
+		if v.raw == "" {
+			continue
+		}
+		d, err := decimal.NewFromString(v.raw)
+		if err != nil {
+			return err
+		}
+		*v.target = d
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package coinbasev3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeOrderGetter struct {
+	orders   []Order // returned in turn, the last one repeatedly
+	err      error
+	failures int // number of calls failing before the orders are returned
+	calls    int
+}
+
+func (f *fakeOrderGetter) GetOrder(ctx context.Context, orderId string) (Order, error) {
+	f.calls++
+	if f.err != nil {
+		return Order{}, f.err
+	}
+	if f.calls <= f.failures {
+		return Order{}, errors.New("timeout")
+	}
+	i := f.calls - f.failures - 1
+	if i >= len(f.orders) {
+		i = len(f.orders) - 1
+	}
+	return f.orders[i], nil
+}
+
+func userMessage(clientOrderId, status, filled, avgPrice, fees string) []byte {
+	return []byte(fmt.Sprintf(`{"channel":"user","client_id":"","timestamp":"2023-02-09T20:33:57.609931463Z","sequence_num":1,"events":[{"type":"update","orders":[{"order_id":"order-1","client_order_id":"%s","cumulative_quantity":"%s","leaves_quantity":"0","avg_price":"%s","total_fees":"%s","status":"%s","product_id":"BTC-USD","creation_time":"2023-02-09T20:33:57.609931463Z","order_side":"BUY","order_type":"Market"}]}]}`, clientOrderId, filled, avgPrice, fees, status))
+}
+
+func TestOrderTracker_UserChannel(t *testing.T) {
+	api := &fakeOrderGetter{}
+	tracker := NewOrderTracker(api, OrderTrackerConfig{PollInterval: time.Hour})
+	tracker.SetConnected(true)
+
+	tracker.Track("client-1")
+	// the user channel can be faster than the response of CreateOrder
+	if err := tracker.Apply(userMessage("client-1", "OPEN", "0", "0", "0")); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	tracker.Placed("client-1", "order-1")
+
+	for _, msg := range [][]byte{
+		userMessage("other", "FILLED", "1", "1", "1"),
+		heartbeatMessage(2),
+		userMessage("client-1", "FILLED", "0.00452", "22100.5", "0.599"),
+		userMessage("client-1", "CANCELLED", "0", "0", "0"),
+	} {
+		if err := tracker.Apply(msg); err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+	}
+
+	fill, err := tracker.WaitForTerminal(context.Background(), "client-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if fill.Status != "FILLED" || fill.OrderId != "order-1" || fill.FilledSize.String() != "0.00452" || fill.AveragePrice.String() != "22100.5" || fill.TotalFees.String() != "0.599" {
+		t.Errorf("Expected the filled order, got %+v", fill)
+	}
+	if api.calls != 0 {
+		t.Errorf("Expected no polling with the websocket available, got %d calls", api.calls)
+	}
+
+	if _, err := tracker.WaitForTerminal(context.Background(), "client-1"); err != ErrOrderNotTracked {
+		t.Errorf("Expected %s, got %v", ErrOrderNotTracked, err)
+	}
+	if _, err := tracker.WaitForTerminal(context.Background(), "other"); err != ErrOrderNotTracked {
+		t.Errorf("Expected %s, got %v", ErrOrderNotTracked, err)
+	}
+}
+
+func TestOrderTracker_PollsWithoutSocket(t *testing.T) {
+	api := &fakeOrderGetter{orders: []Order{
+		{OrderId: "order-1", ClientOrderId: "client-1", Status: "OPEN"},
+		{OrderId: "order-1", ClientOrderId: "client-1", Status: "FILLED", FilledSize: "0.001", AverageFilledPrice: "50000", TotalFees: "0.3"},
+	}}
+	var errs []error
+	tracker := NewOrderTracker(api, OrderTrackerConfig{PollInterval: time.Millisecond, OnError: func(err error) { errs = append(errs, err) }})
+
+	tracker.Track("client-1")
+	tracker.Placed("client-1", "order-1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	fill, err := tracker.WaitForTerminal(ctx, "client-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if fill.Status != "FILLED" || fill.FilledSize.String() != "0.001" || fill.TotalFees.String() != "0.3" {
+		t.Errorf("Expected the filled order, got %+v", fill)
+	}
+	if api.calls != 2 || len(errs) != 0 {
+		t.Errorf("Expected 2 polls without errors, got %d and %v", api.calls, errs)
+	}
+}
+
+func TestOrderTracker_PollsOnceAfterReconnect(t *testing.T) {
+	api := &fakeOrderGetter{orders: []Order{{OrderId: "order-1", ClientOrderId: "client-1", Status: "OPEN"}}}
+	tracker := NewOrderTracker(api, OrderTrackerConfig{PollInterval: time.Millisecond})
+	tracker.SetConnected(true)
+
+	tracker.Track("client-1")
+	tracker.Placed("client-1", "order-1")
+
+	wait := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		fill, err := tracker.WaitForTerminal(ctx, "client-1")
+		if err != context.DeadlineExceeded || fill.Status != "OPEN" {
+			t.Fatalf("Expected the open order with %s, got %+v and %v", context.DeadlineExceeded, fill, err)
+		}
+	}
+
+	// the order is polled once in case it filled before the subscription
+	wait()
+	if api.calls != 1 {
+		t.Errorf("Expected 1 poll, got %d", api.calls)
+	}
+
+	tracker.SetConnected(false)
+	tracker.SetConnected(true)
+	wait()
+	if api.calls != 2 {
+		t.Errorf("Expected 1 more poll after the reconnect, got %d", api.calls)
+	}
+}
+
+func TestOrderTracker_PollRetriedWhileConnected(t *testing.T) {
+	api := &fakeOrderGetter{failures: 1, orders: []Order{
+		{OrderId: "order-1", ClientOrderId: "client-1", Status: "FILLED", FilledSize: "0.001", AverageFilledPrice: "50000", TotalFees: "0.3"},
+	}}
+	var errs []error
+	tracker := NewOrderTracker(api, OrderTrackerConfig{PollInterval: time.Millisecond, OnError: func(err error) { errs = append(errs, err) }})
+	tracker.SetConnected(true)
+
+	tracker.Track("client-1")
+	tracker.Placed("client-1", "order-1")
+
+	// the first poll fails and the terminal update never comes through the websocket
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	fill, err := tracker.WaitForTerminal(ctx, "client-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if fill.Status != "FILLED" {
+		t.Errorf("Expected the filled order, got %+v", fill)
+	}
+	if api.calls != 2 || len(errs) != 1 {
+		t.Errorf("Expected a failed and a successful poll, got %d and %v", api.calls, errs)
+	}
+}
+
+func TestOrderTracker_PollErrors(t *testing.T) {
+	api := &fakeOrderGetter{err: errors.New("timeout")}
+	var errs []error
+	tracker := NewOrderTracker(api, OrderTrackerConfig{PollInterval: time.Millisecond, OnError: func(err error) { errs = append(errs, err) }})
+
+	tracker.Track("client-1")
+	tracker.Placed("client-1", "order-1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := tracker.WaitForTerminal(ctx, "client-1"); err != context.DeadlineExceeded {
+		t.Errorf("Expected %s, got %v", context.DeadlineExceeded, err)
+	}
+	if len(errs) == 0 {
+		t.Errorf("Expected the failed polls to be reported")
+	}
+
+	tracker.Forget("client-1")
+	if _, err := tracker.WaitForTerminal(ctx, "client-1"); err != ErrOrderNotTracked {
+		t.Errorf("Expected %s, got %v", ErrOrderNotTracked, err)
+	}
+}
+
+func TestOrderTracker_Subscribe(t *testing.T) {
+	srv := &subscriptionServer{}
+	s := httptest.NewServer(srv)
+	defer s.Close()
+
+	cl := newTestWsClient(t, s.URL, WsClientConfig{})
+	defer cl.Shutdown()
+
+	tracker := NewOrderTracker(&fakeOrderGetter{}, OrderTrackerConfig{})
+	if err := tracker.Subscribe(cl); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if _, err := cl.Connect(); err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	waitFor(t, "the user channel subscription", func() bool { return len(srv.messages(0)) == 1 })
+	if msg := srv.messages(0)[0]; msg.Channel != ChannelTypeUser {
+		t.Errorf("Expected a user channel subscription, got %v", msg)
+	}
+}
@@ -0,0 +1,143 @@
+package coinbasev3
+
+import (
+	"context"
+)
+
+// pageSize is the number of items requested per page when the query sets no limit.
+const pageSize = 100
+
+// Iter iterates over the items of a list endpoint, following the cursor of each page until the last page or the
+// bound passed to its constructor is reached:
+//
+//	it := api.FillsIter(ListFillsQuery{ProductId: "BTC-USD"}, 0)
+//	for it.Next(ctx) {
+//		fill := it.Value()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iter[T any] struct {
+	fetch  func(ctx context.Context, cursor string) (page[T], error)
+	max    int
+	cursor string
+	items  []T
+	value  T
+	n      int
+	last   bool
+	err    error
+}
+
+// page is a single response of a list endpoint.
+type page[T any] struct {
+	items   []T
+	cursor  string
+	hasNext bool
+}
+
+func newIter[T any](max int, cursor string, fetch func(ctx context.Context, cursor string) (page[T], error)) *Iter[T] {
+	return &Iter[T]{fetch: fetch, max: max, cursor: cursor}
+}
+
+// Next advances to the next item, fetching the next page when the current one is used up. It returns false once
+// all items or max items were returned, when a request fails or when ctx is done, check Err to tell them apart.
+func (it *Iter[T]) Next(ctx context.Context) bool {
+	if it.err != nil || (it.max > 0 && it.n >= it.max) {
+		return false
+	}
+	if err := ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+
+	for len(it.items) == 0 {
+		if it.last {
+			return false
+		}
+		p, err := it.fetch(ctx, it.cursor)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		// a repeated cursor would return the same page forever
+		it.last = !p.hasNext || len(p.items) == 0 || p.cursor == "" || p.cursor == it.cursor
+		it.cursor = p.cursor
+		it.items = p.items
+	}
+
+	it.value, it.items = it.items[0], it.items[1:]
+	it.n++
+	return true
+}
+
+// Value returns the current item.
+func (it *Iter[T]) Value() T {
+	return it.value
+}
+
+// Err returns the error that stopped the iteration, nil if it ran out of items.
+func (it *Iter[T]) Err() error {
+	return it.err
+}
+
+// All returns the remaining items.
+func (it *Iter[T]) All(ctx context.Context) ([]T, error) {
+	var items []T
+	for it.Next(ctx) {
+		items = append(items, it.Value())
+	}
+	return items, it.Err()
+}
+
+// limit returns the page size to request for at most max items.
+func limit(max int) int {
+	if max > 0 && max < pageSize {
+		return max
+	}
+	return pageSize
+}
+
+// OrdersIter returns an iterator over the orders matching q, starting at q.Cursor. A max of 0 or less returns all
+// of them.
+func (c *ApiClient) OrdersIter(q ListOrdersQuery, max int) *Iter[Order] {
+	if q.Limit == 0 {
+		q.Limit = int32(limit(max))
+	}
+	return newIter(max, q.Cursor, func(ctx context.Context, cursor string) (page[Order], error) {
+		q.Cursor = cursor
+		data, err := c.GetListOrders(ctx, q)
+		if err != nil {
+			return page[Order]{}, err
+		}
+		return page[Order]{items: data.Orders, cursor: data.Cursor, hasNext: data.HasNext}, nil
+	})
+}
+
+// FillsIter returns an iterator over the fills matching q, starting at q.Cursor. A max of 0 or less returns all of
+// them.
+func (c *ApiClient) FillsIter(q ListFillsQuery, max int) *Iter[Fill] {
+	if q.Limit == 0 {
+		q.Limit = int64(limit(max))
+	}
+	return newIter(max, q.Cursor, func(ctx context.Context, cursor string) (page[Fill], error) {
+		q.Cursor = cursor
+		data, err := c.GetListFills(ctx, q)
+		if err != nil {
+			return page[Fill]{}, err
+		}
+		// the fills endpoint has no has_next, an empty cursor ends the list
+		return page[Fill]{items: data.Fills, cursor: data.Cursor, hasNext: data.Cursor != ""}, nil
+	})
+}
+
+// AccountsIter returns an iterator over the accounts of the current user. A max of 0 or less returns all of them.
+func (c *ApiClient) AccountsIter(max int) *Iter[Account] {
+	return newIter(max, "", func(ctx context.Context, cursor string) (page[Account], error) {
+		data, err := c.listAccounts(ctx, 250, cursor)
+		if err != nil {
+			return page[Account]{}, err
+		}
+		return page[Account]{items: data.Accounts, cursor: data.Cursor, hasNext: data.HasNext}, nil
+	})
+}
@@ -0,0 +1,161 @@
+package coinbasev3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// pagedServer serves pages rendered by render, chained by cursors page-1, page-2, ..., and records the query of
+// each request.
+func pagedServer(t *testing.T, render func(cursor string, last bool, i int) string, pages int) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.RawQuery)
+		mu.Unlock()
+
+		i := 0
+		if c := r.URL.Query().Get("cursor"); c != "" {
+			fmt.Sscanf(c, "page-%d", &i)
+		}
+		next := fmt.Sprintf("page-%d", i+1)
+		if i+1 == pages {
+			next = ""
+		}
+		_, _ = w.Write([]byte(render(next, i+1 == pages, i)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), queries...)
+	}
+}
+
+func TestApiClient_OrdersIter(t *testing.T) {
+	srv, queries := pagedServer(t, func(cursor string, last bool, i int) string {
+		return fmt.Sprintf(`{"orders":[{"order_id":"%d-a"},{"order_id":"%d-b"}],"has_next":%v,"cursor":"%s"}`, i, i, !last, cursor)
+	}, 3)
+	api := NewApiClient("api_key", testSecretKey(t), "portfolio_id")
+	api.SetBaseUrlV3(srv.URL)
+
+	orders, err := api.OrdersIter(ListOrdersQuery{ProductId: "BTC-USD"}, 0).All(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(orders) != 6 || orders[0].OrderId != "0-a" || orders[5].OrderId != "2-b" {
+		t.Errorf("Expected the orders of all 3 pages, got %v", orders)
+	}
+	q := queries()
+	if len(q) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(q))
+	}
+	if q[0] != "product_id=BTC-USD&limit=100" || q[2] != "product_id=BTC-USD&limit=100&cursor=page-2" {
+		t.Errorf("Unexpected queries %v", q)
+	}
+}
+
+func TestApiClient_OrdersIterBound(t *testing.T) {
+	srv, queries := pagedServer(t, func(cursor string, last bool, i int) string {
+		return fmt.Sprintf(`{"orders":[{"order_id":"%d-a"},{"order_id":"%d-b"}],"has_next":%v,"cursor":"%s"}`, i, i, !last, cursor)
+	}, 3)
+	api := NewApiClient("api_key", testSecretKey(t), "portfolio_id")
+	api.SetBaseUrlV3(srv.URL)
+
+	it := api.OrdersIter(ListOrdersQuery{}, 3)
+	orders, err := it.All(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(orders) != 3 || orders[2].OrderId != "1-a" {
+		t.Errorf("Expected the first 3 orders, got %v", orders)
+	}
+	if it.Next(context.Background()) {
+		t.Errorf("Expected the iterator to stop at the bound")
+	}
+	if q := queries(); len(q) != 2 || q[0] != "limit=3" {
+		t.Errorf("Expected 2 requests of 3 orders, got %v", q)
+	}
+}
+
+func TestApiClient_FillsIter(t *testing.T) {
+	// the last page repeats its cursor instead of returning an empty one
+	srv, queries := pagedServer(t, func(cursor string, last bool, i int) string {
+		if last {
+			cursor = fmt.Sprintf("page-%d", i)
+		}
+		return fmt.Sprintf(`{"fills":[{"trade_id":"%d-a"},{"trade_id":"%d-b"}],"cursor":"%s"}`, i, i, cursor)
+	}, 2)
+	api := NewApiClient("api_key", testSecretKey(t), "portfolio_id")
+	api.SetBaseUrlV3(srv.URL)
+
+	fills, err := api.FillsIter(ListFillsQuery{ProductId: "BTC-USD"}, 0).All(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(fills) != 4 || fills[3].TradeId != "1-b" {
+		t.Errorf("Expected the fills of both pages, got %v", fills)
+	}
+	if q := queries(); len(q) != 2 {
+		t.Errorf("Expected 2 requests, got %v", q)
+	}
+}
+
+func TestApiClient_IterErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("cursor") != "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"fills":[{"trade_id":"a"}],"cursor":"next"}`))
+	}))
+	defer srv.Close()
+	api := NewApiClient("api_key", testSecretKey(t), "portfolio_id")
+	api.SetBaseUrlV3(srv.URL)
+
+	it := api.FillsIter(ListFillsQuery{}, 0)
+	if !it.Next(context.Background()) || it.Value().TradeId != "a" {
+		t.Fatalf("Expected the fill of the first page, got %v", it.Err())
+	}
+	if it.Next(context.Background()) {
+		t.Errorf("Expected the iterator to stop at the failed page")
+	}
+	if !errors.Is(it.Err(), ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized, got %v", it.Err())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	it = api.FillsIter(ListFillsQuery{}, 0)
+	if it.Next(ctx) {
+		t.Errorf("Expected a cancelled context to stop the iterator")
+	}
+	if !errors.Is(it.Err(), context.Canceled) {
+		t.Errorf("Expected %v, got %v", context.Canceled, it.Err())
+	}
+}
+
+func TestApiClient_AccountsIter(t *testing.T) {
+	srv, queries := pagedServer(t, func(cursor string, last bool, i int) string {
+		return fmt.Sprintf(`{"accounts":[{"uuid":"%d-a","currency":"BTC"},{"uuid":"%d-b","currency":"USD"}],"has_next":%v,"cursor":"%s","size":2}`, i, i, !last, cursor)
+	}, 2)
+	api := NewApiClient("api_key", testSecretKey(t), "portfolio_id")
+	api.SetBaseUrlV3(srv.URL)
+
+	accounts, err := api.AccountsIter(0).All(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(accounts) != 4 || accounts[3].Uuid != "1-b" || accounts[3].Currency != "USD" {
+		t.Errorf("Expected the accounts of both pages, got %v", accounts)
+	}
+	if q := queries(); len(q) != 2 || q[1] != "limit=250&cursor=page-1" {
+		t.Errorf("Unexpected queries %v", q)
+	}
+}
@@ -1,26 +1,28 @@
 package coinbasev3
 
+import "context"
+
 // GetBuyPrice get the total price to buy a currency.
-func (c *ApiClient) GetBuyPrice(pair string) (CurrencyPairPrice, error) {
-	return c.getPairPrice(pair, "buy")
+func (c *ApiClient) GetBuyPrice(ctx context.Context, pair string) (CurrencyPairPrice, error) {
+	return c.getPairPrice(ctx, pair, "buy")
 }
 
 // GetSellPrice get the total price to sell a currency.
-func (c *ApiClient) GetSellPrice(pair string) (CurrencyPairPrice, error) {
-	return c.getPairPrice(pair, "sell")
+func (c *ApiClient) GetSellPrice(ctx context.Context, pair string) (CurrencyPairPrice, error) {
+	return c.getPairPrice(ctx, pair, "sell")
 }
 
 // GetSpotPrice get the current market price of a currency.
-func (c *ApiClient) GetSpotPrice(pair string) (CurrencyPairPrice, error) {
-	return c.getPairPrice(pair, "spot")
+func (c *ApiClient) GetSpotPrice(ctx context.Context, pair string) (CurrencyPairPrice, error) {
+	return c.getPairPrice(ctx, pair, "spot")
 }
 
 // getPairPrice get the price of a currency pair.
-func (c *ApiClient) getPairPrice(pair string, side string) (CurrencyPairPrice, error) {
+func (c *ApiClient) getPairPrice(ctx context.Context, pair string, side string) (CurrencyPairPrice, error) {
 	u := "https://api.coinbase.com/v2/prices/{currency_pair}/{side}"
 
 	var price CurrencyPairPrice
-	resp, err := c.client.R().
+	resp, err := c.client.R().SetContext(ctx).
 		SetPathParam("currency_pair", pair).
 		SetPathParam("side", side).
 		SetSuccessResult(&price).Get(u)
@@ -29,7 +31,7 @@ func (c *ApiClient) getPairPrice(pair string, side string) (CurrencyPairPrice, e
 	}
 
 	if !resp.IsSuccessState() {
-		return price, ErrFailedToUnmarshal
+		return price, newResponseError(resp)
 	}
 
 	return price, nil
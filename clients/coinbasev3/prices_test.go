@@ -1,27 +1,39 @@
 package coinbasev3
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestApiClient_GetBuyPrice(t *testing.T) {
-	api := NewApiClient("api_key", "portfolio_api", "secret_key")
-	_, err := api.GetBuyPrice("BTC-USD")
+	api := replayClient(t, "buy_price")
+	price, err := api.GetBuyPrice(context.Background(), "BTC-USD")
 	if err != nil {
-		t.Errorf("Expected no error, got %s", err)
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if price.Data.Amount != "37712.48" || price.Data.Currency != "USD" {
+		t.Errorf("Expected 37712.48 USD, got %s %s", price.Data.Amount, price.Data.Currency)
 	}
 }
 
 func TestApiClient_GetSellPrice(t *testing.T) {
-	api := NewApiClient("api_key", "portfolio_api", "secret_key")
-	_, err := api.GetSellPrice("BTC-USD")
+	api := replayClient(t, "sell_price")
+	price, err := api.GetSellPrice(context.Background(), "BTC-USD")
 	if err != nil {
-		t.Errorf("Expected no error, got %s", err)
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if price.Data.Amount != "37341.90" {
+		t.Errorf("Expected 37341.90, got %s", price.Data.Amount)
 	}
 }
 
 func TestApiClient_GetSpotPrice(t *testing.T) {
-	api := NewApiClient("portfolio_api", "api_key", "secret_key")
-	_, err := api.GetSpotPrice("BTC-USD")
+	api := replayClient(t, "spot_price")
+	price, err := api.GetSpotPrice(context.Background(), "BTC-USD")
 	if err != nil {
-		t.Errorf("Expected no error, got %s", err)
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if price.Data.Amount != "37527.15" {
+		t.Errorf("Expected 37527.15, got %s", price.Data.Amount)
 	}
 }
@@ -11,13 +11,13 @@ func (c *ApiClient) GetProduct(ctx context.Context, productId string) (Product,
 	u := c.makeV3Url(fmt.Sprintf("/brokerage/products/%s", productId))
 
 	var data Product
-	resp, err := c.httpClient.Get(u)
+	resp, err := c.httpClient.Get(ctx, u)
 	if err != nil {
 		return data, err
 	}
 
 	if !resp.IsSuccessState() {
-		return data, ErrFailedToUnmarshal
+		return data, newResponseError(resp)
 	}
 
 	err = resp.Unmarshal(&data)
@@ -93,17 +93,17 @@ type PerpetualDetails struct {
 }
 
 // GetProducts gets a list of available currency pairs for trading.
-func (c *ApiClient) GetProducts() ([]Products, error) {
+func (c *ApiClient) GetProducts(ctx context.Context) ([]Products, error) {
 	u := c.makeExchangeUrl("/products")
 
 	var data []Products
-	resp, err := c.httpClient.Get(u)
+	resp, err := c.httpClient.Get(ctx, u)
 	if err != nil {
 		return nil, err
 	}
 
 	if !resp.IsSuccessState() {
-		return nil, ErrFailedToUnmarshal
+		return nil, newResponseError(resp)
 	}
 
 	err = resp.Unmarshal(&data)
@@ -150,17 +150,17 @@ const (
 )
 
 // GetProductCandles get rates for a single product by product ID, grouped in buckets.
-func (c *ApiClient) GetProductCandles(productId, start, end string, granularity Granularity) ([]ProductCandles, error) {
+func (c *ApiClient) GetProductCandles(ctx context.Context, productId, start, end string, granularity Granularity) ([]ProductCandles, error) {
 	u := c.makeV3Url(fmt.Sprintf("/brokerage/products/%s/candles?start=%s&end=%s&granularity=%s", productId, start, end, granularity))
 
 	var data ProductCandlesData
-	resp, err := c.httpClient.Get(u)
+	resp, err := c.httpClient.Get(ctx, u)
 	if err != nil {
 		return data.Candles, err
 	}
 
 	if !resp.IsSuccessState() {
-		return data.Candles, ErrFailedToUnmarshal
+		return data.Candles, newResponseError(resp)
 	}
 
 	err = resp.Unmarshal(&data)
@@ -185,17 +185,17 @@ type ProductCandles struct {
 }
 
 // GetMarketTrades get snapshot information, by product ID, about the last trades (ticks), best bid/ask, and 24h volume.
-func (c *ApiClient) GetMarketTrades(productId string, limit int32) (MarketTradesData, error) {
+func (c *ApiClient) GetMarketTrades(ctx context.Context, productId string, limit int32) (MarketTradesData, error) {
 	u := c.makeV3Url(fmt.Sprintf("/brokerage/products/%s/ticker?limit=%d", productId, limit))
 
 	var data MarketTradesData
-	resp, err := c.httpClient.Get(u)
+	resp, err := c.httpClient.Get(ctx, u)
 	if err != nil {
 		return data, err
 	}
 
 	if !resp.IsSuccessState() {
-		return data, ErrFailedToUnmarshal
+		return data, newResponseError(resp)
 	}
 
 	err = resp.Unmarshal(&data)
@@ -213,12 +213,12 @@ type MarketTradesData struct {
 }
 
 // GetProductBook get a list of bids/asks for a single product. The amount of detail shown can be customized with the limit parameter.
-func (c *ApiClient) GetProductBook(productId string, limit int32) (ProductBookData, error) {
+func (c *ApiClient) GetProductBook(ctx context.Context, productId string, limit int32) (ProductBookData, error) {
 	u := c.makeV3Url(fmt.Sprintf("/brokerage/product_book?product_id=%s&limit=%d", productId, limit))
 
 	var data ProductBookData
-	if res, err := c.get(u, &data); err != nil {
-		return data, newResponseError(res)
+	if err := c.get(ctx, u, &data); err != nil {
+		return data, err
 	}
 	return data, nil
 }
@@ -240,7 +240,7 @@ type PriceBookOrder struct {
 }
 
 // GetBestBidAsk get the best bid/ask for all products. A subset of all products can be returned instead by using the product_ids input.
-func (c *ApiClient) GetBestBidAsk(productIds []string) (BestBidAskData, error) {
+func (c *ApiClient) GetBestBidAsk(ctx context.Context, productIds []string) (BestBidAskData, error) {
 	query := strings.Join(productIds, "&product_ids=")
 	if query != "" {
 		query = "product_ids=" + query
@@ -250,8 +250,8 @@ func (c *ApiClient) GetBestBidAsk(productIds []string) (BestBidAskData, error) {
 
 	u := c.makeV3Url(fmt.Sprintf("/brokerage/best_bid_ask?%s", query))
 	var data BestBidAskData
-	if res, err := c.get(u, &data); err != nil {
-		return data, newResponseError(res)
+	if err := c.get(ctx, u, &data); err != nil {
+		return data, err
 	}
 	return data, nil
 }
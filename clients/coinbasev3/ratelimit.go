@@ -0,0 +1,74 @@
+package coinbasev3
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sberserker/dcagdax/clients/transport"
+)
+
+// rateLimits are the Advanced Trade API limits, 30 requests per second for private and 10 for public endpoints.
+var rateLimits = map[string]transport.Limit{
+	"private": {Rate: 30, Burst: 30},
+	"public":  {Rate: 10, Burst: 10},
+}
+
+// newLimiter creates a rate limiter for the Advanced Trade API, shared by the clients of an ApiClient.
+func newLimiter() *transport.Limiter {
+	return transport.NewLimiter(rateLimits, endpointClass)
+}
+
+// endpointClass returns the rate limit class of a request.
+func endpointClass(r *http.Request) string {
+	if strings.Contains(r.URL.Path, "/brokerage/market/") || !strings.Contains(r.URL.Path, "/brokerage/") {
+		return "public"
+	}
+	return "private"
+}
+
+// newTransport wraps base with the shared rate limits and retries. sign is nil for clients which sign requests
+// themselves.
+func newTransport(base http.RoundTripper, limiter *transport.Limiter, sign func(*http.Request) error) *transport.Transport {
+	return transport.New(base, transport.Config{
+		Limiter:   limiter,
+		Retryable: retryableOrder,
+		Sign:      sign,
+	})
+}
+
+// retryableOrder allows retrying order placements with a client order id, Coinbase returns the existing order instead
+// of placing a duplicate.
+func retryableOrder(r *http.Request) bool {
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/brokerage/orders") || r.GetBody == nil {
+		return false
+	}
+
+	body, err := r.GetBody()
+	if err != nil {
+		return false
+	}
+	defer body.Close()
+
+	var order struct {
+		ClientOrderId string `json:"client_order_id"`
+	}
+	if err := json.NewDecoder(body).Decode(&order); err != nil {
+		return false
+	}
+	return order.ClientOrderId != ""
+}
+
+// jwtSigner signs every attempt of a request with a fresh JWT.
+func jwtSigner(apiKey, secretKey string) func(*http.Request) error {
+	return func(r *http.Request) error {
+		uri := fmt.Sprintf("%s %s%s", r.Method, r.URL.Host, r.URL.Path)
+		jwt, err := BuildJWT(uri, apiKey, secretKey)
+		if err != nil {
+			return err
+		}
+		r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", jwt))
+		return nil
+	}
+}
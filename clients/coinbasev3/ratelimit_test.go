@@ -0,0 +1,128 @@
+package coinbasev3
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testSecretKey returns a PEM encoded EC key BuildJWT can sign with.
+func testSecretKey(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+}
+
+// flakyServer fails the first requests with status and records the authorization headers.
+type flakyServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	failures int
+	status   int
+	auth     []string
+}
+
+func newFlakyServer(t *testing.T, failures, status int, body string) *flakyServer {
+	s := &flakyServer{failures: failures, status: status}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		s.auth = append(s.auth, r.Header.Get("Authorization"))
+		fail := len(s.auth) <= s.failures
+		s.mu.Unlock()
+
+		if fail {
+			w.WriteHeader(s.status)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *flakyServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.auth...)
+}
+
+func TestApiClient_Retry(t *testing.T) {
+	srv := newFlakyServer(t, 1, http.StatusServiceUnavailable, `{"order":{"order_id":"abc","status":"FILLED"}}`)
+	api := NewApiClient("api_key", testSecretKey(t), "portfolio_id")
+	api.SetBaseUrlV3(srv.URL)
+
+	order, err := api.GetOrder(context.Background(), "abc")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if order.Status != "FILLED" {
+		t.Errorf("Expected status FILLED, got %s", order.Status)
+	}
+
+	auth := srv.requests()
+	if len(auth) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(auth))
+	}
+	if !strings.HasPrefix(auth[0], "Bearer ") || auth[0] == auth[1] {
+		t.Errorf("Expected every attempt to be signed with a fresh JWT, got %v", auth)
+	}
+}
+
+func TestApiClient_RetryOrder(t *testing.T) {
+	body := `{"success":true,"success_response":{"order_id":"abc","client_order_id":"client-1"}}`
+
+	srv := newFlakyServer(t, 1, http.StatusBadGateway, body)
+	api := NewApiClient("api_key", testSecretKey(t), "portfolio_id")
+	api.SetBaseUrlV3(srv.URL)
+
+	data, err := api.CreateOrder(context.Background(), CreateOrderRequest{ClientOrderID: "client-1", ProductID: "BTC-USD", Side: "BUY"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data.SuccessResponse.OrderId != "abc" || len(srv.requests()) != 2 {
+		t.Errorf("Expected the order with a client order id to be retried, got %+v after %d requests", data, len(srv.requests()))
+	}
+
+	// without a client order id a retry could place the order twice
+	srv = newFlakyServer(t, 1, http.StatusBadGateway, body)
+	api.SetBaseUrlV3(srv.URL)
+	if _, err := api.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "BTC-USD", Side: "BUY"}); err == nil {
+		t.Errorf("Expected the failed order to be returned")
+	}
+	if len(srv.requests()) != 1 {
+		t.Errorf("Expected 1 request, got %d", len(srv.requests()))
+	}
+}
+
+func TestEndpointClass(t *testing.T) {
+	tests := map[string]string{
+		"https://api.coinbase.com/api/v3/brokerage/orders":                  "private",
+		"https://api.coinbase.com/api/v3/brokerage/accounts":                "private",
+		"https://api.coinbase.com/api/v3/brokerage/market/products/BTC-USD": "public",
+		"https://api.exchange.coinbase.com/products/BTC-USD/candles":        "public",
+	}
+	for u, expected := range tests {
+		r, _ := http.NewRequest(http.MethodGet, u, nil)
+		if class := endpointClass(r); class != expected {
+			t.Errorf("Expected %s to be %s, got %s", u, expected, class)
+		}
+	}
+}
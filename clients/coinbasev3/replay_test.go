@@ -0,0 +1,40 @@
+package coinbasev3
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sberserker/dcagdax/clients/transport"
+)
+
+var recordFixtures = flag.Bool("record", false, "record the fixtures in testdata against the live API with COINBASE_KEY and COINBASE_SECRET")
+
+// replayClient returns a client replaying the fixture testdata/<name>.json, or recording it with -record. Every
+// recorded request has to be replayed.
+func replayClient(t *testing.T, name string) *ApiClient {
+	mode := transport.Replay
+	key, secret := "organizations/test/apiKeys/test", testSecretKey(t)
+	if *recordFixtures {
+		mode = transport.Record
+		key, secret = os.Getenv("COINBASE_KEY"), os.Getenv("COINBASE_SECRET")
+	}
+
+	recorder, err := transport.NewRecorder(filepath.Join("testdata", name+".json"), transport.RecorderConfig{Mode: mode, Secrets: []string{key}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	t.Cleanup(func() {
+		if err := recorder.Save(); err != nil {
+			t.Error(err)
+		}
+		if unused := recorder.Unused(); len(unused) > 0 {
+			t.Errorf("Expected every recorded request to be replayed, got %d unused", len(unused))
+		}
+	})
+
+	api := NewApiClient(key, secret, "portfolio_id")
+	api.SetTransport(recorder)
+	return api
+}
@@ -1,6 +1,8 @@
 package coinbasev3
 
 import (
+	"context"
+
 	"github.com/imroc/req/v3"
 )
 
@@ -10,11 +12,11 @@ type MockHttpClient struct {
 	client   *req.Client
 }
 
-func (m *MockHttpClient) Get(url string) (*req.Response, error) {
+func (m *MockHttpClient) Get(ctx context.Context, url string) (*req.Response, error) {
 	return m.Response, m.Err
 }
 
-func (m *MockHttpClient) Post(url string, data []byte) (*req.Response, error) {
+func (m *MockHttpClient) Post(ctx context.Context, url string, data []byte) (*req.Response, error) {
 	return m.Response, m.Err
 }
 
@@ -1,11 +1,17 @@
 package coinbasev3
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestApiClient_GetServerTime(t *testing.T) {
-	api := NewApiClient("api_key", "secret_key")
-	_, err := api.GetServerTime()
+	api := replayClient(t, "server_time")
+	st, err := api.GetServerTime(context.Background())
 	if err != nil {
-		t.Errorf("Expected no error, got %s", err)
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if st.Data.Epoch != 1701189157 || st.Data.Iso.Unix() != 1701189157 {
+		t.Errorf("Expected 1701189157, got %d and %s", st.Data.Epoch, st.Data.Iso)
 	}
 }
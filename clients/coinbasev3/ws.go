@@ -6,6 +6,8 @@ import (
 	"github.com/gorilla/websocket"
 	"log"
 	"math/rand"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -20,20 +22,22 @@ var (
 	ErrNoApiKey           = fmt.Errorf("no api key provided")
 	ErrNoSecretKey        = fmt.Errorf("no secret key provided")
 	ErrNotConnected       = fmt.Errorf("not connected")
+	ErrShutdown           = fmt.Errorf("websocket client is shut down")
 )
 
 // WsClientConfig is the configuration struct for creating a new websocket restClient.
 type WsClientConfig struct {
-	Url          string             // optional. defaults to "wss://advanced-trade-ws.coinbase.com"
-	ReadChannel  chan []byte        // required for receiving messages from the websocket connection
-	WsChannels   []WebsocketChannel // required for subscribing to innerChannels on the websocket connection
-	ApiKey       string             // required for signing websocket messages
-	SecretKey    string             // required for signing websocket messages
-	OnConnect    func()             // optional. called when the websocket connection is established
-	OnDisconnect func()             // optional. called when the websocket connection is closed
-	OnReconnect  func()             // optional. called when the websocket connection is re-established
-	UseBackoff   bool               // optional. defaults to false. uses an exponential backoff strategy with jitter
-	Debug        bool               // optional. defaults to false. prints debug messages
+	Url              string             // optional. defaults to "wss://advanced-trade-ws.coinbase.com"
+	ReadChannel      chan []byte        // required for receiving messages from the websocket connection
+	WsChannels       []WebsocketChannel // required for subscribing to innerChannels on the websocket connection
+	ApiKey           string             // required for signing websocket messages
+	SecretKey        string             // required for signing websocket messages
+	OnConnect        func()             // optional. called when the websocket connection is established
+	OnDisconnect     func()             // optional. called when the websocket connection is closed
+	OnReconnect      func()             // optional. called when the websocket connection is re-established
+	UseBackoff       bool               // optional. defaults to false. uses an exponential backoff strategy with jitter
+	HeartbeatTimeout time.Duration      // optional. reconnects when no message arrives within the timeout, subscribes to the heartbeats channel. 0 disables
+	Debug            bool               // optional. defaults to false. prints debug messages
 }
 
 func NewWsClientConfig(apiKey, secretKey string, readCh chan []byte, wsChannels []WebsocketChannel) WsClientConfig {
@@ -74,26 +78,33 @@ func (c *WsClientConfig) tryValidate() error {
 	return nil
 }
 
-// WsClient is an automatically reconnecting websocket restClient.
+// WsClient is an automatically reconnecting websocket restClient. The subscriptions of the config and those changed
+// with Subscribe and Unsubscribe are sent again on every reconnect.
 type WsClient struct {
+	url              string
+	innerChannels    channels
+	cbs              callbacks
+	useBackoff       bool
+	heartbeatTimeout time.Duration
+	debug            bool
+	apiKey           string
+	secretKey        string
+	ctx              context.Context
+	cancel           context.CancelFunc
+
+	mu            sync.Mutex // guards conn, subscriptions and isShutdown, held while writing to conn
 	conn          *websocket.Conn
-	url           string
-	wsChannels    []WebsocketChannel
-	innerChannels channels
-	cbs           callbacks
+	subscriptions map[ChannelType]map[string]bool // product ids by channel, empty for channels without products
 	isShutdown    bool
-	useBackoff    bool
-	debug         bool
-	apiKey        string
-	secretKey     string
-	ctx           context.Context
-	cancel        context.CancelFunc
+
+	wg           sync.WaitGroup // the reconnect loop and the readers
+	shutdownOnce sync.Once
 }
 
 // channels contains the read channel given by the developer and the internal reconnection channel.
 type channels struct {
 	read  chan []byte
-	recon chan bool
+	recon chan struct{}
 }
 
 // callbacks contains the callbacks used by the developer to handle events.
@@ -113,64 +124,108 @@ func NewWsClient(cfg WsClientConfig) (*WsClient, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	c := &WsClient{
-		conn: nil,
-		url:  cfg.Url,
+		url: cfg.Url,
 		innerChannels: channels{
 			read:  cfg.ReadChannel,
-			recon: make(chan bool),
+			recon: make(chan struct{}, 1),
 		},
 		cbs: callbacks{
 			onConnect:    cfg.OnConnect,
 			onDisconnect: cfg.OnDisconnect,
 			onReconnect:  cfg.OnReconnect,
 		},
-		wsChannels: cfg.WsChannels,
-		apiKey:     cfg.ApiKey,
-		secretKey:  cfg.SecretKey,
-		ctx:        ctx,
-		cancel:     cancel,
-		useBackoff: cfg.UseBackoff,
-		debug:      cfg.Debug,
+		subscriptions:    map[ChannelType]map[string]bool{},
+		apiKey:           cfg.ApiKey,
+		secretKey:        cfg.SecretKey,
+		ctx:              ctx,
+		cancel:           cancel,
+		useBackoff:       cfg.UseBackoff,
+		heartbeatTimeout: cfg.HeartbeatTimeout,
+		debug:            cfg.Debug,
+	}
+
+	for _, ch := range cfg.WsChannels {
+		if ch.Type != SubTypeUnsubscribe {
+			c.subscribe(ch.Channel, ch.ProductIds)
+		}
+	}
+	// heartbeats keep the connection busy, without them a quiet channel would trip the watchdog
+	if c.heartbeatTimeout > 0 && c.subscriptions[ChannelTypeHeartbeats] == nil {
+		c.subscribe(ChannelTypeHeartbeats, nil)
 	}
 
-	go c.initReconnectChannel()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.initReconnectChannel()
+	}()
 	return c, nil
 }
 
+// Run connects to the websocket server and keeps the connection until the context is cancelled, then shuts the
+// client down. Returns nil when the client was shut down by Shutdown. A failed first connection shuts the client down
+// as well and returns the dial error.
+func (c *WsClient) Run(ctx context.Context) error {
+	if _, err := c.Connect(); err != nil {
+		c.Shutdown()
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		c.Shutdown()
+		return ctx.Err()
+	case <-c.ctx.Done():
+		return nil
+	}
+}
+
 // ConnectWithUrl connects to the websocket server using the provided url.
 func (c *WsClient) ConnectWithUrl(url string) (*websocket.Conn, error) {
 	if c.url == "" {
 		c.url = url
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
+	ctx, cancel := context.WithTimeout(c.ctx, connectionTimeout)
 	defer cancel()
 
 	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	c.conn = conn
 
-	err = c.subscribeToChannels()
+	c.mu.Lock()
+	if c.isShutdown {
+		c.mu.Unlock()
+		_ = conn.Close()
+		return nil, ErrShutdown
+	}
+
+	// subscribing under the lock keeps Subscribe calls from slipping in between the resubscription and the new conn
+	err = c.subscribeToChannels(conn)
 	if err != nil {
-		_ = c.conn.Close()
+		c.mu.Unlock()
+		_ = conn.Close()
 		return nil, err
 	}
+	c.conn = conn
+	c.wg.Add(1)
+	c.mu.Unlock()
 
 	c.cbs.onConnect()
-	go c.read()
+	go func() {
+		defer c.wg.Done()
+		c.read(conn)
+	}()
 	return conn, nil
 }
 
-// subscribeToChannels subscribes to the provided channels on the websocket connection.
-func (c *WsClient) subscribeToChannels() error {
-	if len(c.wsChannels) > 0 {
-		for ch := range c.wsChannels {
-			err := c.Write(c.wsChannels[ch].marshal(c.apiKey, c.secretKey))
-			if err != nil {
-				return err
-			}
+// subscribeToChannels sends the tracked subscriptions on conn. The caller holds mu.
+func (c *WsClient) subscribeToChannels(conn *websocket.Conn) error {
+	for _, ch := range c.subscriptionChannels() {
+		err := conn.WriteMessage(websocket.TextMessage, ch.marshal(c.apiKey, c.secretKey))
+		if err != nil {
+			return err
 		}
 	}
 
@@ -185,8 +240,101 @@ func (c *WsClient) Connect() (*websocket.Conn, error) {
 	return c.ConnectWithUrl(c.url)
 }
 
-// Write writes data to the websocket connection.
+// Subscribe subscribes to the products of a channel. The subscription is kept for reconnects even when sending it
+// fails, it is sent with the next connection when the client is not connected.
+func (c *WsClient) Subscribe(channel ChannelType, productIds []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.isShutdown {
+		return ErrShutdown
+	}
+
+	c.subscribe(channel, productIds)
+	return c.writeChannel(NewChannelSubscribe(channel, productIds))
+}
+
+// Unsubscribe unsubscribes from the products of a channel, or from the whole channel without product ids.
+func (c *WsClient) Unsubscribe(channel ChannelType, productIds []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.isShutdown {
+		return ErrShutdown
+	}
+
+	products := c.subscriptions[channel]
+	if len(productIds) == 0 {
+		productIds = sortedKeys(products)
+		delete(c.subscriptions, channel)
+	} else {
+		for _, p := range productIds {
+			delete(products, p)
+		}
+		if len(products) == 0 {
+			delete(c.subscriptions, channel)
+		}
+	}
+
+	return c.writeChannel(NewChannelUnsubscribe(channel, productIds))
+}
+
+// Subscriptions returns the subscriptions sent on every connect, ordered by channel.
+func (c *WsClient) Subscriptions() []WebsocketChannel {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subscriptionChannels()
+}
+
+// subscribe adds products to the tracked subscriptions. The caller holds mu.
+func (c *WsClient) subscribe(channel ChannelType, productIds []string) {
+	products, ok := c.subscriptions[channel]
+	if !ok {
+		products = map[string]bool{}
+		c.subscriptions[channel] = products
+	}
+	for _, p := range productIds {
+		products[p] = true
+	}
+}
+
+// subscriptionChannels returns a subscribe message per tracked channel. The caller holds mu.
+func (c *WsClient) subscriptionChannels() []WebsocketChannel {
+	names := make([]string, 0, len(c.subscriptions))
+	for ch := range c.subscriptions {
+		names = append(names, string(ch))
+	}
+	sort.Strings(names)
+
+	subs := make([]WebsocketChannel, 0, len(names))
+	for _, name := range names {
+		ch := ChannelType(name)
+		subs = append(subs, NewChannelSubscribe(ch, sortedKeys(c.subscriptions[ch])))
+	}
+	return subs
+}
+
+// writeChannel sends a (un)subscribe message when connected. The caller holds mu.
+func (c *WsClient) writeChannel(ch WebsocketChannel) error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.WriteMessage(websocket.TextMessage, ch.marshal(c.apiKey, c.secretKey))
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Write writes data to the websocket connection. Subscriptions written here are not sent again on reconnect, use
+// Subscribe instead.
 func (c *WsClient) Write(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if c.conn == nil {
 		return ErrNotConnected
 	}
@@ -202,16 +350,21 @@ func (c *WsClient) Write(data []byte) error {
 }
 
 // Shutdown closes the websocket connection. This will also close the read channel and the underlying reconnect channel.
+// It waits for the reader and the reconnect loop to stop, so the read channel is closed after the last message.
 func (c *WsClient) Shutdown() {
-	c.cancel()
+	c.shutdownOnce.Do(func() {
+		c.cancel()
 
-	c.isShutdown = true
-	close(c.innerChannels.read)
-	close(c.innerChannels.recon)
-	err := c.conn.Close()
-	if err != nil {
-		return
-	}
+		c.mu.Lock()
+		c.isShutdown = true
+		if c.conn != nil {
+			_ = c.conn.Close()
+		}
+		c.mu.Unlock()
+
+		c.wg.Wait()
+		close(c.innerChannels.read)
+	})
 }
 
 // ReadChan returns the channel that receives messages from the websocket connection.
@@ -219,7 +372,15 @@ func (c *WsClient) ReadChan() chan []byte {
 	return c.innerChannels.read
 }
 
+// connection returns the current connection.
+func (c *WsClient) connection() *websocket.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn
+}
+
 // initReconnectChannel will attempt to reconnect to the websocket server using an exponential backoff strategy with jitter.
+// A failed attempt is retried until it succeeds or the client is shut down.
 func (c *WsClient) initReconnectChannel() {
 	backoff := initialBackoff
 	for {
@@ -230,54 +391,82 @@ func (c *WsClient) initReconnectChannel() {
 			}
 			return
 		case <-c.innerChannels.recon:
-			_ = c.conn.Close()
+		}
 
+		for {
 			if c.useBackoff {
 				jitter := time.Duration(rand.Intn(1000)) * time.Millisecond
-				time.Sleep(backoff + jitter)
+				select {
+				case <-c.ctx.Done():
+					return
+				case <-time.After(backoff + jitter):
+				}
 			}
 
 			_, err := c.Connect()
-			if err != nil {
-				c.printf("Reconnection attempt failed: %s\n", err)
-				backoff = calculateBackoff(backoff, maxBackoff)
-				continue
+			if err == nil {
+				break
+			}
+			if c.ctx.Err() != nil {
+				return
 			}
 
-			backoff = initialBackoff
-			c.cbs.onReconnect()
+			c.printf("Reconnection attempt failed: %s\n", err)
+			backoff = calculateBackoff(backoff, maxBackoff)
+			if !c.useBackoff {
+				// without backoff a failing server would be dialled in a busy loop
+				select {
+				case <-c.ctx.Done():
+					return
+				case <-time.After(initialBackoff):
+				}
+			}
 		}
+
+		backoff = initialBackoff
+		c.cbs.onReconnect()
 	}
 }
 
-// read reads messages from the websocket connection. It will attempt to reconnect if the connection is closed.
-func (c *WsClient) read() {
+// read reads messages from the websocket connection. It will attempt to reconnect if the connection is closed, or
+// when no message arrived within the heartbeat timeout.
+func (c *WsClient) read(conn *websocket.Conn) {
 	defer func() {
 		c.cbs.onDisconnect()
-		if err := c.conn.Close(); err != nil {
+		if err := conn.Close(); err != nil {
 			c.printf("Error closing WebSocket connection: %s\n", err)
 		}
 	}()
 
 	for {
-		if c.isShutdown {
-			return
+		if c.heartbeatTimeout > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(c.heartbeatTimeout))
 		}
 
-		_, message, err := c.conn.ReadMessage()
+		_, message, err := conn.ReadMessage()
 		if err != nil {
+			if c.ctx.Err() != nil {
+				return
+			}
+
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				c.printf("WebSocket closed unexpectedly: %v\n", err)
 			} else {
 				c.printf("WebSocket read error: %v\n", err)
 			}
-			if !c.isShutdown {
-				c.innerChannels.recon <- true
+
+			select {
+			case c.innerChannels.recon <- struct{}{}:
+			default:
 			}
 			return
 		}
 
-		c.innerChannels.read <- message
+		select {
+		case c.innerChannels.read <- message:
+		case <-c.ctx.Done():
+			return
+		}
 	}
 }
 
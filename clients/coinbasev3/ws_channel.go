@@ -35,6 +35,7 @@ type WebsocketChannel struct {
 	ApiKey     string      `json:"api_key"`
 	SecretKey  string      `json:"-"`
 	Timestamp  string      `json:"timestamp"`
+	Jwt        string      `json:"jwt,omitempty"` // set for cloud api keys, which sign with a private key
 }
 
 func NewWebsocketChannel(subType SubType, channel ChannelType, productIds []string) WebsocketChannel {
@@ -88,6 +89,7 @@ func (s *WebsocketChannel) marshal(apiKey, secretKey string) []byte {
 
 	s.setTimestamp()
 	s.setSignature()
+	s.setJwt()
 
 	b, err := json.Marshal(s)
 	if err != nil {
@@ -106,3 +108,16 @@ func (s *WebsocketChannel) setSignature() {
 func (s *WebsocketChannel) setTimestamp() {
 	s.Timestamp = fmt.Sprintf("%d", int(time.Now().Unix()))
 }
+
+// setJwt signs the message with a JWT when the secret key is the private key of a cloud api key.
+func (s *WebsocketChannel) setJwt() {
+	if !strings.Contains(s.SecretKey, "PRIVATE KEY") {
+		return
+	}
+
+	jwt, err := BuildJWT("", s.ApiKey, s.SecretKey)
+	if err != nil {
+		return
+	}
+	s.Jwt = jwt
+}
@@ -1,6 +1,13 @@
 package coinbasev3
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
 	"testing"
 )
 
@@ -14,3 +21,33 @@ func TestNewWsFeedSubscription(t *testing.T) {
 		t.Errorf("Expected 1 product id, got %d", len(feed.ProductIds))
 	}
 }
+
+func TestWebsocketChannel_Jwt(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+
+	feed := NewUserChannel(nil)
+	var msg WebsocketChannel
+	if err := json.Unmarshal(feed.marshal("organizations/1/apiKeys/2", secret), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(strings.Split(msg.Jwt, ".")) != 3 {
+		t.Errorf("Expected a jwt for a cloud api key, got %q", msg.Jwt)
+	}
+
+	feed = NewUserChannel(nil)
+	msg = WebsocketChannel{}
+	if err := json.Unmarshal(feed.marshal("key", "secret"), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Jwt != "" || msg.Signature == "" {
+		t.Errorf("Expected only a signature for a legacy api key, got %q", msg.Jwt)
+	}
+}
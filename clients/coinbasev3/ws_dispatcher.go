@@ -0,0 +1,241 @@
+package coinbasev3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultDispatcherQueueSize = 100 // events buffered per handler
+
+// DispatcherConfig is the configuration struct for creating a new dispatcher.
+type DispatcherConfig struct {
+	QueueSize int                                  // optional. events buffered per handler, defaults to 100
+	OnError   func(channel ChannelType, err error) // optional. called with decoding errors and handler panics
+	OnDrop    func(channel ChannelType)            // optional. called when an event is dropped because a handler's queue is full
+}
+
+// Dispatcher decodes the messages of a websocket connection once and routes them to the handlers registered for
+// their channel. Every handler runs in its own goroutine with a queue, a handler which falls behind drops events
+// instead of blocking the connection.
+type Dispatcher struct {
+	queueSize int
+	onError   func(channel ChannelType, err error)
+	onDrop    func(channel ChannelType)
+
+	mu       sync.Mutex
+	handlers map[ChannelType][]*eventHandler
+	running  bool
+}
+
+// eventHandler calls fn with the events of its queue.
+type eventHandler struct {
+	channel ChannelType
+	fn      func(interface{})
+	queue   chan interface{}
+}
+
+// NewDispatcher creates a dispatcher without handlers.
+func NewDispatcher(cfg DispatcherConfig) *Dispatcher {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultDispatcherQueueSize
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(ChannelType, error) {}
+	}
+	if cfg.OnDrop == nil {
+		cfg.OnDrop = func(ChannelType) {}
+	}
+
+	return &Dispatcher{
+		queueSize: cfg.QueueSize,
+		onError:   cfg.OnError,
+		onDrop:    cfg.OnDrop,
+		handlers:  map[ChannelType][]*eventHandler{},
+	}
+}
+
+// OnTicker registers a handler for the ticker and ticker_batch channels.
+func (d *Dispatcher) OnTicker(fn func(TickerEvent)) {
+	d.register(ChannelTypeTicker, func(e interface{}) { fn(e.(TickerEvent)) })
+}
+
+// OnHeartbeat registers a handler for the heartbeats channel.
+func (d *Dispatcher) OnHeartbeat(fn func(HeartbeatsEvent)) {
+	d.register(ChannelTypeHeartbeats, func(e interface{}) { fn(e.(HeartbeatsEvent)) })
+}
+
+// OnCandles registers a handler for the candles channel.
+func (d *Dispatcher) OnCandles(fn func(CandlesEvent)) {
+	d.register(ChannelTypeCandles, func(e interface{}) { fn(e.(CandlesEvent)) })
+}
+
+// OnMarketTrades registers a handler for the market_trades channel.
+func (d *Dispatcher) OnMarketTrades(fn func(MarketTradesEvent)) {
+	d.register(ChannelTypeMarketTrades, func(e interface{}) { fn(e.(MarketTradesEvent)) })
+}
+
+// OnStatus registers a handler for the status channel.
+func (d *Dispatcher) OnStatus(fn func(StatusEvent)) {
+	d.register(ChannelTypeStatus, func(e interface{}) { fn(e.(StatusEvent)) })
+}
+
+// OnLevel2 registers a handler for the level2 channel. Do not feed an OrderBook from it: the sequence numbers count the
+// messages of all channels, so the heartbeats between level2 events look like gaps. Give OrderBook.Run the raw message
+// channel instead.
+func (d *Dispatcher) OnLevel2(fn func(Level2Event)) {
+	d.register(ChannelTypeLevel2, func(e interface{}) { fn(e.(Level2Event)) })
+}
+
+// OnUser registers a handler for the user channel.
+func (d *Dispatcher) OnUser(fn func(UserEvent)) {
+	d.register(ChannelTypeUser, func(e interface{}) { fn(e.(UserEvent)) })
+}
+
+// register adds a handler. Handlers have to be registered before Run.
+func (d *Dispatcher) register(channel ChannelType, fn func(interface{})) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.running {
+		panic("coinbasev3: handlers have to be registered before the dispatcher runs")
+	}
+
+	d.handlers[channel] = append(d.handlers[channel], &eventHandler{
+		channel: channel,
+		fn:      fn,
+		queue:   make(chan interface{}, d.queueSize),
+	})
+}
+
+// Run dispatches the messages of readCh until the context is cancelled or readCh is closed. The handlers finish
+// the queued events before Run returns.
+func (d *Dispatcher) Run(ctx context.Context, readCh <-chan []byte) error {
+	d.mu.Lock()
+	d.running = true
+	handlers := d.handlers
+	d.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, hs := range handlers {
+		for _, h := range hs {
+			wg.Add(1)
+			go func(h *eventHandler) {
+				defer wg.Done()
+				d.work(h)
+			}(h)
+		}
+	}
+
+	defer func() {
+		for _, hs := range handlers {
+			for _, h := range hs {
+				close(h.queue)
+			}
+		}
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-readCh:
+			if !ok {
+				return nil
+			}
+			d.dispatch(msg)
+		}
+	}
+}
+
+// dispatch decodes a message and queues it for the handlers of its channel. Messages of channels without handlers,
+// e.g. subscription confirmations, are ignored.
+func (d *Dispatcher) dispatch(msg []byte) {
+	var raw struct {
+		Channel     string          `json:"channel"`
+		ClientId    string          `json:"client_id"`
+		Timestamp   time.Time       `json:"timestamp"`
+		SequenceNum int             `json:"sequence_num"`
+		Events      json.RawMessage `json:"events"`
+	}
+	if err := json.Unmarshal(msg, &raw); err != nil {
+		d.onError(ChannelType(raw.Channel), err)
+		return
+	}
+
+	channel := ChannelType(raw.Channel)
+	if channel == ChannelTypeTickerBatch {
+		channel = ChannelTypeTicker
+	}
+
+	handlers := d.handlers[channel]
+	if len(handlers) == 0 {
+		return
+	}
+
+	base := Event{Channel: raw.Channel, ClientId: raw.ClientId, Timestamp: raw.Timestamp, SequenceNum: raw.SequenceNum}
+	evt, err := decodeTypedEvent(channel, base, raw.Events)
+	if err != nil {
+		d.onError(channel, err)
+		return
+	}
+
+	for _, h := range handlers {
+		select {
+		case h.queue <- evt:
+		default:
+			d.onDrop(channel)
+		}
+	}
+}
+
+// decodeTypedEvent decodes the events array of a message into the event type of channel.
+func decodeTypedEvent(channel ChannelType, base Event, events json.RawMessage) (interface{}, error) {
+	switch channel {
+	case ChannelTypeTicker:
+		e := TickerEvent{Event: base}
+		err := json.Unmarshal(events, &e.Events)
+		return e, err
+	case ChannelTypeHeartbeats:
+		e := HeartbeatsEvent{Event: base}
+		err := json.Unmarshal(events, &e.Events)
+		return e, err
+	case ChannelTypeCandles:
+		e := CandlesEvent{Event: base}
+		err := json.Unmarshal(events, &e.Events)
+		return e, err
+	case ChannelTypeMarketTrades:
+		e := MarketTradesEvent{Event: base}
+		err := json.Unmarshal(events, &e.Events)
+		return e, err
+	case ChannelTypeStatus:
+		e := StatusEvent{Event: base}
+		err := json.Unmarshal(events, &e.Events)
+		return e, err
+	case ChannelTypeLevel2:
+		e := Level2Event{Event: base}
+		err := json.Unmarshal(events, &e.Events)
+		return e, err
+	case ChannelTypeUser:
+		e := UserEvent{Event: base}
+		err := json.Unmarshal(events, &e.Events)
+		return e, err
+	}
+	return nil, fmt.Errorf("unsupported channel %s", channel)
+}
+
+// work runs the handler for every queued event. A panicking handler is reported and keeps running.
+func (d *Dispatcher) work(h *eventHandler) {
+	for evt := range h.queue {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					d.onError(h.channel, fmt.Errorf("handler panic: %v", r))
+				}
+			}()
+			h.fn(evt)
+		}()
+	}
+}
@@ -0,0 +1,152 @@
+package coinbasev3
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatcher_Routing(t *testing.T) {
+	d := NewDispatcher(DispatcherConfig{})
+
+	var (
+		mu         sync.Mutex
+		tickers    []TickerEvent
+		heartbeats []HeartbeatsEvent
+		level2     []Level2Event
+		users      []UserEvent
+		candles    []CandlesEvent
+		statuses   []StatusEvent
+	)
+	d.OnTicker(func(e TickerEvent) { mu.Lock(); tickers = append(tickers, e); mu.Unlock() })
+	d.OnHeartbeat(func(e HeartbeatsEvent) { mu.Lock(); heartbeats = append(heartbeats, e); mu.Unlock() })
+	d.OnLevel2(func(e Level2Event) { mu.Lock(); level2 = append(level2, e); mu.Unlock() })
+	d.OnUser(func(e UserEvent) { mu.Lock(); users = append(users, e); mu.Unlock() })
+	d.OnCandles(func(e CandlesEvent) { mu.Lock(); candles = append(candles, e); mu.Unlock() })
+	d.OnStatus(func(e StatusEvent) { mu.Lock(); statuses = append(statuses, e); mu.Unlock() })
+
+	readCh := make(chan []byte, 10)
+	readCh <- []byte(`{"channel":"subscriptions","client_id":"","timestamp":"2023-02-09T20:32:50.714964855Z","sequence_num":0,"events":[{"subscriptions":{"ticker":["BTC-USD"]}}]}`)
+	readCh <- []byte(`{"channel":"ticker","client_id":"","timestamp":"2023-02-09T20:30:37.167359596Z","sequence_num":1,"events":[{"type":"update","tickers":[{"type":"ticker","product_id":"BTC-USD","price":"21932.98","volume_24_h":"16038.28770938","low_24_h":"21835.29","high_24_h":"23011.18","low_52_w":"15460","high_52_w":"48240","price_percent_chg_24_h":"-4.15775596190603"}]}]}`)
+	readCh <- []byte(`{"channel":"ticker_batch","client_id":"","timestamp":"2023-02-09T20:30:37.167359596Z","sequence_num":2,"events":[{"type":"update","tickers":[{"type":"ticker","product_id":"ETH-USD","price":"1550.1"}]}]}`)
+	readCh <- []byte(`{"channel":"heartbeats","client_id":"","timestamp":"2023-06-23T20:31:26.122969572Z","sequence_num":3,"events":[{"current_time":"2023-06-23 20:31:56.121961769 +0000 UTC m=+91717.525857105","heartbeat_counter":3049}]}`)
+	readCh <- l2Message(4, "snapshot", "BTC-USD", "bid", "21921.73", "0.06317902")
+	readCh <- []byte(`{"channel":"user","client_id":"","timestamp":"2023-02-09T20:33:57.609931463Z","sequence_num":5,"events":[{"type":"snapshot","orders":[{"order_id":"XXX","client_order_id":"YYY","cumulative_quantity":"0","leaves_quantity":"0.000994","avg_price":"0","total_fees":"0","status":"OPEN","product_id":"BTC-USD","creation_time":"2022-12-07T19:42:18.719312Z","order_side":"BUY","order_type":"Limit"}]}]}`)
+	readCh <- []byte(`{"channel":"candles","client_id":"","timestamp":"2023-06-09T20:19:35.39625135Z","sequence_num":6,"events":[{"type":"snapshot","candles":[{"start":"1688998200","high":"1867.72","low":"1865.63","open":"1867.38","close":"1866.81","volume":"0.20269406","product_id":"ETH-USD"}]}]}`)
+	readCh <- []byte(`{"channel":"status","client_id":"","timestamp":"2023-02-09T20:29:49.753424311Z","sequence_num":7,"events":[{"type":"snapshot","products":[{"product_type":"SPOT","id":"BTC-USD","base_currency":"BTC","quote_currency":"USD","base_increment":"0.00000001","quote_increment":"0.01","display_name":"BTC/USD","status":"online","status_message":"","min_market_funds":"1"}]}]}`)
+	close(readCh)
+
+	if err := d.Run(context.Background(), readCh); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	if len(tickers) != 2 || tickers[0].Events[0].Tickers[0].Price != "21932.98" || tickers[1].Channel != "ticker_batch" {
+		t.Errorf("Expected ticker and ticker_batch events, got %v", tickers)
+	}
+	if len(heartbeats) != 1 || heartbeats[0].Events[0].HeartbeatCounter != "3049" || heartbeats[0].SequenceNum != 3 {
+		t.Errorf("Expected heartbeat 3049, got %v", heartbeats)
+	}
+	if len(level2) != 1 || level2[0].Events[0].Updates[0].EventTime.Year() != 2023 {
+		t.Errorf("Expected level2 snapshot, got %v", level2)
+	}
+	if len(users) != 1 || users[0].Events[0].Orders[0].CreationTime.Year() != 2022 {
+		t.Errorf("Expected user snapshot, got %v", users)
+	}
+	if len(candles) != 1 || candles[0].Events[0].Candles[0].Close != "1866.81" {
+		t.Errorf("Expected candles, got %v", candles)
+	}
+	if len(statuses) != 1 || statuses[0].Events[0].Products[0].Status != "online" {
+		t.Errorf("Expected status, got %v", statuses)
+	}
+}
+
+func TestDispatcher_SlowHandler(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		dropped int
+	)
+	d := NewDispatcher(DispatcherConfig{QueueSize: 1, OnDrop: func(channel ChannelType) {
+		mu.Lock()
+		dropped++
+		mu.Unlock()
+	}})
+
+	release := make(chan struct{})
+	slow := 0
+	d.OnHeartbeat(func(e HeartbeatsEvent) {
+		<-release
+		slow++
+	})
+	fast := 0
+	d.OnHeartbeat(func(e HeartbeatsEvent) { fast++ })
+
+	readCh := make(chan []byte)
+	done := make(chan error)
+	go func() { done <- d.Run(context.Background(), readCh) }()
+
+	// the slow handler holds one event and queues one more, the rest are dropped without blocking
+	for i := 0; i < 5; i++ {
+		select {
+		case readCh <- heartbeatMessage(i):
+		case <-time.After(time.Second):
+			t.Fatalf("Expected a slow handler not to block the read channel")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(release)
+	close(readCh)
+	<-done
+
+	if fast != 5 {
+		t.Errorf("Expected the fast handler to get 5 events, got %d", fast)
+	}
+	if slow+dropped != 5 || dropped < 3 {
+		t.Errorf("Expected at least 3 events dropped for the slow handler, got %d handled and %d dropped", slow, dropped)
+	}
+}
+
+func TestDispatcher_Errors(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		errs = map[ChannelType]int{}
+	)
+	d := NewDispatcher(DispatcherConfig{OnError: func(channel ChannelType, err error) {
+		mu.Lock()
+		errs[channel]++
+		mu.Unlock()
+	}})
+
+	handled := 0
+	d.OnHeartbeat(func(e HeartbeatsEvent) {
+		handled++
+		if handled == 1 {
+			panic("boom")
+		}
+	})
+	d.OnTicker(func(e TickerEvent) {})
+
+	readCh := make(chan []byte, 4)
+	readCh <- heartbeatMessage(1)
+	readCh <- heartbeatMessage(2)
+	readCh <- []byte(`{"channel":"ticker","events":[{"tickers":"not a list"}]}`)
+	readCh <- []byte(`not json`)
+	close(readCh)
+
+	d.Run(context.Background(), readCh)
+
+	if handled != 2 {
+		t.Errorf("Expected the handler to keep running after a panic, got %d events", handled)
+	}
+	if errs[ChannelTypeHeartbeats] != 1 || errs[ChannelTypeTicker] != 1 || errs[""] != 1 {
+		t.Errorf("Expected a panic, a decoding and a parse error, got %v", errs)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected registering after Run to panic")
+		}
+	}()
+	d.OnUser(func(e UserEvent) {})
+}
@@ -1,6 +1,7 @@
 package coinbasev3
 
 import (
+	"encoding/json"
 	"github.com/mitchellh/mapstructure"
 	"time"
 )
@@ -14,6 +15,19 @@ type Event struct {
 	Events      []interface{} `json:"events"`
 }
 
+// decodeEvent decodes an event of the events array. Times are sent as RFC 3339 strings.
+func decodeEvent(input map[string]interface{}, output interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeHookFunc(time.RFC3339Nano),
+		WeaklyTypedInput: true, // numbers of the JSON message are float64
+		Result:           output,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(input)
+}
+
 // IsTickerEvent returns true if the event is a ticker event.
 // TickerBatch has the same JSON message schema as the ticker channel, except the channel field will have a value of ticker_batch.
 func (e Event) IsTickerEvent() bool {
@@ -32,7 +46,7 @@ func (e Event) GetTickerEvent() (TickerEvent, error) {
 		}
 
 		var event TickerEventType
-		err := mapstructure.Decode(ne, &event)
+		err := decodeEvent(ne, &event)
 		if err != nil {
 			return evt, err
 		}
@@ -60,10 +74,12 @@ func (e Event) GetHeartbeatsEvent() (HeartbeatsEvent, error) {
 			return evt, ErrFailedToUnmarshal
 		}
 
-		evt.Events = append(evt.Events, HeartbeatsEventType{
-			CurrentTime:      ne["current_time"].(string),
-			HeartbeatCounter: ne["heartbeat_counter"].(string),
-		})
+		var event HeartbeatsEventType
+		err := decodeEvent(ne, &event)
+		if err != nil {
+			return evt, err
+		}
+		evt.Events = append(evt.Events, event)
 	}
 
 	return evt, nil
@@ -84,7 +100,7 @@ func (e Event) GetCandlesEvent() (CandlesEvent, error) {
 		}
 
 		var event CandlesEventType
-		err := mapstructure.Decode(ne, &event)
+		err := decodeEvent(ne, &event)
 		if err != nil {
 			return evt, err
 		}
@@ -112,7 +128,7 @@ func (e Event) GetMarketTradesEvent() (MarketTradesEvent, error) {
 		}
 
 		var event MarketTradesEventType
-		err := mapstructure.Decode(ne, &event)
+		err := decodeEvent(ne, &event)
 		if err != nil {
 			return evt, err
 		}
@@ -140,7 +156,7 @@ func (e Event) GetStatusEvent() (StatusEvent, error) {
 		}
 
 		var event StatusEventType
-		err := mapstructure.Decode(ne, &event)
+		err := decodeEvent(ne, &event)
 		if err != nil {
 			return evt, err
 		}
@@ -161,6 +177,8 @@ func (e Event) IsLevel2Event() bool {
 // GetLevel2Event converts a generic event to a level 2 event. Returns an error if the event is not level 2 event.
 func (e Event) GetLevel2Event() (Level2Event, error) {
 	var evt Level2Event
+	evt.Event = e
+
 	for _, ev := range e.Events {
 		ne, ok := ev.(map[string]interface{})
 		if !ok {
@@ -168,7 +186,7 @@ func (e Event) GetLevel2Event() (Level2Event, error) {
 		}
 
 		var event Level2EventType
-		err := mapstructure.Decode(ne, &event)
+		err := decodeEvent(ne, &event)
 		if err != nil {
 			return evt, err
 		}
@@ -198,7 +216,7 @@ func (e Event) GetUserEvent() (UserEvent, error) {
 		}
 
 		var event UserEventType
-		err := mapstructure.Decode(ne, &event)
+		err := decodeEvent(ne, &event)
 		if err != nil {
 			return evt, err
 		}
@@ -241,8 +259,8 @@ type HeartbeatsEvent struct {
 }
 
 type HeartbeatsEventType struct {
-	CurrentTime      string `json:"current_time" mapstructure:"current_time"`
-	HeartbeatCounter string `json:"heartbeat_counter" mapstructure:"heartbeat_counter"`
+	CurrentTime      string      `json:"current_time" mapstructure:"current_time"`
+	HeartbeatCounter json.Number `json:"heartbeat_counter" mapstructure:"heartbeat_counter"` // a number, strings in older messages
 }
 
 type CandlesEvent struct {
@@ -0,0 +1,300 @@
+package coinbasev3
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// subscriptionServer records the subscription messages of every connection and sends heartbeats if interval is set.
+type subscriptionServer struct {
+	interval time.Duration
+
+	mu    sync.Mutex
+	conns [][]WebsocketChannel
+}
+
+func (s *subscriptionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	s.mu.Lock()
+	s.conns = append(s.conns, nil)
+	i := len(s.conns) - 1
+	s.mu.Unlock()
+
+	if s.interval > 0 {
+		go func() {
+			for seq := 0; ; seq++ {
+				time.Sleep(s.interval)
+				if conn.WriteMessage(websocket.TextMessage, heartbeatMessage(seq)) != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var ch WebsocketChannel
+		if json.Unmarshal(msg, &ch) == nil {
+			s.mu.Lock()
+			s.conns[i] = append(s.conns[i], ch)
+			s.mu.Unlock()
+		}
+	}
+}
+
+// messages returns the subscription messages received on connection i.
+func (s *subscriptionServer) messages(i int) []WebsocketChannel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i >= len(s.conns) {
+		return nil
+	}
+	return append([]WebsocketChannel(nil), s.conns[i]...)
+}
+
+func newTestWsClient(t *testing.T, url string, cfg WsClientConfig) *WsClient {
+	t.Helper()
+	cfg.ApiKey = "key"
+	cfg.SecretKey = "secret"
+	cfg.Url = makeWsProto(url)
+	if cfg.ReadChannel == nil {
+		cfg.ReadChannel = make(chan []byte, 100)
+	}
+	cl, err := NewWsClient(cfg)
+	if err != nil {
+		t.Fatalf("NewWsClient: %v", err)
+	}
+	return cl
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWsClient_SubscriptionsSurviveReconnect(t *testing.T) {
+	srv := &subscriptionServer{}
+	s := httptest.NewServer(srv)
+	defer s.Close()
+
+	var mu sync.Mutex
+	reconnects := 0
+	cl := newTestWsClient(t, s.URL, WsClientConfig{
+		WsChannels:  []WebsocketChannel{NewTickerChannel([]string{"BTC-USD", "ETH-USD"})},
+		OnReconnect: func() { mu.Lock(); reconnects++; mu.Unlock() },
+	})
+	defer cl.Shutdown()
+
+	// subscriptions before the connection are sent with it
+	if err := cl.Subscribe(ChannelTypeUser, nil); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if _, err := cl.Connect(); err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	if err := cl.Subscribe(ChannelTypeLevel2, []string{"BTC-USD"}); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if err := cl.Unsubscribe(ChannelTypeTicker, []string{"ETH-USD"}); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	waitFor(t, "the runtime subscriptions", func() bool { return len(srv.messages(0)) == 4 })
+
+	first := srv.messages(0)
+	if first[3].Type != SubTypeUnsubscribe || first[3].ProductIds[0] != "ETH-USD" {
+		t.Errorf("Expected an unsubscribe from ETH-USD, got %v", first[3])
+	}
+
+	_ = cl.connection().Close()
+	waitFor(t, "the reconnect", func() bool { mu.Lock(); defer mu.Unlock(); return reconnects == 1 })
+	waitFor(t, "the resubscription", func() bool { return len(srv.messages(1)) == 3 })
+
+	want := []struct {
+		channel  ChannelType
+		products int
+	}{{ChannelTypeLevel2, 1}, {ChannelTypeTicker, 1}, {ChannelTypeUser, 0}}
+	for i, msg := range srv.messages(1) {
+		if msg.Type != SubTypeSubscribe || msg.Channel != want[i].channel || len(msg.ProductIds) != want[i].products {
+			t.Errorf("Expected subscribe to %s with %d products, got %v", want[i].channel, want[i].products, msg)
+		}
+	}
+	if subs := cl.Subscriptions(); len(subs) != 3 || subs[1].ProductIds[0] != "BTC-USD" {
+		t.Errorf("Expected 3 subscriptions, got %v", subs)
+	}
+
+	if err := cl.Unsubscribe(ChannelTypeUser, nil); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if subs := cl.Subscriptions(); len(subs) != 2 {
+		t.Errorf("Expected 2 subscriptions, got %v", subs)
+	}
+}
+
+func TestWsClient_HeartbeatWatchdog(t *testing.T) {
+	srv := &subscriptionServer{} // never sends a message
+	s := httptest.NewServer(srv)
+	defer s.Close()
+
+	var mu sync.Mutex
+	reconnects := 0
+	cl := newTestWsClient(t, s.URL, WsClientConfig{
+		HeartbeatTimeout: 100 * time.Millisecond,
+		OnReconnect:      func() { mu.Lock(); reconnects++; mu.Unlock() },
+	})
+	defer cl.Shutdown()
+
+	if _, err := cl.Connect(); err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	waitFor(t, "a stalled connection to reconnect", func() bool { mu.Lock(); defer mu.Unlock(); return reconnects >= 1 })
+
+	msgs := srv.messages(0)
+	if len(msgs) != 1 || msgs[0].Channel != ChannelTypeHeartbeats {
+		t.Errorf("Expected a heartbeats subscription, got %v", msgs)
+	}
+}
+
+func TestWsClient_HeartbeatsKeepConnection(t *testing.T) {
+	srv := &subscriptionServer{interval: 20 * time.Millisecond}
+	s := httptest.NewServer(srv)
+	defer s.Close()
+
+	cl := newTestWsClient(t, s.URL, WsClientConfig{
+		HeartbeatTimeout: 200 * time.Millisecond,
+		OnReconnect:      func() { t.Errorf("Expected no reconnect while heartbeats arrive") },
+	})
+	if _, err := cl.Connect(); err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	received := 0
+	timeout := time.After(500 * time.Millisecond)
+loop:
+	for {
+		select {
+		case <-cl.ReadChan():
+			received++
+		case <-timeout:
+			break loop
+		}
+	}
+	cl.Shutdown()
+
+	if received < 10 {
+		t.Errorf("Expected at least 10 heartbeats, got %d", received)
+	}
+}
+
+func TestWsClient_Run(t *testing.T) {
+	srv := &subscriptionServer{interval: time.Millisecond}
+	s := httptest.NewServer(srv)
+	defer s.Close()
+
+	// nobody reads, the reader is blocked sending when the context is cancelled
+	readCh := make(chan []byte)
+	cl := newTestWsClient(t, s.URL, WsClientConfig{ReadChannel: readCh})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- cl.Run(ctx) }()
+
+	<-readCh
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Expected %s, got %v", context.Canceled, err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected Run to return after the context was cancelled")
+	}
+
+	// the read channel is closed after the reader stopped
+	for range readCh {
+	}
+
+	if err := cl.Subscribe(ChannelTypeTicker, []string{"BTC-USD"}); err != ErrShutdown {
+		t.Errorf("Expected %s, got %v", ErrShutdown, err)
+	}
+	cl.Shutdown() // a second shutdown is a no-op
+}
+
+func TestWsClient_RunDialError(t *testing.T) {
+	s := httptest.NewServer(http.NotFoundHandler())
+	s.Close()
+
+	cl := newTestWsClient(t, s.URL, WsClientConfig{UseBackoff: true})
+
+	done := make(chan error)
+	go func() { done <- cl.Run(context.Background()) }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("Expected a dial error, got nil")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Expected Run to return after the dial failed")
+	}
+
+	// the reconnect loop stopped and the read channel is closed
+	select {
+	case _, ok := <-cl.ReadChan():
+		if ok {
+			t.Errorf("Expected the read channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the read channel to be closed")
+	}
+
+	if err := cl.Subscribe(ChannelTypeTicker, []string{"BTC-USD"}); err != ErrShutdown {
+		t.Errorf("Expected %s, got %v", ErrShutdown, err)
+	}
+}
+
+func TestWsClient_ShutdownDuringBackoff(t *testing.T) {
+	srv := &subscriptionServer{}
+	s := httptest.NewServer(srv)
+	defer s.Close()
+
+	cl := newTestWsClient(t, s.URL, WsClientConfig{
+		UseBackoff:  true,
+		OnReconnect: func() { t.Errorf("Expected no reconnect after shutdown") },
+	})
+	if _, err := cl.Connect(); err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	_ = cl.connection().Close()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	cl.Shutdown()
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Expected shutdown to interrupt the backoff, took %s", time.Since(start))
+	}
+}
@@ -100,7 +100,7 @@ func TestClient_Reconnecting(t *testing.T) {
 
 	for i := 1; i <= want-1; i++ {
 		time.Sleep(100 * time.Millisecond)
-		_ = cl.conn.Close()
+		_ = cl.connection().Close()
 	}
 	time.Sleep(100 * time.Millisecond)
 	cl.Shutdown()
@@ -152,7 +152,7 @@ func TestWsClient_Backoff_ShouldNotTriggerRecon(t *testing.T) {
 
 	for i := 1; i <= 2; i++ {
 		time.Sleep(400 * time.Millisecond)
-		_ = cl.conn.Close()
+		_ = cl.connection().Close()
 	}
 	time.Sleep(100 * time.Millisecond)
 	cl.Shutdown()
@@ -205,7 +205,7 @@ func TestWsClient_Backoff_ShouldTriggerRecon(t *testing.T) {
 
 	for i := 1; i <= 2; i++ {
 		time.Sleep(2000 * time.Millisecond)
-		_ = cl.conn.Close()
+		_ = cl.connection().Close()
 	}
 	time.Sleep(100 * time.Millisecond)
 	cl.Shutdown()
@@ -10,14 +10,19 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
 
 	"github.com/claudiocandio/gemini-api/logger"
+	"github.com/sberserker/dcagdax/metrics"
 )
 
 type Api struct {
 	url    string
 	key    string
 	secret string
+	client *http.Client
 }
 
 // buildHeader handles the conversion of post parameters into headers formatted
@@ -44,7 +49,11 @@ func (api *Api) buildHeader(req *map[string]interface{}) http.Header {
 }
 
 // request makes the HTTP request to Gemini and handles any returned errors
-func (api *Api) request(verb, url string, params map[string]interface{}) ([]byte, error) {
+func (api *Api) request(verb, url string, params map[string]interface{}) (_ []byte, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveRequest("gemini", endpoint(url), start, err)
+	}()
 
 	logger.Debug("func request: http.NewRequest",
 		fmt.Sprintf("verb:%s", verb),
@@ -74,7 +83,10 @@ func (api *Api) request(verb, url string, params map[string]interface{}) ([]byte
 		fmt.Sprintf("req:%v", req),
 	)
 
-	client := &http.Client{}
+	client := api.client
+	if client == nil {
+		client = api.newHttpClient(nil)
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
@@ -104,11 +116,40 @@ func (api *Api) request(verb, url string, params map[string]interface{}) ([]byte
 	return body, nil
 }
 
+// symbolURIs are the endpoints followed by a symbol or a currency.
+var symbolURIs = []string{
+	symbol_details_URI + "/", ticker_v1_URI, ticker_v2_URI, book_URI, trades_URI, auction_URI,
+	new_deposit_address_URI, deposit_addresses_URI, withdraw_funds_URI,
+}
+
+// endpoint returns the path template of url for metric labels, the symbol is replaced with :symbol so every symbol
+// does not create a time series of its own.
+func endpoint(url string) string {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return "unknown"
+	}
+
+	for _, uri := range symbolURIs {
+		if !strings.HasPrefix(u.Path, uri) {
+			continue
+		}
+		rest := strings.TrimPrefix(u.Path, uri)
+		if i := strings.Index(rest, "/"); i >= 0 {
+			return uri + ":symbol" + rest[i:]
+		}
+		return uri + ":symbol"
+	}
+	return u.Path
+}
+
 func New(live bool, key, secret string) *Api {
 	var url string
 	if url = sandbox_URL; live {
 		url = base_URL
 	}
 
-	return &Api{url: url, key: key, secret: secret}
+	api := &Api{url: url, key: key, secret: secret}
+	api.client = api.newHttpClient(nil)
+	return api
 }
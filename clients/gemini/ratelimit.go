@@ -0,0 +1,79 @@
+package gemini
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sberserker/dcagdax/clients/transport"
+)
+
+// rateLimits are the request rates Gemini recommends, 1 per second for public and 5 per second for private
+// endpoints, with its burst allowance of 5 requests.
+var rateLimits = map[string]transport.Limit{
+	"public":  {Rate: 1, Burst: 5},
+	"private": {Rate: 5, Burst: 5},
+}
+
+// unsafeURIs are the private endpoints which must not be sent twice, Gemini does not deduplicate them.
+var unsafeURIs = []string{new_order_URI, new_deposit_address_URI, withdraw_funds_URI}
+
+// newHttpClient creates the http client of api with the rate limits and retries of the transport, sending requests
+// through base.
+func (api *Api) newHttpClient(base http.RoundTripper) *http.Client {
+	return &http.Client{
+		Transport: transport.New(base, transport.Config{
+			Limiter:   transport.NewLimiter(rateLimits, endpointClass),
+			Retryable: retryable,
+			Sign:      api.sign,
+		}),
+	}
+}
+
+// SetTransport sends the requests of api through base, http.DefaultTransport when nil, e.g. a transport.Recorder
+// replaying fixtures. The rate limits, retries and signing wrap base.
+func (api *Api) SetTransport(base http.RoundTripper) {
+	api.client = api.newHttpClient(base)
+}
+
+// endpointClass returns the rate limit class of a request, the private endpoints are POST requests.
+func endpointClass(r *http.Request) string {
+	if r.Method == http.MethodPost {
+		return "private"
+	}
+	return "public"
+}
+
+// retryable allows retrying the private endpoints which only read or cancel.
+func retryable(r *http.Request) bool {
+	for _, uri := range unsafeURIs {
+		if strings.HasPrefix(r.URL.Path, uri) {
+			return false
+		}
+	}
+	return true
+}
+
+// sign signs every attempt of a private request with a new nonce, Gemini rejects a nonce it has seen before.
+func (api *Api) sign(r *http.Request) error {
+	payload := r.Header.Get("X-GEMINI-PAYLOAD")
+	if payload == "" {
+		return nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return err
+	}
+	params := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return err
+	}
+	params["nonce"] = nonce()
+
+	for key, values := range api.buildHeader(&params) {
+		r.Header[key] = values
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package transport
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Limit is the rate limit of an endpoint class.
+type Limit struct {
+	Rate  float64 // requests per second
+	Burst int     // optional. requests allowed at once, defaults to 1
+}
+
+// Limiter rate limits requests with a token bucket per endpoint class. Exchanges limit by API key, so clients which
+// share a key should share a limiter.
+type Limiter struct {
+	classify func(*http.Request) string
+	buckets  map[string]*bucket
+}
+
+// NewLimiter creates a limiter for the endpoint classes of limits. classify returns the class of a request, requests
+// of classes without a limit are not limited. A nil classify puts every request into the "" class.
+func NewLimiter(limits map[string]Limit, classify func(*http.Request) string) *Limiter {
+	if classify == nil {
+		classify = func(*http.Request) string { return "" }
+	}
+
+	buckets := map[string]*bucket{}
+	for class, limit := range limits {
+		if limit.Rate <= 0 {
+			continue
+		}
+		if limit.Burst <= 0 {
+			limit.Burst = 1
+		}
+		buckets[class] = &bucket{
+			rate:   limit.Rate,
+			burst:  float64(limit.Burst),
+			tokens: float64(limit.Burst),
+			last:   time.Now(),
+		}
+	}
+
+	return &Limiter{classify: classify, buckets: buckets}
+}
+
+// Wait blocks until the limit of the request's class allows it or its context is cancelled. A nil limiter does not
+// limit.
+func (l *Limiter) Wait(req *http.Request) error {
+	if l == nil {
+		return nil
+	}
+	b, ok := l.buckets[l.classify(req)]
+	if !ok {
+		return nil
+	}
+	return b.wait(req.Context())
+}
+
+// bucket is a token bucket which refills rate tokens per second up to burst.
+type bucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64 // negative while requests wait for tokens
+	last   time.Time
+}
+
+// wait takes a token, waiting until it is refilled when the bucket is empty.
+func (b *bucket) wait(ctx context.Context) error {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	delay := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+
+	if err := sleep(ctx, delay); err != nil {
+		// return the token reserved for the cancelled request
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return err
+	}
+	return nil
+}
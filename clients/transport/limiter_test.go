@@ -0,0 +1,90 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Wait(t *testing.T) {
+	limiter := NewLimiter(map[string]Limit{
+		"private": {Rate: 20, Burst: 2},
+	}, func(r *http.Request) string {
+		if strings.HasPrefix(r.URL.Path, "/private") {
+			return "private"
+		}
+		return "public"
+	})
+	private, _ := http.NewRequest(http.MethodGet, "http://exchange.test/private/orders", nil)
+	public, _ := http.NewRequest(http.MethodGet, "http://exchange.test/public/ticker", nil)
+
+	// the burst and requests of classes without a limit pass at once
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := limiter.Wait(private); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if err := limiter.Wait(public); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("Expected no wait, got %s", elapsed)
+	}
+
+	// then one request every 50 milliseconds
+	start = time.Now()
+	for i := 0; i < 4; i++ {
+		if err := limiter.Wait(private); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("Expected 4 requests to take 200ms, got %s", elapsed)
+	}
+}
+
+func TestLimiter_Cancel(t *testing.T) {
+	limiter := NewLimiter(map[string]Limit{"": {Rate: 0.1}}, nil)
+	req, _ := http.NewRequest(http.MethodGet, "http://exchange.test/", nil)
+	if err := limiter.Wait(req); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(req.WithContext(ctx)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	var nilLimiter *Limiter
+	if err := nilLimiter.Wait(req); err != nil {
+		t.Errorf("Expected a nil limiter not to limit, got %v", err)
+	}
+}
+
+func TestTransport_Limiter(t *testing.T) {
+	srv := newFakeServer(t, http.StatusTooManyRequests)
+	client := newTestClient(Config{Limiter: NewLimiter(map[string]Limit{"": {Rate: 20}}, nil)})
+
+	// the retry waits for a token too
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Expected 3 requests to take 100ms, got %s", elapsed)
+	}
+	if srv.requests() != 3 {
+		t.Errorf("Expected 3 requests, got %d", srv.requests())
+	}
+}
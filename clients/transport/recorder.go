@@ -0,0 +1,403 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mode tells a Recorder whether to record or replay requests.
+type Mode int
+
+const (
+	// Replay serves the responses of a fixture file and fails requests it has no response for.
+	Replay Mode = iota
+	// Record sends requests to the exchange and captures them with their responses.
+	Record
+)
+
+// ErrUnexpectedRequest is returned when replaying a request which is not in the fixture file, it is never retried.
+var ErrUnexpectedRequest = errors.New("unexpected request")
+
+// RedactedHeaders are the request headers carrying credentials, they are never written to a fixture file.
+var RedactedHeaders = []string{
+	"Authorization",
+	"Cb-Access-Key", "Cb-Access-Sign", "Cb-Access-Passphrase", "Cb-Access-Timestamp",
+	"X-Gemini-Apikey", "X-Gemini-Signature", "X-Gemini-Payload",
+}
+
+// RedactedFields are the JSON fields holding account ids, their values are replaced wherever they appear.
+var RedactedFields = []string{
+	"uuid", "user_id", "account_id", "profile_id", "entity_id",
+	"portfolio_id", "portfolio_uuid", "retail_portfolio_id", "payment_method_id",
+}
+
+// jwtPattern matches JSON web tokens, e.g. in a websocket subscription or a bearer token.
+var jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+// redacted replaces the values of RedactedHeaders and JWTs.
+const redacted = "REDACTED"
+
+// minRedactedLength is the length of the shortest field value redacted, shorter values like "0" are no ids and would
+// corrupt the fixtures when replaced.
+const minRedactedLength = 8
+
+// RecorderConfig is the configuration struct for creating a new recorder.
+type RecorderConfig struct {
+	Mode         Mode                                      // optional. defaults to Replay
+	Base         http.RoundTripper                         // optional. the transport requests are recorded from, defaults to http.DefaultTransport
+	Secrets      []string                                  // optional. values replaced wherever they appear when recording and matching, e.g. the API key or the portfolio id
+	Fields       []string                                  // optional. JSON fields of which the values are redacted, defaults to RedactedFields
+	IgnoreParams []string                                  // optional. query parameters not compared when replaying, e.g. time windows which move between runs
+	Header       func(string) bool                         // optional. whether a response header is recorded, defaults to Content-Type only
+	Match        func(*http.Request, RecordedRequest) bool // optional. overrides the default matching of method, URL and query
+}
+
+// Interaction is a request and its response in a fixture file.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a redacted request.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is a redacted response.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Recorder is an http.RoundTripper which records requests into a fixture file or replays them from it, so the
+// exchange clients can be tested end-to-end without network access. Pass it as the base of an exchange client,
+// the client still rate limits, retries and signs every request.
+//
+// When recording, credential headers and JWTs are replaced with REDACTED, the values of Secrets and of the JSON
+// fields in Fields with stable placeholders, e.g. redacted-uuid-1, so ids returned by one request and sent with the
+// next one still match. Save writes the fixture file.
+//
+// When replaying, a request is answered with the first unused interaction of the same method, URL and query, request
+// bodies are not compared as they hold random client order ids. Secrets are redacted from the request before
+// matching, so replaying with the credentials used for recording matches too.
+type Recorder struct {
+	path string
+	cfg  RecorderConfig
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+	placeholders map[string]string // redacted value to its placeholder
+	counts       map[string]int    // placeholders per field
+}
+
+// NewRecorder creates a recorder of the fixture file at path. In Replay mode the file is loaded at once.
+func NewRecorder(path string, cfg RecorderConfig) (*Recorder, error) {
+	if cfg.Base == nil {
+		cfg.Base = http.DefaultTransport
+	}
+	if cfg.Fields == nil {
+		cfg.Fields = RedactedFields
+	}
+	if cfg.Header == nil {
+		cfg.Header = func(name string) bool { return http.CanonicalHeaderKey(name) == "Content-Type" }
+	}
+
+	r := &Recorder{
+		path:         path,
+		cfg:          cfg,
+		placeholders: map[string]string{},
+		counts:       map[string]int{},
+	}
+	for _, secret := range cfg.Secrets {
+		r.placeholder("secret", secret)
+	}
+
+	if cfg.Mode == Replay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &r.interactions); err != nil {
+			return nil, fmt.Errorf("fixture %s: %w", path, err)
+		}
+		r.used = make([]bool, len(r.interactions))
+	}
+
+	return r, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.cfg.Mode == Record {
+		return r.record(req)
+	}
+	return r.replay(req)
+}
+
+// Save writes the recorded interactions to the fixture file. It does nothing in Replay mode.
+func (r *Recorder) Save() error {
+	if r.cfg.Mode != Record {
+		return nil
+	}
+
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(data, '\n'), 0644)
+}
+
+// Unused returns the interactions which were not replayed.
+func (r *Recorder) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []Interaction
+	for i, interaction := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+func (r *Recorder) record(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	out := req.Clone(req.Context())
+	if reqBody != nil {
+		out.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	// leave compression to the base transport, which decompresses the body it records
+	out.Header.Del("Accept-Encoding")
+
+	resp, err := r.cfg.Base.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// ids are mostly returned by the exchange before they are sent
+	r.collect(respBody)
+	r.collect(reqBody)
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    r.redact(req.URL.String()),
+			Header: r.redactHeader(req.Header, func(string) bool { return true }),
+			Body:   r.redact(string(reqBody)),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.redactHeader(resp.Header, r.cfg.Header),
+			Body:       r.redact(string(respBody)),
+		},
+	}
+	r.interactions = append(r.interactions, interaction)
+	r.used = append(r.used, true)
+
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.interactions {
+		if r.used[i] || !r.match(req, interaction.Request) {
+			continue
+		}
+		r.used[i] = true
+
+		recorded := interaction.Response
+		header := recorded.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+			StatusCode:    recorded.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+			ContentLength: int64(len(recorded.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w %s %s", ErrUnexpectedRequest, req.Method, r.redact(req.URL.String()))
+}
+
+// match reports whether req is the recorded request, comparing the method, URL and query without IgnoreParams.
+func (r *Recorder) match(req *http.Request, recorded RecordedRequest) bool {
+	if r.cfg.Match != nil {
+		return r.cfg.Match(req, recorded)
+	}
+	if req.Method != recorded.Method {
+		return false
+	}
+
+	u, err := url.Parse(r.redact(req.URL.String()))
+	if err != nil {
+		return false
+	}
+	v, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+	if u.Scheme != v.Scheme || u.Host != v.Host || u.Path != v.Path {
+		return false
+	}
+
+	q, p := u.Query(), v.Query()
+	for _, param := range r.cfg.IgnoreParams {
+		q.Del(param)
+		p.Del(param)
+	}
+	return q.Encode() == p.Encode()
+}
+
+// collect registers the values of the redacted fields of a JSON body.
+func (r *Recorder) collect(body []byte) {
+	var v interface{}
+	if json.Unmarshal(body, &v) != nil {
+		return
+	}
+	r.walk(v)
+}
+
+func (r *Recorder) walk(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if s, ok := value.(string); ok && r.redactedField(key) {
+				r.placeholder(key, s)
+				continue
+			}
+			r.walk(value)
+		}
+	case []interface{}:
+		for _, value := range v {
+			r.walk(value)
+		}
+	}
+}
+
+func (r *Recorder) redactedField(key string) bool {
+	for _, field := range r.cfg.Fields {
+		if strings.EqualFold(key, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholder registers a value to redact, numbering the placeholders of each field in the order they are seen.
+func (r *Recorder) placeholder(field, value string) {
+	if len(value) < minRedactedLength {
+		return
+	}
+	if _, found := r.placeholders[value]; found {
+		return
+	}
+	r.counts[field]++
+	r.placeholders[value] = fmt.Sprintf("redacted-%s-%d", strings.ReplaceAll(field, "_", "-"), r.counts[field])
+}
+
+// redact replaces JWTs and the registered values in s, longest value first so a value containing another one is
+// replaced whole.
+func (r *Recorder) redact(s string) string {
+	s = jwtPattern.ReplaceAllString(s, redacted)
+
+	values := make([]string, 0, len(r.placeholders))
+	for value := range r.placeholders {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+
+	for _, value := range values {
+		s = strings.ReplaceAll(s, value, r.placeholders[value])
+		// query strings carry the value escaped
+		if escaped := url.QueryEscape(value); escaped != value {
+			s = strings.ReplaceAll(s, escaped, r.placeholders[value])
+		}
+	}
+	return s
+}
+
+// redactHeader returns the headers which keep reports true for, with RedactedHeaders replaced.
+func (r *Recorder) redactHeader(h http.Header, keep func(string) bool) http.Header {
+	out := http.Header{}
+	for name, values := range h {
+		if !keep(name) {
+			continue
+		}
+		for _, value := range values {
+			if redactedHeader(name) {
+				value = redacted
+			}
+			out.Add(name, r.redact(value))
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func redactedHeader(name string) bool {
+	for _, header := range RedactedHeaders {
+		if strings.EqualFold(name, header) {
+			return true
+		}
+	}
+	return false
+}
+
+// readBody reads and closes a body.
+func readBody(body io.ReadCloser) ([]byte, error) {
+	if body == nil || body == http.NoBody {
+		return nil, nil
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
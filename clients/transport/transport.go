@@ -0,0 +1,231 @@
+// Package transport is the http.RoundTripper shared by the exchange clients. It rate limits requests with a token
+// bucket per endpoint class and retries failed requests with jittered exponential backoff. Recorder records the
+// requests of a client into fixture files and replays them for offline tests.
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultMinBackoff = 500 * time.Millisecond
+	defaultMaxBackoff = 10 * time.Second
+)
+
+// Config is the configuration struct for creating a new transport.
+type Config struct {
+	Limiter    *Limiter                  // optional. requests are not rate limited without a limiter
+	MaxRetries int                       // optional. defaults to 3, negative disables retries
+	MinBackoff time.Duration             // optional. defaults to 500 milliseconds, doubled with every retry
+	MaxBackoff time.Duration             // optional. defaults to 10 seconds
+	Retryable  func(*http.Request) bool  // optional. whether a request which is not idempotent may be retried after a server or network error, defaults to never
+	Sign       func(*http.Request) error // optional. called before every attempt, e.g. to sign each attempt with a fresh JWT or nonce
+}
+
+// Transport rate limits and retries the requests of its base round tripper.
+//
+// Requests rejected with 429 Too Many Requests were not processed and are always retried. Idempotent requests are
+// also retried after network errors and 500, 502, 503 and 504 responses, other requests only when Retryable allows
+// it. A Retry-After header takes precedence over the backoff.
+type Transport struct {
+	base       http.RoundTripper
+	limiter    *Limiter
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	retryable  func(*http.Request) bool
+	sign       func(*http.Request) error
+}
+
+// New creates a transport wrapping base, http.DefaultTransport when base is nil.
+func New(base http.RoundTripper, cfg Config) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = defaultMaxRetries
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = defaultMinBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if cfg.Retryable == nil {
+		cfg.Retryable = func(*http.Request) bool { return false }
+	}
+	if cfg.Sign == nil {
+		cfg.Sign = func(*http.Request) error { return nil }
+	}
+
+	return &Transport{
+		base:       base,
+		limiter:    cfg.Limiter,
+		maxRetries: cfg.MaxRetries,
+		minBackoff: cfg.MinBackoff,
+		maxBackoff: cfg.MaxBackoff,
+		retryable:  cfg.Retryable,
+		sign:       cfg.Sign,
+	}
+}
+
+// RoundTrip implements http.RoundTripper. Every attempt waits for the rate limiter and is signed again, the response
+// of the last attempt is returned.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	retryable := idempotent(req.Method) || t.retryable(req)
+	// a body can only be sent again when it can be recreated
+	rewindable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.Wait(req); err != nil {
+			closeBody(req, attempt)
+			return nil, err
+		}
+
+		r, err := t.prepare(req, attempt)
+		if err != nil {
+			closeBody(req, attempt)
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(r)
+		if attempt >= t.maxRetries || !rewindable || ctx.Err() != nil {
+			return resp, err
+		}
+
+		delay, retry := t.retryDelay(resp, err, retryable, attempt)
+		if !retry {
+			return resp, err
+		}
+		if resp != nil {
+			// drain the body so the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// prepare clones the request for an attempt, recreates the body of retries and signs it.
+func (t *Transport) prepare(req *http.Request, attempt int) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+
+	if err := t.sign(r); err != nil {
+		if attempt > 0 && r.Body != nil {
+			r.Body.Close()
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// closeBody closes the body of a request which was not sent, the base round tripper closes it otherwise.
+func closeBody(req *http.Request, attempt int) {
+	if attempt == 0 && req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// retryDelay returns whether an attempt should be retried and how long to wait before.
+func (t *Transport) retryDelay(resp *http.Response, err error, retryable bool, attempt int) (time.Duration, bool) {
+	if err != nil {
+		if errors.Is(err, ErrUnexpectedRequest) {
+			// a replayed fixture has no response for it, trying again does not change that
+			return 0, false
+		}
+		// the request may have reached the exchange
+		return t.backoff(attempt), retryable
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if !retryable {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+		return d, true
+	}
+	return t.backoff(attempt), true
+}
+
+// backoff returns the exponential backoff of an attempt with equal jitter, between half and the full delay.
+func (t *Transport) backoff(attempt int) time.Duration {
+	d := t.maxBackoff
+	if attempt < 32 && t.minBackoff<<attempt < t.maxBackoff {
+		d = t.minBackoff << attempt
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// retryAfter parses a Retry-After header, either delay seconds or an HTTP date.
+func retryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		d := time.Until(date)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// idempotent returns true for the methods which can be sent again without side effects.
+func idempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// sleep waits for d or until the context is cancelled.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
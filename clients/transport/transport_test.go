@@ -0,0 +1,307 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeServer responds with the statuses in order and records the request bodies and authorization headers.
+type fakeServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	header   http.Header // set on every response
+	bodies   []string
+	auth     []string
+}
+
+func newFakeServer(t *testing.T, statuses ...int) *fakeServer {
+	s := &fakeServer{statuses: statuses, header: http.Header{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		s.bodies = append(s.bodies, string(body))
+		s.auth = append(s.auth, r.Header.Get("Authorization"))
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		for k, v := range s.header {
+			w.Header()[k] = v
+		}
+		s.mu.Unlock()
+
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeServer) requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+func newTestClient(cfg Config) *http.Client {
+	if cfg.MinBackoff == 0 {
+		cfg.MinBackoff = time.Millisecond
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = 5 * time.Millisecond
+	}
+	return &http.Client{Transport: New(nil, cfg)}
+}
+
+func TestTransport_RetriesIdempotent(t *testing.T) {
+	srv := newFakeServer(t, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK)
+	client := newTestClient(Config{})
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if srv.requests() != 3 {
+		t.Errorf("Expected 3 requests, got %d", srv.requests())
+	}
+}
+
+func TestTransport_MaxRetries(t *testing.T) {
+	srv := newFakeServer(t, 500, 500, 500, 500, 500)
+	client := newTestClient(Config{MaxRetries: 2})
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected the last response with status 500, got %d", resp.StatusCode)
+	}
+	if srv.requests() != 3 {
+		t.Errorf("Expected 3 requests, got %d", srv.requests())
+	}
+
+	srv = newFakeServer(t, 500, 500)
+	client = newTestClient(Config{MaxRetries: -1})
+	resp, err = client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if srv.requests() != 1 {
+		t.Errorf("Expected retries to be disabled, got %d requests", srv.requests())
+	}
+}
+
+func TestTransport_NotRetried(t *testing.T) {
+	srv := newFakeServer(t, http.StatusBadRequest, http.StatusOK)
+	client := newTestClient(Config{})
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", resp.StatusCode)
+	}
+	if srv.requests() != 1 {
+		t.Errorf("Expected 1 request, got %d", srv.requests())
+	}
+}
+
+func TestTransport_Post(t *testing.T) {
+	// a failed POST may have been processed
+	srv := newFakeServer(t, http.StatusInternalServerError, http.StatusOK)
+	client := newTestClient(Config{})
+
+	resp, err := client.Post(srv.URL, "application/json", strings.NewReader(`{"order":1}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError || srv.requests() != 1 {
+		t.Errorf("Expected the POST not to be retried, got status %d after %d requests", resp.StatusCode, srv.requests())
+	}
+
+	// a rejected POST was not
+	srv = newFakeServer(t, http.StatusTooManyRequests, http.StatusOK)
+	resp, err = client.Post(srv.URL, "application/json", strings.NewReader(`{"order":1}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || srv.requests() != 2 {
+		t.Errorf("Expected the POST to be retried after 429, got status %d after %d requests", resp.StatusCode, srv.requests())
+	}
+
+	// Retryable allows retries of requests the exchange deduplicates
+	srv = newFakeServer(t, http.StatusInternalServerError, http.StatusOK)
+	client = newTestClient(Config{Retryable: func(r *http.Request) bool {
+		return r.Header.Get("Idempotency-Key") != ""
+	}})
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{"order":1}`))
+	req.Header.Set("Idempotency-Key", "abc")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || srv.requests() != 2 {
+		t.Errorf("Expected the POST to be retried, got status %d after %d requests", resp.StatusCode, srv.requests())
+	}
+	for i, body := range srv.bodies {
+		if body != `{"order":1}` {
+			t.Errorf("Expected the body of attempt %d to be sent again, got %q", i, body)
+		}
+	}
+}
+
+func TestTransport_NetworkError(t *testing.T) {
+	var attempts int32
+	base := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			return nil, errors.New("connection reset")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+	})
+	client := &http.Client{Transport: New(base, Config{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond})}
+
+	resp, err := client.Get("http://exchange.test/")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+
+	atomic.StoreInt32(&attempts, 0)
+	_, err = client.Post("http://exchange.test/", "application/json", strings.NewReader(`{}`))
+	if err == nil {
+		t.Errorf("Expected the network error of the POST")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestTransport_RetryAfter(t *testing.T) {
+	srv := newFakeServer(t, http.StatusTooManyRequests, http.StatusOK)
+	srv.header.Set("Retry-After", "1")
+	// the backoff alone would retry after at most 5 milliseconds
+	client := newTestClient(Config{})
+
+	start := time.Now()
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected the retry to wait for Retry-After, got %s", elapsed)
+	}
+	if srv.requests() != 2 {
+		t.Errorf("Expected 2 requests, got %d", srv.requests())
+	}
+}
+
+func TestTransport_Cancel(t *testing.T) {
+	srv := newFakeServer(t, http.StatusTooManyRequests, http.StatusOK)
+	srv.header.Set("Retry-After", "60")
+	client := newTestClient(Config{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+
+	start := time.Now()
+	_, err := client.Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the backoff to be cancelled, got %s", elapsed)
+	}
+}
+
+func TestTransport_Sign(t *testing.T) {
+	srv := newFakeServer(t, http.StatusServiceUnavailable, http.StatusOK)
+	var signed int32
+	client := newTestClient(Config{Sign: func(r *http.Request) error {
+		n := atomic.AddInt32(&signed, 1)
+		r.Header.Set("Authorization", "Bearer "+string(rune('0'+n)))
+		return nil
+	}})
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if len(srv.auth) != 2 || srv.auth[0] != "Bearer 1" || srv.auth[1] != "Bearer 2" {
+		t.Errorf("Expected every attempt to be signed again, got %v", srv.auth)
+	}
+
+	client = newTestClient(Config{Sign: func(*http.Request) error { return errors.New("no key") }})
+	if _, err := client.Get(srv.URL); err == nil || !strings.Contains(err.Error(), "no key") {
+		t.Errorf("Expected the signing error, got %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tr := New(nil, Config{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second})
+
+	for attempt, max := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for i := 0; i < 20; i++ {
+			d := tr.backoff(attempt)
+			if d < max/2 || d > max {
+				t.Errorf("Expected the backoff of attempt %d between %s and %s, got %s", attempt, max/2, max, d)
+			}
+		}
+	}
+	if d := tr.backoff(100); d > time.Second {
+		t.Errorf("Expected the backoff to be capped, got %s", d)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if d, ok := retryAfter("3"); !ok || d != 3*time.Second {
+		t.Errorf("Expected 3s, got %s %v", d, ok)
+	}
+	if d, ok := retryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); !ok || d < 59*time.Minute {
+		t.Errorf("Expected about an hour, got %s %v", d, ok)
+	}
+	for _, header := range []string{"", "soon", "-1"} {
+		if _, ok := retryAfter(header); ok {
+			t.Errorf("Expected %q to be ignored", header)
+		}
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// runState is shared between the daemon loop and the control API.
+type runState struct {
+	mu   sync.Mutex
+	next time.Time
+	last *runResult
+}
+
+func (st *runState) setNext(next time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.next = next
+}
+
+func (st *runState) finished(r *runResult) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.last = r
+}
+
+// snapshot returns the next run and last run result.
+func (st *runState) snapshot() (time.Time, *runResult) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.next, st.last
+}
+
+// runTrigger asks the daemon to run now. The result is sent to done.
+type runTrigger struct {
+	force bool
+	done  chan<- *runResult
+}
+
+// runDaemon keeps running Sync at every purchase window until ctx is
+// cancelled or the --until deadline passes. Runs triggered through the
+// control API are served between windows.
+func runDaemon(ctx context.Context, s *gdaxSchedule) {
+	for {
+		s.daemonRun(ctx, false)
+
+		now := time.Now()
+		if !s.req.until.IsZero() && now.After(s.req.until) {
+			s.logger.Infow("Deadline has passed, stopping")
+			return
+		}
+
+		next := s.nextRun(now)
+		if next.IsZero() {
+			s.logger.Infow("Schedule has no further runs, stopping")
+			return
+		}
+
+		nextRunTimestamp.Set(float64(next.Unix()), s.profile)
+		s.state.setNext(next)
+
+		s.logger.Infow(
+			"Next run",
+			"time", next.Local(),
+		)
+
+		timer := time.NewTimer(next.Sub(now))
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				s.logger.Infow("Shutting down")
+				return
+			case t := <-s.triggers:
+				t.done <- s.daemonRun(ctx, t.force)
+			case <-timer.C:
+				break wait
+			}
+		}
+	}
+}
+
+// daemonRun runs Sync and records its outcome. Forced runs come from the
+// control API and were confirmed there. Shutting down the daemon cancels ctx
+// and with it the run.
+func (s *gdaxSchedule) daemonRun(ctx context.Context, force bool) *runResult {
+	confirm := s.confirmFunc
+	if force {
+		confirm = func(string) bool { return true }
+	}
+
+	r, err := s.run(ctx, force, confirm)
+	recordRun(r)
+	s.state.finished(r)
+	if err != nil {
+		s.logger.Warn(err.Error())
+	}
+
+	return r
+}
@@ -4,13 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/coinbase-samples/advanced-trade-sdk-go/accounts"
 	"github.com/coinbase-samples/advanced-trade-sdk-go/client"
 	"github.com/coinbase-samples/advanced-trade-sdk-go/model"
 	"github.com/coinbase-samples/advanced-trade-sdk-go/orders"
 	"github.com/coinbase-samples/advanced-trade-sdk-go/paymentmethods"
 	"github.com/coinbase-samples/advanced-trade-sdk-go/portfolios"
 	"github.com/coinbase-samples/advanced-trade-sdk-go/products"
+	"github.com/coinbase-samples/core-go"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
@@ -23,14 +24,17 @@ import (
 )
 
 type CoinbaseV3 struct {
-	portfolio       portfolios.PortfoliosService
-	products        products.ProductsService
-	payment         paymentmethods.PaymentMethodsService
-	accountsService accounts.AccountsService
-	orders          orders.OrdersService
-	client3         client.RestClient
-	client          *exchange.Client
-	accounts        map[string]*account
+	portfolio   portfolios.PortfoliosService
+	products    products.ProductsService
+	payment     paymentmethods.PaymentMethodsService
+	orders      orders.OrdersService
+	client3     client.RestClient
+	client      *exchange.Client
+	api         *coinbasev3.ApiClient
+	portfolioId string
+	accounts    map[string]string // account uuid per currency, balances are always fetched
+	tracker     *coinbasev3.OrderTracker
+	candles     *coinbasev3.CandleStore
 }
 
 type account struct {
@@ -40,21 +44,41 @@ type account struct {
 	Currency  string  `json:"currency"`
 }
 
+// CoinbaseCredentials name the environment variables holding the API key
+// of an account and the portfolio orders are placed in.
+type CoinbaseCredentials struct {
+	KeyEnv       string
+	SecretEnv    string
+	PortfolioEnv string
+}
+
+// DefaultCoinbaseCredentials are used without credential profiles.
+var DefaultCoinbaseCredentials = CoinbaseCredentials{
+	KeyEnv:       "COINBASE_KEY",
+	SecretEnv:    "COINBASE_SECRET",
+	PortfolioEnv: "PORTFOLIO_ID",
+}
+
 func NewCoinbaseV3() (*CoinbaseV3, error) {
-	secret := os.Getenv("COINBASE_SECRET")
-	key := os.Getenv("COINBASE_KEY")
-	portfolioId := os.Getenv("PORTFOLIO_ID")
+	return NewCoinbaseV3Account(DefaultCoinbaseCredentials)
+}
+
+// NewCoinbaseV3Account connects to the account and portfolio of creds.
+func NewCoinbaseV3Account(creds CoinbaseCredentials) (*CoinbaseV3, error) {
+	secret := os.Getenv(creds.SecretEnv)
+	key := os.Getenv(creds.KeyEnv)
+	portfolioId := os.Getenv(creds.PortfolioEnv)
 
 	if secret == "" {
-		return nil, errors.New("COINBASE_SECRET environment variable is required")
+		return nil, fmt.Errorf("%s environment variable is required", creds.SecretEnv)
 	}
 
 	if key == "" {
-		return nil, errors.New("COINBASE_KEY environment variable is required")
+		return nil, fmt.Errorf("%s environment variable is required", creds.KeyEnv)
 	}
 
 	if portfolioId == "" {
-		return nil, errors.New("PORTFOLIO_ID environment variable is required")
+		return nil, fmt.Errorf("%s environment variable is required", creds.PortfolioEnv)
 	}
 
 	// to allow new token pem format be passed via .env file
@@ -66,91 +90,211 @@ func NewCoinbaseV3() (*CoinbaseV3, error) {
 	products := products.NewProductsService(client3.GetClient())
 	payment := paymentmethods.NewPaymentMethodsService(client3.GetClient())
 	orders := orders.NewOrdersService(client3.GetClient())
-	accountsService := accounts.NewAccountsService(client3.GetClient())
 
 	return &CoinbaseV3{
-		accounts:        map[string]*account{},
-		portfolio:       portfolio,
-		products:        products,
-		accountsService: accountsService,
-		payment:         payment,
-		orders:          orders,
-		client3:         client3.GetClient(),
-		client:          client,
+		accounts:    map[string]string{},
+		portfolio:   portfolio,
+		products:    products,
+		payment:     payment,
+		orders:      orders,
+		client3:     client3.GetClient(),
+		client:      client,
+		api:         client3,
+		portfolioId: portfolioId,
 	}, nil
 }
 
+// SetTransport sends the requests to Coinbase through base, e.g. a transport.Recorder replaying fixtures. The SDK
+// services share the http client of the API client, so they use it too.
+func (c *CoinbaseV3) SetTransport(base http.RoundTripper) {
+	c.api.SetTransport(base)
+	c.client.SetTransport(base)
+}
+
 func (c *CoinbaseV3) CreateOrder(ctx context.Context, productId string, amount float64, orderType OrderTypeType, limitOrderFunc CalcLimitOrder) (*Order, error) {
+	orderConfig, err := c.orderConfiguration(ctx, productId, amount, orderType, limitOrderFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	orderReq := orders.CreateOrderRequest{
+		ProductId:          productId,
+		OrderConfiguration: orderConfig,
+		Side:               coinbasev3.OrderSideBuy,
+		ClientOrderId:      uuid.NewString(),
+		RetailPortfolioId:  c.portfolioId,
+	}
 
-	var orderReq orders.CreateOrderRequest
+	if c.tracker != nil {
+		c.tracker.Track(orderReq.ClientOrderId)
+	}
 
-	if orderType == Limit {
-		marketTradeRequest := products.GetMarketTradesRequest{
-			ProductId: productId,
-			Limit:     "10",
-		}
-		trades, err := c.products.GetMarketTrades(ctx, &marketTradeRequest)
-		if err != nil {
-			return nil, err
-		}
+	order, err := c.orders.CreateOrder(ctx, &orderReq)
+	err = apiError(err)
 
-		bestAsk, err := decimal.NewFromString(trades.BestAsk)
-		if err != nil {
-			return nil, err
-		}
-		orderPrice, orderSize := limitOrderFunc(bestAsk, decimal.NewFromFloat(amount))
+	if err == nil && !order.Success {
+		err = orderError(order)
+	}
 
-		orderConfig := model.OrderConfiguration{
-			LimitLimitGtc: &model.LimitGtc{
-				BaseSize:   orderPrice.String(),
-				LimitPrice: orderSize.String(),
-			},
+	if err != nil {
+		if c.tracker != nil {
+			c.tracker.Forget(orderReq.ClientOrderId)
 		}
-		orderReq = orders.CreateOrderRequest{
-			ProductId:          productId,
-			OrderConfiguration: orderConfig,
-			Side:               coinbasev3.OrderSideBuy,
-			ClientOrderId:      uuid.NewString(),
+		return nil, err
+	}
+
+	if c.tracker != nil {
+		c.tracker.Placed(orderReq.ClientOrderId, order.SuccessResponse.OrderId)
+	}
+
+	return &Order{
+		Symbol:        order.SuccessResponse.ProductId,
+		OrderID:       order.SuccessResponse.OrderId,
+		ClientOrderID: orderReq.ClientOrderId,
+	}, nil
+}
+
+// orderError returns the failure of an order Coinbase did not place, e.g.
+// insufficient funds, as a coinbasev3.ResponseError.
+func orderError(order *orders.CreateOrderResponse) error {
+	var resp coinbasev3.CreatOrderErrorResponse
+	if order.ErrorResponse != nil {
+		resp = coinbasev3.CreatOrderErrorResponse{
+			Error:                 order.ErrorResponse.Error,
+			Message:               order.ErrorResponse.Message,
+			ErrorDetails:          order.ErrorResponse.ErrorDetails,
+			PreviewFailureReason:  order.ErrorResponse.PreviewFailureReason,
+			NewOrderFailureReason: order.ErrorResponse.NewOrderFailureReason,
 		}
-	} else {
-		orderConfig := model.OrderConfiguration{
+	}
+	return coinbasev3.NewOrderError(order.FailureReason, resp)
+}
+
+// apiError converts the unexpected responses of the Advanced Trade SDK into a
+// coinbasev3.ResponseError, other errors are returned as they are.
+func apiError(err error) error {
+	var apiErr *core.ApiError
+	if errors.As(err, &apiErr) {
+		return coinbasev3.ResponseError{StatusCode: apiErr.CodeReceived, Message: apiErr.Message}
+	}
+	return err
+}
+
+// TrackOrders creates an order tracker for the orders placed from now on.
+// Wait for an order with its ClientOrderID, the tracker polls the order
+// until it is fed the user channel of a websocket connection.
+func (c *CoinbaseV3) TrackOrders(cfg coinbasev3.OrderTrackerConfig) *coinbasev3.OrderTracker {
+	c.tracker = coinbasev3.NewOrderTracker(c.api, cfg)
+	return c.tracker
+}
+
+// orderConfiguration builds the order placed by CreateOrder and validated by
+// PreviewOrder. Market orders spend amount in the quote currency, limit
+// orders are priced off the best ask.
+func (c *CoinbaseV3) orderConfiguration(ctx context.Context, productId string, amount float64, orderType OrderTypeType, limitOrderFunc CalcLimitOrder) (model.OrderConfiguration, error) {
+	if orderType != Limit {
+		return model.OrderConfiguration{
 			MarketMarketIoc: &model.MarketIoc{
 				QuoteSize: decimal.NewFromFloat(amount).StringFixedBank(2),
 			},
-		}
-		orderReq = orders.CreateOrderRequest{
-			ProductId:          productId,
-			OrderConfiguration: orderConfig,
-			Side:               coinbasev3.OrderSideBuy,
-			ClientOrderId:      uuid.NewString(),
-		}
+		}, nil
+	}
+
+	marketTradeRequest := products.GetMarketTradesRequest{
+		ProductId: productId,
+		Limit:     "10",
 	}
-	orderConfig := model.OrderConfiguration{
-		MarketMarketIoc: &model.MarketIoc{
-			QuoteSize: decimal.NewFromFloat(amount).StringFixedBank(2),
+	trades, err := c.products.GetMarketTrades(ctx, &marketTradeRequest)
+	if err != nil {
+		return model.OrderConfiguration{}, err
+	}
+
+	bestAsk, err := decimal.NewFromString(trades.BestAsk)
+	if err != nil {
+		return model.OrderConfiguration{}, err
+	}
+	orderPrice, orderSize := limitOrderFunc(bestAsk, decimal.NewFromFloat(amount))
+
+	return model.OrderConfiguration{
+		LimitLimitGtc: &model.LimitGtc{
+			BaseSize:   orderSize.String(),
+			LimitPrice: orderPrice.String(),
 		},
+	}, nil
+}
+
+func (c *CoinbaseV3) PreviewOrder(ctx context.Context, productId string, amount float64, orderType OrderTypeType, limitOrderFunc CalcLimitOrder) (*OrderPreview, error) {
+	orderConfig, err := c.orderConfiguration(ctx, productId, amount, orderType, limitOrderFunc)
+	if err != nil {
+		return nil, err
 	}
-	orderReq = orders.CreateOrderRequest{
+
+	preview, err := c.orders.CreateOrderPreview(ctx, &orders.CreateOrderPreviewRequest{
 		ProductId:          productId,
-		OrderConfiguration: orderConfig,
 		Side:               coinbasev3.OrderSideBuy,
-		ClientOrderId:      uuid.NewString(),
+		OrderConfiguration: orderConfig,
+		RetailPortfolioId:  c.portfolioId,
+	})
+	if err != nil {
+		return nil, apiError(err)
 	}
 
-	order, err := c.orders.CreateOrder(ctx, &orderReq)
+	return parseOrderPreview(preview)
+}
+
+func parseOrderPreview(p *orders.CreateOrderPreviewResponse) (*OrderPreview, error) {
+	preview := &OrderPreview{
+		Errors:   p.Errs,
+		Warnings: p.Warning,
+	}
+
+	values := []struct {
+		raw    string
+		target *float64
+	}{
+		{p.QuoteSize, &preview.QuoteSize},
+		{p.BaseSize, &preview.BaseSize},
+		{p.OrderTotal, &preview.Total},
+		{p.CommissionTotal, &preview.Commission},
+	}
+	for _, v := range values {
+		if v.raw == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v.raw, 64)
+		if err != nil {
+			return nil, err
+		}
+		*v.target = f
+	}
+
+	return preview, nil
+}
 
+// GetFeeRates returns the spot fee tier from the transaction summary.
+func (c *CoinbaseV3) GetFeeRates(ctx context.Context) (*FeeRates, error) {
+	summary, err := c.api.GetTransactionSummary(ctx, coinbasev3.TransactionSummaryRequest{
+		ProductType: coinbasev3.ProductTypeSpot,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if !order.Success {
-		return nil, errors.New(fmt.Sprintf("order failed with %s, %s", order.FailureReason, order.ErrorResponse.Message))
+	return parseFeeTier(summary.FeeTier)
+}
+
+func parseFeeTier(tier coinbasev3.FeeTier) (*FeeRates, error) {
+	maker, err := strconv.ParseFloat(tier.MakerFeeRate, 64)
+	if err != nil {
+		return nil, err
 	}
 
-	return &Order{
-		Symbol:  order.SuccessResponse.ProductId,
-		OrderID: order.SuccessResponse.OrderId,
-	}, nil
+	taker, err := strconv.ParseFloat(tier.TakerFeeRate, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FeeRates{Tier: tier.PricingTier, Maker: maker, Taker: taker}, nil
 }
 
 func (c *CoinbaseV3) GetTickerSymbol(baseCurrency string, quoteCurrency string) string {
@@ -175,13 +319,213 @@ func (c *CoinbaseV3) GetTicker(ctx context.Context, productId string) (*Ticker,
 	return &Ticker{Price: bestAsk}, nil
 }
 
+func (c *CoinbaseV3) GetBestBidAsk(ctx context.Context, productId string) (*BidAsk, error) {
+	data, err := c.api.GetBestBidAsk(ctx, []string{productId})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, book := range data.PriceBooks {
+		if book.ProductId != productId {
+			continue
+		}
+
+		if len(book.Bids) == 0 || len(book.Asks) == 0 {
+			return nil, fmt.Errorf("empty order book for %s", productId)
+		}
+
+		bid, err := strconv.ParseFloat(book.Bids[0].Price, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		ask, err := strconv.ParseFloat(book.Asks[0].Price, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		return &BidAsk{Bid: bid, Ask: ask}, nil
+	}
+
+	return nil, fmt.Errorf("no best bid/ask returned for %s", productId)
+}
+
+func (c *CoinbaseV3) GetSpotPrice(ctx context.Context, productId string) (*Ticker, error) {
+	price, err := c.api.GetSpotPrice(ctx, productId)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := strconv.ParseFloat(price.Data.Amount, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Ticker{Price: amount}, nil
+}
+
+// UseCandleCache keeps the hourly candles of GetCandles in dir, only the
+// candles missing from it are requested.
+func (c *CoinbaseV3) UseCandleCache(dir string) {
+	c.candles = coinbasev3.NewCandleStore(c.api, coinbasev3.CandleStoreConfig{Dir: dir})
+}
+
+// GetCandles returns up to count most recent hourly candles, newest first.
+func (c *CoinbaseV3) GetCandles(ctx context.Context, productId string, count int) ([]Candle, error) {
+	end := time.Now()
+	start := end.Add(-time.Duration(count) * time.Hour)
+
+	if c.candles != nil {
+		return c.cachedCandles(ctx, productId, start, end, count)
+	}
+
+	data, err := c.api.GetProductCandles(
+		ctx,
+		productId,
+		strconv.FormatInt(start.Unix(), 10),
+		strconv.FormatInt(end.Unix(), 10),
+		coinbasev3.GranularityOneHour,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	candles := make([]Candle, 0, len(data))
+	for _, d := range data {
+		candle, err := parseCandle(d)
+		if err != nil {
+			return nil, err
+		}
+		candles = append(candles, candle)
+		if len(candles) == count {
+			break
+		}
+	}
+
+	return candles, nil
+}
+
+func (c *CoinbaseV3) cachedCandles(ctx context.Context, productId string, start time.Time, end time.Time, count int) ([]Candle, error) {
+	data, err := c.candles.Candles(ctx, productId, coinbasev3.GranularityOneHour, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	candles := make([]Candle, 0, count)
+	for i := len(data) - 1; i >= 0 && len(candles) < count; i-- {
+		d := data[i]
+		candles = append(candles, Candle{
+			Start: d.Start,
+			Open:  d.Open.InexactFloat64(),
+			High:  d.High.InexactFloat64(),
+			Low:   d.Low.InexactFloat64(),
+			Close: d.Close.InexactFloat64(),
+		})
+	}
+
+	return candles, nil
+}
+
+func parseCandle(d coinbasev3.ProductCandles) (Candle, error) {
+	var candle Candle
+
+	start, err := strconv.ParseInt(d.Start, 10, 64)
+	if err != nil {
+		return candle, err
+	}
+	candle.Start = time.Unix(start, 0)
+
+	values := []struct {
+		raw    string
+		target *float64
+	}{
+		{d.Open, &candle.Open},
+		{d.High, &candle.High},
+		{d.Low, &candle.Low},
+		{d.Close, &candle.Close},
+	}
+	for _, v := range values {
+		*v.target, err = strconv.ParseFloat(v.raw, 64)
+		if err != nil {
+			return candle, err
+		}
+	}
+
+	return candle, nil
+}
+
+// GetFills returns the fills history, following the cursors returned by Coinbase.
+func (c *CoinbaseV3) GetFills(ctx context.Context, productId string, start time.Time, end time.Time) ([]Fill, error) {
+	q := coinbasev3.ListFillsQuery{
+		ProductId: productId,
+		Limit:     100,
+	}
+	if !start.IsZero() {
+		q.StartSequenceTimestamp = start.UTC().Format(time.RFC3339)
+	}
+	if !end.IsZero() {
+		q.EndSequenceTimestamp = end.UTC().Format(time.RFC3339)
+	}
+
+	fills := []Fill{}
+	it := c.api.FillsIter(q, 0)
+	for it.Next(ctx) {
+		fill, err := parseFill(it.Value())
+		if err != nil {
+			return nil, err
+		}
+		fills = append(fills, fill)
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return fills, nil
+}
+
+func parseFill(f coinbasev3.Fill) (Fill, error) {
+	fill := Fill{
+		TradeID:   f.TradeId,
+		OrderID:   f.OrderId,
+		ProductID: f.ProductId,
+		Side:      strings.ToUpper(f.Side),
+		Time:      f.TradeTime,
+	}
+
+	currencies := strings.SplitN(f.ProductId, "-", 2)
+	if len(currencies) != 2 {
+		return fill, fmt.Errorf("unexpected product %s in fill %s", f.ProductId, f.TradeId)
+	}
+	fill.BaseCurrency, fill.QuoteCurrency = currencies[0], currencies[1]
+
+	var err error
+	if fill.Price, err = decimal.NewFromString(f.Price); err != nil {
+		return fill, err
+	}
+	if fill.Size, err = decimal.NewFromString(f.Size); err != nil {
+		return fill, err
+	}
+	if f.Commission != "" {
+		if fill.Commission, err = decimal.NewFromString(f.Commission); err != nil {
+			return fill, err
+		}
+	}
+
+	// size is the quote amount for orders placed in quote currency
+	if f.SizeInQuote && fill.Price.IsPositive() {
+		fill.Size = fill.Size.Div(fill.Price).Round(8)
+	}
+
+	return fill, nil
+}
+
 func (c *CoinbaseV3) GetProduct(ctx context.Context, productId string) (*Product, error) {
 	productRequest := products.GetProductRequest{
 		ProductId: productId,
 	}
 	product, err := c.products.GetProduct(ctx, &productRequest)
 	if err != nil {
-		return nil, err
+		return nil, apiError(err)
 	}
 
 	price, err := strconv.ParseFloat(product.BaseMinSize, 64)
@@ -197,10 +541,32 @@ func (c *CoinbaseV3) GetProduct(ctx context.Context, productId string) (*Product
 }
 
 func (c *CoinbaseV3) Deposit(ctx context.Context, currency string, amount float64) (*time.Time, error) {
-	account, err := c.accountFor(ctx, currency) //taking the first coins a marker, make sure to put your main coin first
+	accountId, err := c.accountId(ctx, currency) //taking the first coins a marker, make sure to put your main coin first
+	if err != nil {
+		return nil, err
+	}
+	bankAccount, err := c.bankAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	depositResponse, err := c.client.Deposit(ctx, accountId, exchange.DepositParams{
+		Amount:          amount,
+		Currency:        currency,
+		PaymentMethodID: bankAccount.Id,
+		Commit:          true,
+	})
+
 	if err != nil {
 		return nil, err
 	}
+
+	payoutAt := depositResponse.Data.PayoutAt
+	return &payoutAt, nil
+}
+
+// bankAccount returns the ACH payment method deposits are made from.
+func (c *CoinbaseV3) bankAccount(ctx context.Context) (*model.PaymentMethod, error) {
 	paymentMethodRequest := paymentmethods.ListPaymentMethodsRequest{}
 	paymentMethods, err := c.payment.ListPaymentMethods(ctx, &paymentMethodRequest)
 
@@ -220,47 +586,36 @@ func (c *CoinbaseV3) Deposit(ctx context.Context, currency string, amount float6
 		return nil, errors.New("No ACH bank account found on this account")
 	}
 
-	depositResponse, err := c.client.Deposit(account.Id, exchange.DepositParams{
-		Amount:          amount,
-		Currency:        currency,
-		PaymentMethodID: bankAccount.Id,
-		Commit:          true,
-	})
+	return bankAccount, nil
+}
 
+func (c *CoinbaseV3) GetDepositMethod(ctx context.Context, currency string) (*PaymentMethod, error) {
+	bankAccount, err := c.bankAccount(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	payoutAt := depositResponse.Data.PayoutAt
-	return &payoutAt, nil
+	return &PaymentMethod{
+		ID:   bankAccount.Id,
+		Name: bankAccount.Name,
+		Type: bankAccount.Type,
+	}, nil
 }
 
 func (c *CoinbaseV3) LastPurchaseTime(ctx context.Context, coin string, currency string, since time.Time) (*time.Time, error) {
-
-	productIds := make([]string, 1)
-	productIds[0] = c.GetTickerSymbol(coin, currency)
-	orderList, err := c.orders.ListOrders(ctx, &orders.ListOrdersRequest{
-		ProductIds:  productIds,
-		StartDate:   since.Format("2006-01-02T15:04:05.999999999Z07:00"),
-		OrderStatus: []string{"FILLED"},
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	if len(orderList.Orders) > 0 {
-		layout := time.RFC3339 // This is the layout matching the string format
-
-		t, err := time.Parse(layout, orderList.Orders[0].CreatedTime)
-		if err != nil {
-			fmt.Println("Error:", err)
-			return nil, err
-		}
+	// orders are returned newest first, so the first one is the last purchase
+	it := c.api.OrdersIter(coinbasev3.ListOrdersQuery{
+		ProductId:         c.GetTickerSymbol(coin, currency),
+		StartDate:         since.UTC().Format(time.RFC3339Nano),
+		OrderStatus:       []string{"FILLED"},
+		RetailPortfolioId: c.portfolioId,
+	}, 1)
+	if it.Next(ctx) {
+		t := it.Value().CreatedTime
 		return &t, nil
 	}
 
-	return nil, nil
+	return nil, it.Err()
 }
 
 func (c *CoinbaseV3) GetFiatAccount(ctx context.Context, currency string) (*Account, error) {
@@ -273,7 +628,7 @@ func (c *CoinbaseV3) GetFiatAccount(ctx context.Context, currency string) (*Acco
 	return &Account{Available: account.Available}, nil
 }
 
-func (c *CoinbaseV3) GetPendingTransfers(currency string) ([]PendingTransfer, error) {
+func (c *CoinbaseV3) GetPendingTransfers(ctx context.Context, currency string) ([]PendingTransfer, error) {
 	pendingTransfers := []PendingTransfer{}
 	// // Dang, we don't have enough funds. Let's see if money is on the way.
 	// var transfers []exchange.Transfer
@@ -300,24 +655,29 @@ func (c *CoinbaseV3) GetPendingTransfers(currency string) ([]PendingTransfer, er
 	return pendingTransfers, nil
 }
 
-func (c *CoinbaseV3) accountFor(ctx context.Context, currencyCode string) (*account, error) {
-
-	// cache accounts
-	if a, found := c.accounts[currencyCode]; found {
-		return a, nil
+// accountId returns the uuid of the wallet of currencyCode, it never changes so only the first call lists the accounts.
+func (c *CoinbaseV3) accountId(ctx context.Context, currencyCode string) (string, error) {
+	if id, found := c.accounts[currencyCode]; found {
+		return id, nil
 	}
-	listAccountsRequest := accounts.ListAccountsRequest{
-		Pagination: &model.PaginationParams{
-			Cursor: "",
-			Limit:  "100",
-		},
-	}
-	accounts, err := c.accountsService.ListAccounts(ctx, &listAccountsRequest)
+
+	acct, err := c.accountFor(ctx, currencyCode)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	return acct.Id, nil
+}
+
+// accountFor returns the wallet of currencyCode with its current balance. The balance changes with every purchase
+// and deposit, so it is not cached.
+func (c *CoinbaseV3) accountFor(ctx context.Context, currencyCode string) (*account, error) {
+	it := c.api.AccountsIter(0)
+	for it.Next(ctx) {
+		a := it.Value()
+		if a.Currency != currencyCode {
+			continue
+		}
 
-	for _, a := range accounts.Accounts {
 		available, err := strconv.ParseFloat(a.AvailableBalance.Value, 64)
 		if err != nil {
 			return nil, err
@@ -328,17 +688,18 @@ func (c *CoinbaseV3) accountFor(ctx context.Context, currencyCode string) (*acco
 			return nil, err
 		}
 
-		if a.Currency == currencyCode {
-			acct := &account{
-				Id:        a.Uuid,
-				Currency:  a.Currency,
-				Available: available,
-				Hold:      hold,
-			}
-
-			c.accounts[currencyCode] = acct
-			return acct, nil
+		acct := &account{
+			Id:        a.Uuid,
+			Currency:  a.Currency,
+			Available: available,
+			Hold:      hold,
 		}
+
+		c.accounts[currencyCode] = acct.Id
+		return acct, nil
+	}
+	if err := it.Err(); err != nil {
+		return nil, apiError(err)
 	}
 
 	return nil, fmt.Errorf("No %s wallet on this account", currencyCode)
@@ -0,0 +1,148 @@
+package exchanges
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/coinbase-samples/advanced-trade-sdk-go/model"
+	"github.com/coinbase-samples/advanced-trade-sdk-go/orders"
+	"github.com/coinbase-samples/core-go"
+	"github.com/sberserker/dcagdax/clients/coinbasev3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFill(t *testing.T) {
+	fill, err := parseFill(coinbasev3.Fill{TradeId: "1", ProductId: "BTC-USD", Side: "BUY", Price: "20000", Size: "50", Commission: "0.3", SizeInQuote: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "BTC", fill.BaseCurrency)
+	assert.Equal(t, "USD", fill.QuoteCurrency)
+	assert.Equal(t, "0.0025", fill.Size.String())
+	assert.Equal(t, "0.3", fill.Commission.String())
+
+	_, err = parseFill(coinbasev3.Fill{TradeId: "2", ProductId: "BTCUSD", Price: "1", Size: "1"})
+	assert.EqualError(t, err, "unexpected product BTCUSD in fill 2")
+}
+
+func TestParseOrderPreview(t *testing.T) {
+	preview, err := parseOrderPreview(&orders.CreateOrderPreviewResponse{
+		QuoteSize:       "49.70",
+		BaseSize:        "0.0025",
+		OrderTotal:      "50",
+		CommissionTotal: "0.30",
+		Errs:            []string{"PREVIEW_INSUFFICIENT_FUND"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, &OrderPreview{QuoteSize: 49.7, BaseSize: 0.0025, Total: 50, Commission: 0.3, Errors: []string{"PREVIEW_INSUFFICIENT_FUND"}}, preview)
+
+	_, err = parseOrderPreview(&orders.CreateOrderPreviewResponse{OrderTotal: "abc"})
+	assert.NotNil(t, err)
+}
+
+func TestOrderErrors(t *testing.T) {
+	err := orderError(&orders.CreateOrderResponse{
+		FailureReason: "UNKNOWN_FAILURE_REASON",
+		ErrorResponse: &model.ErrorResponse{Error: "INSUFFICIENT_FUND", Message: "Insufficient balance in source account"},
+	})
+	assert.True(t, errors.Is(err, ErrInsufficientFunds))
+
+	// a failed order without error response
+	err = orderError(&orders.CreateOrderResponse{FailureReason: "UNSUPPORTED_ORDER_CONFIGURATION"})
+	assert.EqualError(t, err, "coinbase: 200 UNSUPPORTED_ORDER_CONFIGURATION order failed")
+
+	err = apiError(&core.ApiError{Message: "Unauthorized", CodeReceived: 401})
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+
+	other := errors.New("connection reset")
+	assert.Equal(t, other, apiError(other))
+}
+
+func TestParseFeeTier(t *testing.T) {
+	rates, err := parseFeeTier(coinbasev3.FeeTier{PricingTier: "Advanced 1", MakerFeeRate: "0.004", TakerFeeRate: "0.006"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, &FeeRates{Tier: "Advanced 1", Maker: 0.004, Taker: 0.006}, rates)
+}
+
+type fakeCandleGetter struct {
+	calls int
+}
+
+func (f *fakeCandleGetter) GetProductCandles(ctx context.Context, productId, start, end string, granularity coinbasev3.Granularity) ([]coinbasev3.ProductCandles, error) {
+	f.calls++
+	from, _ := strconv.ParseInt(start, 10, 64)
+	to, _ := strconv.ParseInt(end, 10, 64)
+
+	candles := []coinbasev3.ProductCandles{}
+	for t := from; t <= to; t += 3600 {
+		price := strconv.FormatInt(t/3600%100, 10)
+		candles = append(candles, coinbasev3.ProductCandles{Start: strconv.FormatInt(t, 10), Open: price, High: price, Low: price, Close: price})
+	}
+	return candles, nil
+}
+
+func TestGetCandlesFromCache(t *testing.T) {
+	api := &fakeCandleGetter{}
+	c := &CoinbaseV3{candles: coinbasev3.NewCandleStore(api, coinbasev3.CandleStoreConfig{Dir: t.TempDir()})}
+
+	candles, err := c.GetCandles(context.Background(), "BTC-USD", 24)
+	assert.Nil(t, err)
+	assert.Len(t, candles, 24)
+	assert.True(t, candles[0].Start.After(candles[1].Start), "newest first")
+	assert.Equal(t, float64(candles[0].Start.Unix()/3600%100), candles[0].Close)
+
+	_, err = c.GetCandles(context.Background(), "BTC-USD", 24)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, api.calls, "only the current hour is requested again")
+}
+
+func TestGetFiatAccountPages(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	secret := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+
+	// the USD wallet is on the second page of accounts
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Query().Get("cursor") == "" {
+			_, _ = w.Write([]byte(`{"accounts":[{"uuid":"1","currency":"BTC","available_balance":{"value":"1"},"hold":{"value":"0"}}],"has_next":true,"cursor":"next"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"accounts":[{"uuid":"2","currency":"USD","available_balance":{"value":"150.5"},"hold":{"value":"10"}}],"has_next":false}`))
+	}))
+	defer srv.Close()
+
+	api := coinbasev3.NewApiClient("api_key", secret, "portfolio_id")
+	api.SetBaseUrlV3(srv.URL)
+	c := &CoinbaseV3{api: api, accounts: map[string]string{}}
+
+	acct, err := c.GetFiatAccount(context.Background(), "USD")
+	assert.Nil(t, err)
+	assert.Equal(t, 150.5, acct.Available)
+
+	// the balance of the last run is never reused
+	_, err = c.GetFiatAccount(context.Background(), "USD")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, requests, "the balance is fetched again")
+
+	id, err := c.accountId(context.Background(), "USD")
+	assert.Nil(t, err)
+	assert.Equal(t, "2", id)
+	assert.Equal(t, 4, requests, "the account id is cached")
+
+	_, err = c.GetFiatAccount(context.Background(), "EUR")
+	assert.EqualError(t, err, "No EUR wallet on this account")
+}
@@ -1,14 +1,24 @@
 package exchanges
 
-//go:generate mockgen -destination=../mocks/mock_exchange.go -package=mocks github.com/sberserker/dcagdax/exchanges Exchange
+//go:generate mockgen -destination=../mocks/mock_exchange.go -package=mocks github.com/sberserker/dcagdax/exchanges Exchange,MarketData,FillHistory,Previewer
 
 import (
 	"context"
 	"time"
 
+	"github.com/sberserker/dcagdax/clients/coinbasev3"
 	"github.com/shopspring/decimal"
 )
 
+// Failures the scheduler reacts to, compare errors with errors.Is. Only the
+// Coinbase exchange tells them apart, others return plain errors.
+var (
+	ErrInsufficientFunds = coinbasev3.ErrInsufficientFunds
+	ErrInvalidProduct    = coinbasev3.ErrInvalidProduct
+	ErrUnauthorized      = coinbasev3.ErrUnauthorized
+	ErrRateLimited       = coinbasev3.ErrRateLimited
+)
+
 type CalcLimitOrder func(askPrice decimal.Decimal, fiatAmount decimal.Decimal) (orderPrice decimal.Decimal, orderSize decimal.Decimal)
 
 type Exchange interface {
@@ -26,7 +36,49 @@ type Exchange interface {
 
 	GetFiatAccount(ctx context.Context, currency string) (*Account, error)
 
-	GetPendingTransfers(currency string) ([]PendingTransfer, error)
+	GetPendingTransfers(ctx context.Context, currency string) ([]PendingTransfer, error)
+}
+
+// PriceSource is implemented by the exchanges which can serve as the
+// reference price of the price guard.
+type PriceSource interface {
+	GetTickerSymbol(baseCurrency string, quoteCurrency string) string
+
+	GetTicker(ctx context.Context, productId string) (*Ticker, error)
+}
+
+// MarketData is implemented by exchanges which can provide reference prices
+// independent of the ticker used for order placement.
+type MarketData interface {
+	GetBestBidAsk(ctx context.Context, productId string) (*BidAsk, error)
+
+	GetSpotPrice(ctx context.Context, productId string) (*Ticker, error)
+
+	GetCandles(ctx context.Context, productId string, count int) ([]Candle, error)
+}
+
+// Previewer is implemented by exchanges which can show what orders and
+// deposits would do without placing them.
+type Previewer interface {
+	// GetFeeRates returns the fee rates of the account's current fee tier.
+	GetFeeRates(ctx context.Context) (*FeeRates, error)
+
+	// PreviewOrder validates the order CreateOrder would place with the same
+	// arguments.
+	PreviewOrder(ctx context.Context, productId string, amount float64, orderType OrderTypeType, limitOrderFunc CalcLimitOrder) (*OrderPreview, error)
+
+	// GetDepositMethod returns the payment method Deposit would use.
+	GetDepositMethod(ctx context.Context, currency string) (*PaymentMethod, error)
+}
+
+// FillHistory is implemented by exchanges which can list past trade fills.
+type FillHistory interface {
+	GetTickerSymbol(baseCurrency string, quoteCurrency string) string
+
+	// GetFills returns fills of productId traded in [start, end). An empty
+	// productId returns fills of all products when the exchange supports it,
+	// zero times leave the range open.
+	GetFills(ctx context.Context, productId string, start time.Time, end time.Time) ([]Fill, error)
 }
 
 type OrderTypeType int32
@@ -39,12 +91,48 @@ const (
 type Order struct {
 	Symbol  string
 	OrderID string
+	// ClientOrderID is the id the order was placed with, when the exchange
+	// supports client order ids.
+	ClientOrderID string
 }
 
 type Ticker struct {
 	Price float64
 }
 
+type BidAsk struct {
+	Bid float64
+	Ask float64
+}
+
+type Candle struct {
+	Start time.Time
+	Open  float64
+	High  float64
+	Low   float64
+	Close float64
+}
+
+const (
+	FillBuy  = "BUY"
+	FillSell = "SELL"
+)
+
+// Fill is a single trade execution. Size is in the base currency, Price and
+// Commission are in the quote currency.
+type Fill struct {
+	TradeID       string
+	OrderID       string
+	ProductID     string
+	BaseCurrency  string
+	QuoteCurrency string
+	Side          string
+	Time          time.Time
+	Price         decimal.Decimal
+	Size          decimal.Decimal
+	Commission    decimal.Decimal
+}
+
 type Product struct {
 	QuoteCurrency string
 	BaseCurrency  string
@@ -58,3 +146,25 @@ type Account struct {
 type PendingTransfer struct {
 	Amount float64
 }
+
+// FeeRates are fractions of the order value, e.g. 0.006 for 0.6%.
+type FeeRates struct {
+	Tier  string
+	Maker float64
+	Taker float64
+}
+
+type OrderPreview struct {
+	QuoteSize  float64
+	BaseSize   float64
+	Total      float64 // including commission
+	Commission float64
+	Errors     []string
+	Warnings   []string
+}
+
+type PaymentMethod struct {
+	ID   string
+	Name string
+	Type string
+}
@@ -1,6 +1,7 @@
 package exchanges
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
@@ -164,7 +165,7 @@ func (f *Ftx) GetFiatAccount(currency string) (*Account, error) {
 	return nil, fmt.Errorf("Cannot find %s account", currency)
 }
 
-func (f *Ftx) GetPendingTransfers(currency string) ([]PendingTransfer, error) {
+func (f *Ftx) GetPendingTransfers(ctx context.Context, currency string) ([]PendingTransfer, error) {
 	return []PendingTransfer{}, nil
 }
 
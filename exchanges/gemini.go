@@ -1,10 +1,14 @@
 package exchanges
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -41,11 +45,16 @@ func NewGemini() (*Gemini, error) {
 	}, nil
 }
 
+// SetTransport sends the requests to Gemini through base, e.g. a transport.Recorder replaying fixtures.
+func (g *Gemini) SetTransport(base http.RoundTripper) {
+	g.client.SetTransport(base)
+}
+
 func (g *Gemini) GetTickerSymbol(baseCurrency string, quoteCurrency string) string {
 	return baseCurrency + quoteCurrency
 }
 
-func (g *Gemini) GetTicker(productId string) (*Ticker, error) {
+func (g *Gemini) GetTicker(ctx context.Context, productId string) (*Ticker, error) {
 	ticker, err := g.client.TickerV2(productId)
 	if err != nil {
 		return nil, err
@@ -134,6 +143,55 @@ func (g *Gemini) LastPurchaseTime(ticker string, currency string, since time.Tim
 	return &lastTransactionTime, nil
 }
 
+// GetFills returns fills of a single product, Gemini has no history across products.
+func (g *Gemini) GetFills(ctx context.Context, productId string, start time.Time, end time.Time) ([]Fill, error) {
+	if productId == "" {
+		return nil, errors.New("gemini exchange requires a product to list fills")
+	}
+
+	symbol, err := g.client.SymbolDetails(productId)
+	if err != nil {
+		return nil, err
+	}
+
+	args := gemini.Args{}
+	// without a timestamp the trades are listed from the start of the account
+	if !start.IsZero() {
+		args["timestamp"] = start
+	}
+
+	trades, err := g.client.PastTrades(productId, args)
+	if err != nil {
+		return nil, err
+	}
+
+	fills := []Fill{}
+	for _, t := range trades {
+		if !end.IsZero() && !t.TimestampmsT.Before(end) {
+			continue
+		}
+
+		if t.FeeAmount != 0 && !strings.EqualFold(t.FeeCurrency, symbol.QuoteCurrency) {
+			return nil, fmt.Errorf("unsupported fee currency %s in trade %d", t.FeeCurrency, t.TradeId)
+		}
+
+		fills = append(fills, Fill{
+			TradeID:       strconv.FormatInt(t.TradeId, 10),
+			OrderID:       t.OrderId,
+			ProductID:     productId,
+			BaseCurrency:  strings.ToUpper(symbol.BaseCurrency),
+			QuoteCurrency: strings.ToUpper(symbol.QuoteCurrency),
+			Side:          strings.ToUpper(t.Type),
+			Time:          t.TimestampmsT,
+			Price:         decimal.NewFromFloat(t.Price),
+			Size:          decimal.NewFromFloat(t.Amount),
+			Commission:    decimal.NewFromFloat(t.FeeAmount),
+		})
+	}
+
+	return fills, nil
+}
+
 func (g *Gemini) GetFiatAccount(currency string) (*Account, error) {
 	balances, err := g.client.Balances()
 	if err != nil {
@@ -157,7 +215,7 @@ func (g *Gemini) GetFiatAccount(currency string) (*Account, error) {
 }
 
 //this is not something gemini can profide
-func (g *Gemini) GetPendingTransfers(currency string) ([]PendingTransfer, error) {
+func (g *Gemini) GetPendingTransfers(ctx context.Context, currency string) ([]PendingTransfer, error) {
 	return []PendingTransfer{}, nil
 }
 
@@ -1,8 +1,14 @@
 package exchanges
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
 	"testing"
+	"time"
 
+	"github.com/sberserker/dcagdax/clients/transport"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,3 +21,51 @@ func TestDecimalPrecision(t *testing.T) {
 	assert.Equal(t, 2, int(decimalPrecision(0.08)))
 	assert.Equal(t, 0, int(decimalPrecision(2)))
 }
+
+func TestGeminiGetFillsReplay(t *testing.T) {
+	recorder, err := transport.NewRecorder("testdata/gemini_fills.json", transport.RecorderConfig{})
+	assert.Nil(t, err)
+
+	t.Setenv("GEMINI_KEY", "account-key")
+	t.Setenv("GEMINI_SECRET", "account-secret")
+	g, err := NewGemini()
+	assert.Nil(t, err)
+	// the payload of a request is signed and redacted from the fixture, it is checked before replaying
+	var payloads []map[string]interface{}
+	g.SetTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if raw, err := base64.StdEncoding.DecodeString(r.Header.Get("X-GEMINI-PAYLOAD")); err == nil && len(raw) > 0 {
+			var payload map[string]interface{}
+			assert.Nil(t, json.Unmarshal(raw, &payload))
+			payloads = append(payloads, payload)
+		}
+		return recorder.RoundTrip(r)
+	}))
+
+	fills, err := g.GetFills(context.Background(), "btcusd", time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC), time.Time{})
+	assert.Nil(t, err)
+	if assert.Len(t, fills, 2) {
+		assert.Equal(t, "109823746521", fills[0].TradeID)
+		assert.Equal(t, "BTC", fills[0].BaseCurrency)
+		assert.Equal(t, "USD", fills[0].QuoteCurrency)
+		assert.Equal(t, "BUY", fills[0].Side)
+		assert.Equal(t, "0.0796", fills[0].Commission.String())
+	}
+	if assert.Len(t, payloads, 1) {
+		assert.Equal(t, float64(time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC).UnixMilli()), payloads[0]["timestamp"])
+	}
+
+	// without a start all trades are listed
+	fills, err = g.GetFills(context.Background(), "btcusd", time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Len(t, fills, 2)
+	if assert.Len(t, payloads, 2) {
+		assert.Equal(t, float64(0), payloads[1]["timestamp"])
+	}
+	assert.Empty(t, recorder.Unused())
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
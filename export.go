@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sberserker/dcagdax/exchanges"
+)
+
+// exportRequest selects the fills exported by `dcagdax export lots`.
+type exportRequest struct {
+	exchange string
+	currency string
+	assets   []string
+	from     time.Time
+	to       time.Time // exclusive
+	format   string
+}
+
+// exportLots fetches the fills history and writes the tax lots to w.
+func exportLots(ctx context.Context, history exchanges.FillHistory, req exportRequest, w io.Writer) error {
+	if !validLotFormat(req.format) {
+		return fmt.Errorf("unsupported lot format %s, use one of %s", req.format, strings.Join(lotFormats, ", "))
+	}
+
+	// without assets the whole history is fetched and filtered by currency
+	productIds := []string{""}
+	if len(req.assets) > 0 {
+		productIds = productIds[:0]
+		for _, a := range req.assets {
+			productIds = append(productIds, history.GetTickerSymbol(strings.ToUpper(a), req.currency))
+		}
+	}
+
+	fills := []exchanges.Fill{}
+	for _, productId := range productIds {
+		f, err := history.GetFills(ctx, productId, req.from, req.to)
+		if err != nil {
+			return err
+		}
+		fills = append(fills, f...)
+	}
+
+	lots := lotsFromFills(req.exchange, fills, req.from, req.to, req.assets, req.currency)
+
+	return writeLots(w, req.format, lots)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sberserker/dcagdax/exchanges"
+	"github.com/sberserker/dcagdax/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportLots(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	m := mocks.NewMockFillHistory(ctrl)
+
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2023, 1, 10, 12, 30, 0, 0, time.UTC)
+
+	t.Run("when assets are given", func(t *testing.T) {
+		req := exportRequest{exchange: "coinbase", currency: "USD", assets: []string{"eth", "btc"}, from: from, to: to, format: lotFormatGeneric}
+
+		m.EXPECT().GetTickerSymbol("ETH", "USD").Return("ETH-USD")
+		m.EXPECT().GetTickerSymbol("BTC", "USD").Return("BTC-USD")
+		m.EXPECT().GetFills(ctx, "ETH-USD", from, to).Return([]exchanges.Fill{testFill("2", exchanges.FillBuy, "ETH", at, "1500", "0.01", "0.1")}, nil)
+		m.EXPECT().GetFills(ctx, "BTC-USD", from, to).Return([]exchanges.Fill{testFill("1", exchanges.FillBuy, "BTC", at, "17000", "0.001", "0.1")}, nil)
+
+		var buf bytes.Buffer
+		err := exportLots(ctx, m, req, &buf)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "acquired,asset,quantity,price,fee,cost,currency,exchange,trade_id,order_id\n"+
+			"2023-01-10T12:30:00Z,BTC,0.001,17000,0.1,17.1,USD,coinbase,1,order-1\n"+
+			"2023-01-10T12:30:00Z,ETH,0.01,1500,0.1,15.1,USD,coinbase,2,order-2\n", buf.String())
+	})
+
+	t.Run("when all products are exported", func(t *testing.T) {
+		req := exportRequest{exchange: "coinbase", currency: "USD", format: lotFormatGeneric}
+
+		m.EXPECT().GetFills(ctx, "", time.Time{}, time.Time{}).Return(nil, errors.New("boom"))
+
+		err := exportLots(ctx, m, req, &bytes.Buffer{})
+
+		assert.EqualError(t, err, "boom")
+	})
+
+	t.Run("when format is unsupported", func(t *testing.T) {
+		err := exportLots(ctx, m, exportRequest{format: "turbotax"}, &bytes.Buffer{})
+
+		assert.EqualError(t, err, "unsupported lot format turbotax, use one of generic, 8949, koinly, cointracker")
+	})
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sberserker/dcagdax/exchanges"
+	"github.com/shopspring/decimal"
+)
+
+// fillDelay is how long placed orders get to fill before their fees are
+// looked up.
+const fillDelay = 5 * time.Second
+
+// feeRate is the fee expected for the orders of a run.
+type feeRate struct {
+	tier string  // fee tier of the account, empty with --fee
+	rate float64 // fraction of the order value
+}
+
+// loadFees fetches the fee rate for the orders of the current run: the maker
+// rate of the account's fee tier for limit orders and the taker rate for
+// market orders. --fee is used when it is given explicitly or when the
+// exchange has no fee endpoint.
+func (s *gdaxSchedule) loadFees(ctx context.Context) feeRate {
+	if s.fees != nil {
+		return *s.fees
+	}
+
+	fees := feeRate{rate: s.req.fee / 100}
+	s.fees = &fees
+
+	p, ok := s.exchange.(exchanges.Previewer)
+	if !ok || s.req.feeOverride {
+		return fees
+	}
+
+	rates, err := p.GetFeeRates(ctx)
+	if err != nil {
+		s.logger.Warnw(
+			"Fee tier unavailable, using --fee",
+			"fee", s.req.fee,
+			"error", err,
+		)
+		return fees
+	}
+
+	fees.tier = rates.Tier
+	fees.rate = rates.Taker
+	if s.req.orderType == exchanges.Limit {
+		fees.rate = rates.Maker
+	}
+
+	s.logger.Infow(
+		"Fee tier",
+		"tier", fees.tier,
+		"rate", fees.rate,
+	)
+
+	return fees
+}
+
+// feeFraction is the fee rate of the current run, --fee before it was loaded.
+func (s *gdaxSchedule) feeFraction() decimal.Decimal {
+	if s.fees != nil {
+		return decimal.NewFromFloat(s.fees.rate)
+	}
+	return decimal.NewFromFloat(s.req.fee / 100)
+}
+
+// expectedFee is the fee of an order for amount. Limit orders exclude the
+// fee from the amount, market orders pay it out of the amount.
+func (s *gdaxSchedule) expectedFee(amount float64) float64 {
+	rate := s.feeFraction()
+	value := decimal.NewFromFloat(amount)
+	if s.req.orderType == exchanges.Limit {
+		value = value.Mul(decimal.NewFromInt(1).Sub(rate))
+	}
+
+	fee, _ := value.Mul(rate).Round(2).Float64()
+	return fee
+}
+
+// reportFees looks up the fills of the orders placed in r and compares the
+// fees paid with the expected ones. Orders which are not filled yet are left
+// without a fee.
+func (s *gdaxSchedule) reportFees(ctx context.Context, r *runResult) {
+	h, ok := s.exchange.(exchanges.FillHistory)
+	if !ok {
+		return
+	}
+
+	placed := map[string][]int{}
+	for i, o := range r.Orders {
+		if o.Status == orderPlaced {
+			placed[o.ProductID] = append(placed[o.ProductID], i)
+		}
+	}
+	if len(placed) == 0 {
+		return
+	}
+
+	if s.sleepFunc(ctx, fillDelay) != nil {
+		return
+	}
+
+	for productId, orders := range placed {
+		fills, err := h.GetFills(ctx, productId, r.Start, time.Time{})
+		if err != nil {
+			s.logger.Warnw(
+				"Fills unavailable, fees are not reported",
+				"productId", productId,
+				"error", err,
+			)
+			continue
+		}
+
+		for _, i := range orders {
+			o := &r.Orders[i]
+
+			filled := false
+			paid := decimal.Zero
+			for _, f := range fills {
+				if f.OrderID == o.OrderID {
+					filled = true
+					paid = paid.Add(f.Commission)
+				}
+			}
+
+			if !filled {
+				s.logger.Infow(
+					"Order not filled yet, fee unknown",
+					"orderId", o.OrderID,
+					"expectedFee", o.ExpectedFee,
+				)
+				continue
+			}
+
+			fee, _ := paid.Float64()
+			o.Fee = &fee
+			feesPaid.Add(fee, s.profile, o.Coin, s.req.currency)
+
+			s.logger.Infow(
+				"Order fees",
+				"orderId", o.OrderID,
+				"expected", o.ExpectedFee,
+				"actual", fee,
+				"difference", paid.Sub(decimal.NewFromFloat(o.ExpectedFee)).Round(2).String(),
+			)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sberserker/dcagdax/exchanges"
+	"github.com/sberserker/dcagdax/mocks"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// feeExchange reports fee tiers and fills. GetFills is forwarded explicitly
+// since both Exchange and FillHistory define GetTickerSymbol.
+type feeExchange struct {
+	*mocks.MockExchange
+	*mocks.MockPreviewer
+	fills *mocks.MockFillHistory
+}
+
+func (e feeExchange) GetFills(ctx context.Context, productId string, start time.Time, end time.Time) ([]exchanges.Fill, error) {
+	return e.fills.GetFills(ctx, productId, start, end)
+}
+
+func TestLoadFees(t *testing.T) {
+	rates := &exchanges.FeeRates{Tier: "Advanced 1", Maker: 0.004, Taker: 0.006}
+
+	type test struct {
+		name      string
+		previewer bool
+		override  bool
+		orderType exchanges.OrderTypeType
+		err       error
+		fees      feeRate
+	}
+
+	tests := []test{
+		{name: "no fee endpoint", orderType: exchanges.Market, fees: feeRate{rate: 0.005}},
+		{name: "market order", previewer: true, orderType: exchanges.Market, fees: feeRate{tier: "Advanced 1", rate: 0.006}},
+		{name: "limit order", previewer: true, orderType: exchanges.Limit, fees: feeRate{tier: "Advanced 1", rate: 0.004}},
+		{name: "override", previewer: true, override: true, orderType: exchanges.Limit, fees: feeRate{rate: 0.005}},
+		{name: "fee tier unavailable", previewer: true, orderType: exchanges.Market, err: errors.New("unauthorized"), fees: feeRate{rate: 0.005}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			ctx := context.Background()
+
+			m := mocks.NewMockExchange(ctrl)
+			p := mocks.NewMockPreviewer(ctrl)
+
+			s := gdaxSchedule{}
+			s.logger = loggerStub(t).Sugar()
+			s.req = syncRequest{orderType: tc.orderType, fee: 0.5, feeOverride: tc.override}
+			s.exchange = m
+
+			if tc.previewer {
+				s.exchange = previewExchange{m, p}
+				if !tc.override {
+					p.EXPECT().GetFeeRates(ctx).Return(rates, tc.err).Times(1)
+				}
+			}
+
+			assert.Equal(t, tc.fees, s.loadFees(ctx))
+			// loaded once per run
+			assert.Equal(t, tc.fees, s.loadFees(ctx))
+		})
+	}
+}
+
+func TestCalcLimitOrderWithFeeTier(t *testing.T) {
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{fee: 1, orderSpread: 1, orderType: exchanges.Limit}
+	s.fees = &feeRate{tier: "Advanced 1", rate: 0.004}
+
+	orderPrice, orderSize := s.calcLimitOrder(decimal.NewFromFloat(1), decimal.NewFromFloat(10))
+
+	assert.Equal(t, "1.01", orderPrice.String())
+	assert.Equal(t, "9.86138613", orderSize.String())
+	assert.Equal(t, 0.04, s.expectedFee(10))
+}
+
+func TestSyncReportsFees(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	m := mocks.NewMockExchange(ctrl)
+	p := mocks.NewMockPreviewer(ctrl)
+	h := mocks.NewMockFillHistory(ctrl)
+
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, currency: "USD", usd: 150, fee: 0.5}
+	s.coins = map[string]orderDetails{
+		"BTC": {symbol: "BTC-USD", amount: 100},
+		"ETH": {symbol: "ETH-USD", amount: 50},
+	}
+	s.markerCoin = "BTC"
+	s.exchange = feeExchange{m, p, h}
+
+	var slept time.Duration
+	s.sleepFunc = func(ctx context.Context, d time.Duration) error {
+		slept += d
+		return nil
+	}
+
+	m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(nil, nil)
+	m.EXPECT().GetFiatAccount(ctx, "USD").Return(&exchanges.Account{Available: 200}, nil)
+	p.EXPECT().GetFeeRates(ctx).Return(&exchanges.FeeRates{Tier: "Advanced 1", Maker: 0.004, Taker: 0.006}, nil)
+	m.EXPECT().CreateOrder(ctx, "BTC-USD", 100.0, exchanges.Market, gomock.Any()).Return(&exchanges.Order{OrderID: "1"}, nil)
+	m.EXPECT().CreateOrder(ctx, "ETH-USD", 50.0, exchanges.Market, gomock.Any()).Return(&exchanges.Order{OrderID: "2"}, nil)
+	h.EXPECT().GetFills(ctx, "BTC-USD", gomock.Any(), time.Time{}).Return([]exchanges.Fill{
+		{OrderID: "1", Commission: decimal.NewFromFloat(0.3)},
+		{OrderID: "1", Commission: decimal.NewFromFloat(0.31)},
+		{OrderID: "0", Commission: decimal.NewFromFloat(0.5)},
+	}, nil)
+	h.EXPECT().GetFills(ctx, "ETH-USD", gomock.Any(), time.Time{}).Return([]exchanges.Fill{}, nil)
+
+	r, err := s.Sync()
+
+	paid := 0.61
+	assert.Nil(t, err)
+	assert.Equal(t, statusSucceeded, r.Status)
+	assert.Equal(t, []orderOutcome{
+		{Coin: "BTC", ProductID: "BTC-USD", Amount: 100, Status: orderPlaced, OrderID: "1", ExpectedFee: 0.6, Fee: &paid},
+		{Coin: "ETH", ProductID: "ETH-USD", Amount: 50, Status: orderPlaced, OrderID: "2", ExpectedFee: 0.3},
+	}, r.Orders)
+	assert.Equal(t, fillDelay, slept)
+}
@@ -5,12 +5,12 @@ go 1.21.5
 require (
 	github.com/claudiocandio/gemini-api v1.0.1
 	github.com/coinbase-samples/advanced-trade-sdk-go v0.3.1
+	github.com/coinbase-samples/core-go v0.2.0
 	github.com/golang/mock v1.6.0
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.3
 	github.com/grishinsana/goftx v1.2.2-0.20210726052311-b7369763f91c
 	github.com/imroc/req/v3 v3.42.2
-	github.com/jarcoal/httpmock v1.3.1
 	github.com/mitchellh/mapstructure v1.5.0
 	github.com/shopspring/decimal v1.4.0
 	github.com/sirupsen/logrus v1.8.1
@@ -28,7 +28,6 @@ require (
 	github.com/alecthomas/units v0.0.0-20151022065526-2efee857e7cf // indirect
 	github.com/andybalholm/brotli v1.0.5 // indirect
 	github.com/cloudflare/circl v1.3.3 // indirect
-	github.com/coinbase-samples/core-go v0.2.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/gaukas/godicttls v0.0.4 // indirect
 	github.com/go-task/slim-sprig v0.0.0-20230315185526-52ccab3ef572 // indirect
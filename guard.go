@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/sberserker/dcagdax/exchanges"
+)
+
+const (
+	guardSourceNone    = "none"
+	guardSourceSpot    = "spot"
+	guardSourceCandles = "candles"
+)
+
+var (
+	errPriceDeviation   = errors.New("price deviates from reference")
+	errSpreadTooWide    = errors.New("bid/ask spread is too wide")
+	errNoMarketData     = errors.New("exchange does not provide market data required by the price guard")
+	errNoReferencePrice = errors.New("no reference price available")
+)
+
+// priceGuard cross-checks the exchange price against a second source before
+// an order is placed. Thresholds are percentages, zero disables the check.
+type priceGuard struct {
+	source    string // none, spot, candles or an exchange name
+	deviation float64
+	spread    float64
+	candles   int
+	reference exchanges.PriceSource // second exchange used when source is an exchange name
+}
+
+// candleCache is implemented by exchanges which can keep candles on disk.
+type candleCache interface {
+	UseCandleCache(dir string)
+}
+
+// useCandleCache caches the candles of exchange in dir when it is set.
+func useCandleCache(exchange exchanges.Exchange, dir string) {
+	if c, ok := exchange.(candleCache); ok && dir != "" {
+		c.UseCandleCache(dir)
+	}
+}
+
+func (g priceGuard) enabled() bool {
+	return g.deviation > 0 || g.spread > 0
+}
+
+func (g priceGuard) validate(exchange exchanges.Exchange) error {
+	if g.deviation > 0 && (g.source == "" || g.source == guardSourceNone) {
+		return errors.New("--guard-deviation requires --guard-source")
+	}
+
+	_, hasMarketData := exchange.(exchanges.MarketData)
+
+	if g.spread > 0 && !hasMarketData {
+		return errNoMarketData
+	}
+
+	if g.deviation == 0 {
+		return nil
+	}
+
+	switch g.source {
+	case guardSourceSpot:
+		if !hasMarketData {
+			return errNoMarketData
+		}
+	case guardSourceCandles:
+		if !hasMarketData {
+			return errNoMarketData
+		}
+		if g.candles <= 0 {
+			return errors.New("--guard-candles must be positive")
+		}
+	default:
+		if g.reference == nil {
+			return fmt.Errorf("reference exchange %s is not configured", g.source)
+		}
+	}
+
+	return nil
+}
+
+// checkPrice refuses to trade productId when the bid/ask spread or the
+// deviation from the reference price exceeds the configured thresholds.
+func (s *gdaxSchedule) checkPrice(ctx context.Context, coin string, productId string) error {
+	g := s.req.guard
+	if !g.enabled() {
+		return nil
+	}
+
+	if g.spread > 0 {
+		spread, err := s.spreadPercent(ctx, productId)
+		if err != nil {
+			return err
+		}
+
+		if spread > g.spread {
+			return fmt.Errorf("%w: %s spread %.3f%% exceeds %.3f%%", errSpreadTooWide, productId, spread, g.spread)
+		}
+	}
+
+	if g.deviation > 0 {
+		ticker, err := s.exchange.GetTicker(ctx, productId)
+		if err != nil {
+			return err
+		}
+
+		reference, err := s.referencePrice(ctx, coin, productId)
+		if err != nil {
+			return err
+		}
+
+		deviation := math.Abs(ticker.Price-reference) / reference * 100
+
+		s.logger.Infow(
+			"Price guard",
+			"productId", productId,
+			"price", ticker.Price,
+			"reference", reference,
+			"source", g.source,
+			"deviation", deviation,
+		)
+
+		if deviation > g.deviation {
+			return fmt.Errorf(
+				"%w: %s price %.2f is %.3f%% away from %s reference %.2f, limit %.3f%%",
+				errPriceDeviation, productId, ticker.Price, deviation, g.source, reference, g.deviation,
+			)
+		}
+	}
+
+	return nil
+}
+
+func (s *gdaxSchedule) spreadPercent(ctx context.Context, productId string) (float64, error) {
+	marketData, ok := s.exchange.(exchanges.MarketData)
+	if !ok {
+		return 0, errNoMarketData
+	}
+
+	book, err := marketData.GetBestBidAsk(ctx, productId)
+	if err != nil {
+		return 0, err
+	}
+
+	mid := (book.Ask + book.Bid) / 2
+	if mid <= 0 || book.Bid <= 0 || book.Ask < book.Bid {
+		return 0, fmt.Errorf("%w: %s bid %.2f ask %.2f", errSpreadTooWide, productId, book.Bid, book.Ask)
+	}
+
+	return (book.Ask - book.Bid) / mid * 100, nil
+}
+
+func (s *gdaxSchedule) referencePrice(ctx context.Context, coin string, productId string) (float64, error) {
+	g := s.req.guard
+
+	var price float64
+
+	switch g.source {
+	case guardSourceSpot, guardSourceCandles:
+		marketData, ok := s.exchange.(exchanges.MarketData)
+		if !ok {
+			return 0, errNoMarketData
+		}
+
+		if g.source == guardSourceSpot {
+			ticker, err := marketData.GetSpotPrice(ctx, productId)
+			if err != nil {
+				return 0, err
+			}
+			price = ticker.Price
+			break
+		}
+
+		candles, err := marketData.GetCandles(ctx, productId, g.candles)
+		if err != nil {
+			return 0, err
+		}
+		if len(candles) == 0 {
+			return 0, fmt.Errorf("%w: no candles for %s", errNoReferencePrice, productId)
+		}
+
+		total := 0.0
+		for _, c := range candles {
+			total += c.Close
+		}
+		price = total / float64(len(candles))
+	default:
+		symbol := g.reference.GetTickerSymbol(coin, s.req.currency)
+		ticker, err := g.reference.GetTicker(ctx, symbol)
+		if err != nil {
+			return 0, err
+		}
+		price = ticker.Price
+	}
+
+	if price <= 0 {
+		return 0, fmt.Errorf("%w: %s returned %.2f for %s", errNoReferencePrice, g.source, price, productId)
+	}
+
+	return price, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sberserker/dcagdax/exchanges"
+	"github.com/sberserker/dcagdax/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+// marketDataExchange is an exchange which also implements exchanges.MarketData.
+type marketDataExchange struct {
+	*mocks.MockExchange
+	*mocks.MockMarketData
+}
+
+func TestPriceGuardValidate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockExchange(ctrl)
+	md := marketDataExchange{m, mocks.NewMockMarketData(ctrl)}
+
+	type test struct {
+		guard    priceGuard
+		exchange exchanges.Exchange
+		err      string
+		message  string
+	}
+
+	tests := []test{
+		{guard: priceGuard{}, exchange: m, err: "", message: "disabled guard"},
+		{guard: priceGuard{source: guardSourceNone, deviation: 1}, exchange: md, err: "--guard-deviation requires --guard-source", message: "deviation without source"},
+		{guard: priceGuard{spread: 1}, exchange: m, err: errNoMarketData.Error(), message: "spread without market data"},
+		{guard: priceGuard{source: guardSourceSpot, deviation: 1}, exchange: m, err: errNoMarketData.Error(), message: "spot without market data"},
+		{guard: priceGuard{source: guardSourceCandles, deviation: 1}, exchange: md, err: "--guard-candles must be positive", message: "no candles"},
+		{guard: priceGuard{source: "gemini", deviation: 1}, exchange: m, err: "reference exchange gemini is not configured", message: "missing reference"},
+		{guard: priceGuard{source: "gemini", deviation: 1, reference: m}, exchange: m, err: "", message: "reference exchange"},
+	}
+
+	for _, tc := range tests {
+		err := tc.guard.validate(tc.exchange)
+		if tc.err == "" {
+			assert.Nil(t, err, tc.message)
+		} else {
+			assert.EqualError(t, err, tc.err, tc.message)
+		}
+	}
+}
+
+func TestCheckPrice(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	m := mocks.NewMockExchange(ctrl)
+	md := mocks.NewMockMarketData(ctrl)
+
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.exchange = marketDataExchange{m, md}
+
+	t.Run("when spread is too wide", func(t *testing.T) {
+		s.req = syncRequest{currency: "USD", guard: priceGuard{spread: 0.5}}
+		md.EXPECT().GetBestBidAsk(ctx, "BTC-USD").Return(&exchanges.BidAsk{Bid: 99, Ask: 101}, nil)
+
+		err := s.checkPrice(ctx, "BTC", "BTC-USD")
+
+		assert.True(t, errors.Is(err, errSpreadTooWide))
+	})
+
+	t.Run("when spread is fine", func(t *testing.T) {
+		s.req = syncRequest{currency: "USD", guard: priceGuard{spread: 0.5}}
+		md.EXPECT().GetBestBidAsk(ctx, "BTC-USD").Return(&exchanges.BidAsk{Bid: 100, Ask: 100.1}, nil)
+
+		err := s.checkPrice(ctx, "BTC", "BTC-USD")
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("when price deviates from spot", func(t *testing.T) {
+		s.req = syncRequest{currency: "USD", guard: priceGuard{source: guardSourceSpot, deviation: 2}}
+		m.EXPECT().GetTicker(ctx, "BTC-USD").Return(&exchanges.Ticker{Price: 90}, nil)
+		md.EXPECT().GetSpotPrice(ctx, "BTC-USD").Return(&exchanges.Ticker{Price: 100}, nil)
+
+		err := s.checkPrice(ctx, "BTC", "BTC-USD")
+
+		assert.True(t, errors.Is(err, errPriceDeviation))
+	})
+
+	t.Run("when price is close to candles average", func(t *testing.T) {
+		s.req = syncRequest{currency: "USD", guard: priceGuard{source: guardSourceCandles, deviation: 2, candles: 3}}
+		m.EXPECT().GetTicker(ctx, "BTC-USD").Return(&exchanges.Ticker{Price: 101}, nil)
+		md.EXPECT().GetCandles(ctx, "BTC-USD", 3).Return([]exchanges.Candle{{Close: 99}, {Close: 100}, {Close: 101}}, nil)
+
+		err := s.checkPrice(ctx, "BTC", "BTC-USD")
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("when reference exchange disagrees", func(t *testing.T) {
+		reference := mocks.NewMockExchange(ctrl)
+		s.req = syncRequest{currency: "USD", guard: priceGuard{source: "gemini", deviation: 1, reference: reference}}
+		m.EXPECT().GetTicker(ctx, "BTC-USD").Return(&exchanges.Ticker{Price: 120}, nil)
+		reference.EXPECT().GetTickerSymbol("BTC", "USD").Return("BTCUSD")
+		reference.EXPECT().GetTicker(ctx, "BTCUSD").Return(&exchanges.Ticker{Price: 100}, nil)
+
+		err := s.checkPrice(ctx, "BTC", "BTC-USD")
+
+		assert.True(t, errors.Is(err, errPriceDeviation))
+	})
+
+	t.Run("when reference is unavailable", func(t *testing.T) {
+		s.req = syncRequest{currency: "USD", guard: priceGuard{source: guardSourceSpot, deviation: 1}}
+		m.EXPECT().GetTicker(ctx, "BTC-USD").Return(&exchanges.Ticker{Price: 100}, nil)
+		md.EXPECT().GetSpotPrice(ctx, "BTC-USD").Return(&exchanges.Ticker{Price: 0}, nil)
+
+		err := s.checkPrice(ctx, "BTC", "BTC-USD")
+
+		assert.True(t, errors.Is(err, errNoReferencePrice))
+	})
+}
+
+func TestSyncSkipsOrderWhenPriceGuardRejects(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	m := mocks.NewMockExchange(ctrl)
+	md := mocks.NewMockMarketData(ctrl)
+
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, currency: "USD", usd: 50, guard: priceGuard{spread: 0.5}}
+	s.coins = map[string]orderDetails{"BTC": {symbol: "BTC-USD", amount: 50}}
+	s.markerCoin = "BTC"
+	s.sleepFunc = func(ctx context.Context, d time.Duration) error { return nil }
+	s.exchange = marketDataExchange{m, md}
+
+	m.EXPECT().LastPurchaseTime(ctx, "BTC", "USD", gomock.Any()).Return(nil, nil)
+	m.EXPECT().GetFiatAccount(ctx, "USD").Return(&exchanges.Account{Available: 50}, nil)
+	md.EXPECT().GetBestBidAsk(ctx, "BTC-USD").Return(&exchanges.BidAsk{Bid: 50, Ask: 150}, nil)
+	m.EXPECT().CreateOrder(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	_, err := s.Sync()
+
+	assert.Nil(t, err)
+}
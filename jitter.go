@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var hoursRange = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?-(\d{1,2})(?::(\d{2}))?$`)
+
+// tradingHours restricts orders to a time of day range. The range wraps
+// around midnight when start is after end. The zero value allows any time.
+type tradingHours struct {
+	spec  string
+	start int // minutes since midnight, inclusive
+	end   int // minutes since midnight, exclusive
+	loc   *time.Location
+}
+
+// parseHours parses "HH[:MM]-HH[:MM] [time zone]", e.g. "14:00-21:00 America/New_York".
+func parseHours(value string) (tradingHours, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return tradingHours{}, nil
+	}
+	if len(fields) > 2 {
+		return tradingHours{}, fmt.Errorf("--hours misformatted: %s", value)
+	}
+
+	m := hoursRange.FindStringSubmatch(fields[0])
+	if m == nil {
+		return tradingHours{}, fmt.Errorf("--hours misformatted: %s", value)
+	}
+
+	start, ok := minutesOfDay(m[1], m[2])
+	if !ok {
+		return tradingHours{}, fmt.Errorf("--hours misformatted: %s", value)
+	}
+
+	end, ok := minutesOfDay(m[3], m[4])
+	if !ok {
+		return tradingHours{}, fmt.Errorf("--hours misformatted: %s", value)
+	}
+
+	if start == end {
+		return tradingHours{}, fmt.Errorf("--hours range is empty: %s", value)
+	}
+
+	loc := time.Local
+	if len(fields) == 2 {
+		l, err := time.LoadLocation(fields[1])
+		if err != nil {
+			return tradingHours{}, err
+		}
+		loc = l
+	}
+
+	return tradingHours{spec: value, start: start, end: end, loc: loc}, nil
+}
+
+func minutesOfDay(hour string, minute string) (int, bool) {
+	h, _ := strconv.Atoi(hour)
+	m := 0
+	if minute != "" {
+		m, _ = strconv.Atoi(minute)
+	}
+
+	// 24:00 closes a range at midnight
+	if h == 24 && m == 0 {
+		return 24 * 60, true
+	}
+	if h > 23 || m > 59 {
+		return 0, false
+	}
+
+	return h*60 + m, true
+}
+
+func (h tradingHours) enabled() bool {
+	return h.loc != nil
+}
+
+func (h tradingHours) minute(t time.Time) int {
+	t = t.In(h.loc)
+	return t.Hour()*60 + t.Minute()
+}
+
+// contains reports whether orders are allowed at t.
+func (h tradingHours) contains(t time.Time) bool {
+	if !h.enabled() {
+		return true
+	}
+
+	m := h.minute(t)
+	if h.start < h.end {
+		return m >= h.start && m < h.end
+	}
+	return m >= h.start || m < h.end
+}
+
+// remaining returns how long orders are still allowed after t, zero when
+// outside of the range or when any time is allowed.
+func (h tradingHours) remaining(t time.Time) time.Duration {
+	if !h.enabled() || !h.contains(t) {
+		return 0
+	}
+
+	left := h.end - h.minute(t)
+	if left <= 0 {
+		left += 24 * 60
+	}
+
+	return time.Duration(left)*time.Minute - time.Duration(t.Second())*time.Second
+}
+
+func (h tradingHours) String() string {
+	return h.spec
+}
+
+// randomDelay returns a uniformly distributed delay in [0, max].
+func randomDelay(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max) + 1))
+}
+
+// checkHours skips the run with errOutsideHours when orders are not allowed at
+// now.
+func (s *gdaxSchedule) checkHours(now time.Time) error {
+	if !s.req.hours.contains(now) {
+		return fmt.Errorf("%w: allowed hours %s", errOutsideHours, s.req.hours)
+	}
+	return nil
+}
+
+func (s *gdaxSchedule) now() time.Time {
+	if s.nowFunc != nil {
+		return s.nowFunc()
+	}
+	return time.Now()
+}
+
+// waitJitter sleeps a random delay of up to --jitter so orders are not placed
+// at the same predictable minute. The delay never runs past the allowed hours.
+// It returns the error of ctx when ctx is done before the delay is over.
+func (s *gdaxSchedule) waitJitter(ctx context.Context, now time.Time) error {
+	if s.req.jitter <= 0 {
+		return nil
+	}
+
+	max := s.req.jitter
+	if left := s.req.hours.remaining(now); left > 0 && left < max {
+		max = left
+	}
+
+	delay := s.jitterFunc(max)
+
+	s.logger.Infow(
+		"Delaying orders",
+		"minutes", delay.Minutes(),
+	)
+
+	if s.dryRun() {
+		s.logger.Infow("Delay skipped for dry run")
+		return nil
+	}
+
+	return s.sleepFunc(ctx, delay)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHours(t *testing.T) {
+	type test struct {
+		value string
+		start int
+		end   int
+		loc   string
+		err   string
+	}
+
+	tests := []test{
+		{value: "14:00-21:00 America/New_York", start: 14 * 60, end: 21 * 60, loc: "America/New_York"},
+		{value: "9-17:30 UTC", start: 9 * 60, end: 17*60 + 30, loc: "UTC"},
+		{value: "22:00-24:00 UTC", start: 22 * 60, end: 24 * 60, loc: "UTC"},
+		{value: "14-14 UTC", err: "--hours range is empty: 14-14 UTC"},
+		{value: "25:00-26:00", err: "--hours misformatted: 25:00-26:00"},
+		{value: "14:00", err: "--hours misformatted: 14:00"},
+		{value: "14-21 UTC extra", err: "--hours misformatted: 14-21 UTC extra"},
+		{value: "14-21 Mars/Base", err: "unknown time zone Mars/Base"},
+	}
+
+	for _, tc := range tests {
+		hours, err := parseHours(tc.value)
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, tc.start, hours.start)
+		assert.Equal(t, tc.end, hours.end)
+		assert.Equal(t, tc.loc, hours.loc.String())
+	}
+
+	hours, err := parseHours("")
+	assert.Nil(t, err)
+	assert.False(t, hours.enabled())
+}
+
+func TestTradingHours(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 3, 6, hour, minute, 0, 0, time.UTC)
+	}
+
+	t.Run("when disabled", func(t *testing.T) {
+		hours := tradingHours{}
+
+		assert.True(t, hours.contains(at(3, 0)))
+		assert.Equal(t, time.Duration(0), hours.remaining(at(3, 0)))
+	})
+
+	t.Run("when within a day", func(t *testing.T) {
+		hours, _ := parseHours("14:00-21:00 America/New_York")
+
+		// eastern standard time is UTC-5
+		assert.False(t, hours.contains(at(18, 59)))
+		assert.True(t, hours.contains(at(19, 0)))
+		assert.True(t, hours.contains(at(23, 30)))
+		assert.False(t, hours.contains(at(2, 0)))
+		assert.Equal(t, 30*time.Minute, hours.remaining(at(1, 30)))
+		assert.Equal(t, time.Duration(0), hours.remaining(at(2, 0)))
+	})
+
+	t.Run("when wrapping midnight", func(t *testing.T) {
+		hours, _ := parseHours("22:00-02:00 UTC")
+
+		assert.True(t, hours.contains(at(23, 0)))
+		assert.True(t, hours.contains(at(1, 0)))
+		assert.False(t, hours.contains(at(2, 0)))
+		assert.Equal(t, 3*time.Hour, hours.remaining(at(23, 0)))
+		assert.Equal(t, time.Hour, hours.remaining(at(1, 0)))
+	})
+}
+
+func TestRandomDelay(t *testing.T) {
+	assert.Equal(t, time.Duration(0), randomDelay(0))
+
+	for i := 0; i < 100; i++ {
+		d := randomDelay(time.Minute)
+		assert.True(t, d >= 0 && d <= time.Minute)
+	}
+}
+
+func TestWaitJitterIsCappedByHours(t *testing.T) {
+	hours, _ := parseHours("14:00-15:00 UTC")
+
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{jitter: time.Hour, hours: hours}
+
+	var max, slept time.Duration
+	s.jitterFunc = func(d time.Duration) time.Duration {
+		max = d
+		return d
+	}
+	s.sleepFunc = func(ctx context.Context, d time.Duration) error {
+		slept = d
+		return nil
+	}
+
+	err := s.waitJitter(context.Background(), time.Date(2024, 3, 6, 14, 50, 0, 0, time.UTC))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 10*time.Minute, max)
+	assert.Equal(t, 10*time.Minute, slept)
+
+	t.Run("when debug is on", func(t *testing.T) {
+		slept = 0
+		s.debug = true
+
+		err := s.waitJitter(context.Background(), time.Date(2024, 3, 6, 14, 0, 0, 0, time.UTC))
+
+		assert.Nil(t, err)
+		assert.Equal(t, time.Hour, max)
+		assert.Equal(t, time.Duration(0), slept)
+	})
+}
+
+func TestWaitJitterIsCancelled(t *testing.T) {
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{jitter: time.Hour}
+	s.jitterFunc = func(d time.Duration) time.Duration { return d }
+	s.sleepFunc = sleep
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := s.waitJitter(ctx, start)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), time.Minute)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+const (
+	ledgerPurchase = "purchase"
+	ledgerSkipped  = "skipped"
+)
+
+// ledgerEntry is a single line of the ledger file.
+type ledgerEntry struct {
+	Time    time.Time `json:"time"`
+	Coin    string    `json:"coin"`
+	Kind    string    `json:"kind"`
+	Amount  float64   `json:"amount"`
+	Price   float64   `json:"price,omitempty"`
+	OrderID string    `json:"order_id,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Carry   float64   `json:"carry"`             // amount carried forward into the next window after this entry
+	Windows int       `json:"windows,omitempty"` // purchase windows covered by this entry, 1 when empty
+}
+
+func (e ledgerEntry) windows() int {
+	if e.Windows <= 0 {
+		return 1
+	}
+	return e.Windows
+}
+
+// ledger is an append only JSON lines file recording purchase windows.
+// A nil ledger records nothing.
+type ledger struct {
+	path string
+}
+
+func newLedger(path string) *ledger {
+	if path == "" {
+		return nil
+	}
+	return &ledger{path: path}
+}
+
+func (l *ledger) record(e ledgerEntry) error {
+	if l == nil {
+		return nil
+	}
+
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(e)
+}
+
+func (l *ledger) entries() ([]ledgerEntry, error) {
+	if l == nil {
+		return nil, nil
+	}
+
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []ledgerEntry{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var e ledgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, scanner.Err()
+}
+
+// last returns the most recent entry for coin or nil if there is none.
+func (l *ledger) last(coin string) (*ledgerEntry, error) {
+	entries, err := l.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Coin == coin {
+			return &entries[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// carry returns the amount carried forward for coin.
+func (l *ledger) carry(coin string) (float64, error) {
+	e, err := l.last(coin)
+	if err != nil || e == nil {
+		return 0, err
+	}
+	return e.Carry, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLedger(t *testing.T) {
+	l := newLedger(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	now := time.Now().UTC().Truncate(time.Second)
+
+	t.Run("when empty", func(t *testing.T) {
+		entries, err := l.entries()
+
+		assert.Nil(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("when recorded", func(t *testing.T) {
+		assert.Nil(t, l.record(ledgerEntry{Time: now, Coin: "BTC", Kind: ledgerSkipped, Amount: 25, Carry: 25}))
+		assert.Nil(t, l.record(ledgerEntry{Time: now, Coin: "ETH", Kind: ledgerPurchase, Amount: 10, OrderID: "1"}))
+
+		entries, err := l.entries()
+		assert.Nil(t, err)
+		assert.Len(t, entries, 2)
+		assert.Equal(t, now, entries[0].Time)
+
+		last, err := l.last("BTC")
+		assert.Nil(t, err)
+		assert.Equal(t, ledgerSkipped, last.Kind)
+
+		carry, err := l.carry("BTC")
+		assert.Nil(t, err)
+		assert.Equal(t, 25.0, carry)
+
+		carry, err = l.carry("LTC")
+		assert.Nil(t, err)
+		assert.Equal(t, 0.0, carry)
+	})
+}
+
+func TestNilLedger(t *testing.T) {
+	var l *ledger = newLedger("")
+
+	assert.Nil(t, l)
+	assert.Nil(t, l.record(ledgerEntry{Coin: "BTC"}))
+
+	last, err := l.last("BTC")
+	assert.Nil(t, err)
+	assert.Nil(t, last)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sberserker/dcagdax/exchanges"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	lotFormatGeneric     = "generic"
+	lotFormat8949        = "8949"
+	lotFormatKoinly      = "koinly"
+	lotFormatCoinTracker = "cointracker"
+)
+
+var lotFormats = []string{lotFormatGeneric, lotFormat8949, lotFormatKoinly, lotFormatCoinTracker}
+
+// taxLot is a single acquisition. Cost is the fiat paid including the fee.
+type taxLot struct {
+	Acquired time.Time
+	Asset    string
+	Currency string
+	Quantity decimal.Decimal
+	Price    decimal.Decimal
+	Fee      decimal.Decimal
+	Cost     decimal.Decimal
+	Exchange string
+	TradeID  string
+	OrderID  string
+}
+
+// lotsFromFills turns buy fills into tax lots sorted by acquisition time.
+// Fills outside of [from, to) or of other assets and currencies are left out.
+func lotsFromFills(exchange string, fills []exchanges.Fill, from time.Time, to time.Time, assets []string, currency string) []taxLot {
+	wanted := map[string]bool{}
+	for _, a := range assets {
+		wanted[strings.ToUpper(a)] = true
+	}
+
+	lots := []taxLot{}
+	for _, f := range fills {
+		if f.Side != exchanges.FillBuy {
+			continue
+		}
+		if !from.IsZero() && f.Time.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !f.Time.Before(to) {
+			continue
+		}
+		if len(wanted) > 0 && !wanted[f.BaseCurrency] {
+			continue
+		}
+		if currency != "" && f.QuoteCurrency != currency {
+			continue
+		}
+
+		lots = append(lots, taxLot{
+			Acquired: f.Time.UTC(),
+			Asset:    f.BaseCurrency,
+			Currency: f.QuoteCurrency,
+			Quantity: f.Size,
+			Price:    f.Price,
+			Fee:      f.Commission,
+			Cost:     f.Price.Mul(f.Size).Add(f.Commission),
+			Exchange: exchange,
+			TradeID:  f.TradeID,
+			OrderID:  f.OrderID,
+		})
+	}
+
+	// fills of one order share the time, the trade id keeps the order stable
+	sort.SliceStable(lots, func(i, j int) bool {
+		if !lots[i].Acquired.Equal(lots[j].Acquired) {
+			return lots[i].Acquired.Before(lots[j].Acquired)
+		}
+		if lots[i].Asset != lots[j].Asset {
+			return lots[i].Asset < lots[j].Asset
+		}
+		return lots[i].TradeID < lots[j].TradeID
+	})
+
+	return lots
+}
+
+// openLots returns the part of lots which is not sold yet. The sell fills of
+// the asset and currency of a lot dispose of the lots acquired before them
+// first in, first out, the cost and fee of a partially sold lot shrink with its
+// quantity. lots are sorted by acquisition time.
+func openLots(lots []taxLot, fills []exchanges.Fill) []taxLot {
+	sells := []exchanges.Fill{}
+	for _, f := range fills {
+		if f.Side == exchanges.FillSell {
+			sells = append(sells, f)
+		}
+	}
+	sort.SliceStable(sells, func(i, j int) bool {
+		return sells[i].Time.Before(sells[j].Time)
+	})
+
+	open := append([]taxLot{}, lots...)
+	for _, f := range sells {
+		sold := f.Size
+		for i := range open {
+			l := &open[i]
+			if sold.IsZero() || l.Acquired.After(f.Time) {
+				break
+			}
+			if l.Asset != f.BaseCurrency || l.Currency != f.QuoteCurrency || l.Quantity.IsZero() {
+				continue
+			}
+
+			disposed := decimal.Min(sold, l.Quantity)
+			left := l.Quantity.Sub(disposed)
+			l.Cost = l.Cost.Mul(left).Div(l.Quantity)
+			l.Fee = l.Fee.Mul(left).Div(l.Quantity)
+			l.Quantity = left
+			sold = sold.Sub(disposed)
+		}
+	}
+
+	remaining := open[:0]
+	for _, l := range open {
+		if !l.Quantity.IsZero() {
+			remaining = append(remaining, l)
+		}
+	}
+	return remaining
+}
+
+func validLotFormat(format string) bool {
+	for _, f := range lotFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// writeLots writes lots as CSV in one of the lotFormats.
+func writeLots(w io.Writer, format string, lots []taxLot) error {
+	var header []string
+	var row func(l taxLot) []string
+
+	switch format {
+	case lotFormatGeneric:
+		header = []string{"acquired", "asset", "quantity", "price", "fee", "cost", "currency", "exchange", "trade_id", "order_id"}
+		row = func(l taxLot) []string {
+			return []string{
+				l.Acquired.Format(time.RFC3339),
+				l.Asset,
+				l.Quantity.String(),
+				l.Price.String(),
+				l.Fee.String(),
+				l.Cost.String(),
+				l.Currency,
+				l.Exchange,
+				l.TradeID,
+				l.OrderID,
+			}
+		}
+	case lotFormat8949:
+		// lots are not disposed of yet, sale columns are left for the tax tool
+		header = []string{"Description of property", "Date acquired", "Date sold or disposed of", "Proceeds", "Cost or other basis", "Code", "Amount of adjustment", "Gain or (loss)"}
+		row = func(l taxLot) []string {
+			return []string{
+				fmt.Sprintf("%s %s", l.Quantity.String(), l.Asset),
+				l.Acquired.Format("01/02/2006"),
+				"",
+				"",
+				l.Cost.StringFixed(2),
+				"",
+				"",
+				"",
+			}
+		}
+	case lotFormatKoinly:
+		header = []string{"Date", "Sent Amount", "Sent Currency", "Received Amount", "Received Currency", "Fee Amount", "Fee Currency", "Net Worth Amount", "Net Worth Currency", "Label", "Description", "TxHash"}
+		row = func(l taxLot) []string {
+			return []string{
+				l.Acquired.Format("2006-01-02 15:04:05 UTC"),
+				l.Price.Mul(l.Quantity).String(),
+				l.Currency,
+				l.Quantity.String(),
+				l.Asset,
+				l.Fee.String(),
+				l.Currency,
+				"",
+				"",
+				"",
+				fmt.Sprintf("%s order %s", l.Exchange, l.OrderID),
+				l.TradeID,
+			}
+		}
+	case lotFormatCoinTracker:
+		header = []string{"Date", "Received Quantity", "Received Currency", "Sent Quantity", "Sent Currency", "Fee Amount", "Fee Currency", "Tag"}
+		row = func(l taxLot) []string {
+			return []string{
+				l.Acquired.Format("01/02/2006 15:04:05"),
+				l.Quantity.String(),
+				l.Asset,
+				l.Price.Mul(l.Quantity).String(),
+				l.Currency,
+				l.Fee.String(),
+				l.Currency,
+				"",
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported lot format %s, use one of %s", format, strings.Join(lotFormats, ", "))
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, l := range lots {
+		if err := cw.Write(row(l)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/sberserker/dcagdax/exchanges"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func testFill(id string, side string, asset string, at time.Time, price string, size string, commission string) exchanges.Fill {
+	return exchanges.Fill{
+		TradeID:       id,
+		OrderID:       "order-" + id,
+		ProductID:     asset + "-USD",
+		BaseCurrency:  asset,
+		QuoteCurrency: "USD",
+		Side:          side,
+		Time:          at,
+		Price:         decimal.RequireFromString(price),
+		Size:          decimal.RequireFromString(size),
+		Commission:    decimal.RequireFromString(commission),
+	}
+}
+
+func TestLotsFromFills(t *testing.T) {
+	jan := time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+	feb := time.Date(2023, 2, 10, 12, 0, 0, 0, time.UTC)
+
+	fills := []exchanges.Fill{
+		testFill("3", exchanges.FillBuy, "BTC", feb, "20000", "0.0025", "0.3"),
+		testFill("2", exchanges.FillBuy, "ETH", jan, "1500", "0.01", "0.1"),
+		testFill("1", exchanges.FillBuy, "BTC", jan, "17000", "0.001", "0.1"),
+		testFill("4", exchanges.FillSell, "BTC", feb, "21000", "0.001", "0.1"),
+	}
+
+	t.Run("when unfiltered", func(t *testing.T) {
+		lots := lotsFromFills("coinbase", fills, time.Time{}, time.Time{}, nil, "USD")
+
+		assert.Equal(t, 3, len(lots))
+		assert.Equal(t, []string{"1", "2", "3"}, []string{lots[0].TradeID, lots[1].TradeID, lots[2].TradeID})
+		assert.Equal(t, "17.1", lots[0].Cost.String())
+		assert.Equal(t, "50.3", lots[2].Cost.String())
+	})
+
+	t.Run("when filtered by date and asset", func(t *testing.T) {
+		lots := lotsFromFills("coinbase", fills, jan, feb, []string{"btc"}, "USD")
+
+		assert.Equal(t, 1, len(lots))
+		assert.Equal(t, "1", lots[0].TradeID)
+	})
+
+	t.Run("when filtered by currency", func(t *testing.T) {
+		lots := lotsFromFills("coinbase", fills, time.Time{}, time.Time{}, nil, "EUR")
+
+		assert.Equal(t, 0, len(lots))
+	})
+}
+
+func TestOpenLots(t *testing.T) {
+	jan := time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	lots := lotsFromFills("coinbase", []exchanges.Fill{
+		testFill("1", exchanges.FillBuy, "BTC", jan, "100", "1", "1"),
+		testFill("2", exchanges.FillBuy, "BTC", jan.Add(time.Hour), "200", "2", "2"),
+		testFill("3", exchanges.FillBuy, "BTC", jan.Add(3*time.Hour), "300", "1", "1"),
+		testFill("4", exchanges.FillBuy, "ETH", jan, "10", "1", "0"),
+	}, time.Time{}, time.Time{}, nil, "USD")
+
+	open := openLots(lots, []exchanges.Fill{
+		// sells the first lot and half of the second, the third lot is bought afterwards
+		testFill("5", exchanges.FillSell, "BTC", jan.Add(2*time.Hour), "250", "2", "1"),
+		// more than held before it, the later lot is left untouched
+		testFill("6", exchanges.FillSell, "BTC", jan.Add(2*time.Hour), "250", "5", "1"),
+	})
+
+	assert.Equal(t, 2, len(open))
+	assert.Equal(t, "4", open[0].TradeID)
+	assert.Equal(t, "3", open[1].TradeID)
+	assert.Equal(t, "1", open[1].Quantity.String())
+	assert.Equal(t, "301", open[1].Cost.String())
+
+	open = openLots(lots, []exchanges.Fill{
+		testFill("5", exchanges.FillSell, "BTC", jan.Add(2*time.Hour), "250", "2", "1"),
+	})
+
+	assert.Equal(t, 3, len(open))
+	assert.Equal(t, "2", open[1].TradeID)
+	assert.Equal(t, "1", open[1].Quantity.String())
+	assert.Equal(t, "201", open[1].Cost.String())
+	assert.Equal(t, "1", open[1].Fee.String())
+	// the lots passed in are left unchanged
+	assert.Equal(t, "2", lots[2].Quantity.String())
+}
+
+func TestWriteLots(t *testing.T) {
+	at := time.Date(2023, 1, 10, 12, 30, 0, 0, time.UTC)
+	lots := lotsFromFills("coinbase", []exchanges.Fill{testFill("1", exchanges.FillBuy, "BTC", at, "17000", "0.001", "0.1")}, time.Time{}, time.Time{}, nil, "USD")
+
+	type test struct {
+		format string
+		csv    string
+	}
+
+	tests := []test{
+		{
+			format: lotFormatGeneric,
+			csv: "acquired,asset,quantity,price,fee,cost,currency,exchange,trade_id,order_id\n" +
+				"2023-01-10T12:30:00Z,BTC,0.001,17000,0.1,17.1,USD,coinbase,1,order-1\n",
+		},
+		{
+			format: lotFormat8949,
+			csv: "Description of property,Date acquired,Date sold or disposed of,Proceeds,Cost or other basis,Code,Amount of adjustment,Gain or (loss)\n" +
+				"0.001 BTC,01/10/2023,,,17.10,,,\n",
+		},
+		{
+			format: lotFormatKoinly,
+			csv: "Date,Sent Amount,Sent Currency,Received Amount,Received Currency,Fee Amount,Fee Currency,Net Worth Amount,Net Worth Currency,Label,Description,TxHash\n" +
+				"2023-01-10 12:30:00 UTC,17,USD,0.001,BTC,0.1,USD,,,,coinbase order order-1,1\n",
+		},
+		{
+			format: lotFormatCoinTracker,
+			csv: "Date,Received Quantity,Received Currency,Sent Quantity,Sent Currency,Fee Amount,Fee Currency,Tag\n" +
+				"01/10/2023 12:30:00,0.001,BTC,17,USD,0.1,USD,\n",
+		},
+	}
+
+	for _, tc := range tests {
+		var buf bytes.Buffer
+
+		err := writeLots(&buf, tc.format, lots)
+
+		assert.Nil(t, err)
+		assert.Equal(t, tc.csv, buf.String())
+	}
+
+	err := writeLots(&bytes.Buffer{}, "turbotax", lots)
+	assert.EqualError(t, err, "unsupported lot format turbotax, use one of generic, 8949, koinly, cointracker")
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"time"
+
+	"github.com/sberserker/dcagdax/metrics"
+)
+
+var (
+	runsAttempted = metrics.Default.NewCounter(
+		"dcagdax_runs_total",
+		"Purchase runs attempted.",
+	)
+
+	runsSucceeded = metrics.Default.NewCounter(
+		"dcagdax_runs_succeeded_total",
+		"Purchase runs which completed.",
+	)
+
+	runsSkipped = metrics.Default.NewCounter(
+		"dcagdax_runs_skipped_total",
+		"Purchase runs skipped by reason.",
+		"reason",
+	)
+
+	runsFailed = metrics.Default.NewCounter(
+		"dcagdax_runs_failed_total",
+		"Purchase runs which failed with an error.",
+	)
+
+	ordersPlaced = metrics.Default.NewCounter(
+		"dcagdax_orders_placed_total",
+		"Orders placed per profile and coin.",
+		"profile", "coin",
+	)
+
+	ordersFailed = metrics.Default.NewCounter(
+		"dcagdax_orders_failed_total",
+		"Orders which failed or were rejected by the price guard per profile and coin.",
+		"profile", "coin",
+	)
+
+	fiatSpent = metrics.Default.NewCounter(
+		"dcagdax_fiat_spent_total",
+		"Fiat amount of placed orders per profile and coin.",
+		"profile", "coin", "currency",
+	)
+
+	feesPaid = metrics.Default.NewCounter(
+		"dcagdax_fees_paid_total",
+		"Fees paid for filled orders per profile and coin.",
+		"profile", "coin", "currency",
+	)
+
+	depositsInitiated = metrics.Default.NewCounter(
+		"dcagdax_deposits_total",
+		"Deposits initiated per profile.",
+		"profile", "currency",
+	)
+
+	fiatDeposited = metrics.Default.NewCounter(
+		"dcagdax_fiat_deposited_total",
+		"Fiat amount of initiated deposits per profile.",
+		"profile", "currency",
+	)
+
+	pausedGauge = metrics.Default.NewGauge(
+		"dcagdax_paused",
+		"1 while purchases of the profile are paused.",
+		"profile",
+	)
+
+	nextRunTimestamp = metrics.Default.NewGauge(
+		"dcagdax_next_run_timestamp_seconds",
+		"Unix time of the next scheduled run of the profile.",
+		"profile",
+	)
+)
+
+func init() {
+	metrics.Default.NewGaugeFunc(
+		"dcagdax_next_run_seconds",
+		"Seconds until the next scheduled run of any profile.",
+		func() float64 {
+			next := nextRunTimestamp.Min()
+			if next == 0 {
+				return 0
+			}
+			return next - float64(time.Now().Unix())
+		},
+	)
+}
+
+// recordRun counts the outcome of a Sync run.
+func recordRun(r *runResult) {
+	runsAttempted.Inc()
+
+	switch r.Status {
+	case statusSkipped:
+		runsSkipped.Inc(r.Reason)
+	case statusPaused:
+		runsSkipped.Inc(skipPaused)
+	case statusFailed:
+		runsFailed.Inc()
+	default:
+		runsSucceeded.Inc()
+	}
+}
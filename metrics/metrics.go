@@ -0,0 +1,298 @@
+// Package metrics is a minimal registry of counters, gauges and histograms
+// exposed in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// DefBuckets are latency buckets in seconds suited for exchange API calls.
+var DefBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Default is the registry the application and exchange clients record to.
+var Default = NewRegistry()
+
+var (
+	exchangeRequestDuration = Default.NewHistogram(
+		"dcagdax_exchange_request_duration_seconds",
+		"Latency of exchange API requests.",
+		DefBuckets,
+		"exchange", "endpoint",
+	)
+
+	exchangeRequestErrors = Default.NewCounter(
+		"dcagdax_exchange_request_errors_total",
+		"Failed exchange API requests.",
+		"exchange", "endpoint",
+	)
+)
+
+// ObserveRequest records the latency and outcome of an exchange API request
+// started at start.
+func ObserveRequest(exchange string, endpoint string, start time.Time, err error) {
+	exchangeRequestDuration.Observe(time.Since(start).Seconds(), exchange, endpoint)
+	if err != nil {
+		exchangeRequestErrors.Inc(exchange, endpoint)
+	}
+}
+
+// Registry holds metric families in registration order.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+	names    map[string]bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+type family struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	fn      func() float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	value  float64
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func (r *Registry) register(f *family) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[f.name] {
+		panic(fmt.Sprintf("metrics: %s registered twice", f.name))
+	}
+	r.names[f.name] = true
+
+	f.series = map[string]*series{}
+	r.families = append(r.families, f)
+
+	return f
+}
+
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: append([]string{}, values...)}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+
+	return s
+}
+
+func (f *family) value(values []string) float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.get(values).value
+}
+
+// Counter only goes up.
+type Counter struct{ f *family }
+
+func (r *Registry) NewCounter(name string, help string, labels ...string) *Counter {
+	return &Counter{r.register(&family{name: name, help: help, kind: kindCounter, labels: labels})}
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: %s cannot decrease", c.f.name))
+	}
+
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.f.get(labelValues).value += v
+}
+
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.f.value(labelValues)
+}
+
+// Gauge can be set to any value.
+type Gauge struct{ f *family }
+
+func (r *Registry) NewGauge(name string, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(&family{name: name, help: help, kind: kindGauge, labels: labels})}
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	g.f.get(labelValues).value = v
+}
+
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.f.value(labelValues)
+}
+
+// Min returns the smallest value of the series set to a non-zero value, zero
+// when there is none.
+func (g *Gauge) Min() float64 {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+
+	min := 0.0
+	for _, s := range g.f.series {
+		if s.value != 0 && (min == 0 || s.value < min) {
+			min = s.value
+		}
+	}
+	return min
+}
+
+// NewGaugeFunc registers a gauge without labels evaluated on every scrape.
+func (r *Registry) NewGaugeFunc(name string, help string, fn func() float64) {
+	r.register(&family{name: name, help: help, kind: kindGauge, fn: fn})
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct{ f *family }
+
+func (r *Registry) NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{r.register(&family{name: name, help: help, kind: kindHistogram, labels: labels, buckets: buckets})}
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+
+	s := h.f.get(labelValues)
+	for i, b := range h.f.buckets {
+		if v <= b {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+	return h.f.get(labelValues).count
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	families := append([]*family{}, r.families...)
+	r.mu.Unlock()
+
+	var sb strings.Builder
+	for _, f := range families {
+		f.write(&sb)
+	}
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+func (f *family) write(sb *strings.Builder) {
+	fmt.Fprintf(sb, "# HELP %s %s\n", f.name, f.help)
+	fmt.Fprintf(sb, "# TYPE %s %s\n", f.name, f.kind)
+
+	if f.fn != nil {
+		fmt.Fprintf(sb, "%s %s\n", f.name, formatValue(f.fn()))
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := f.series[k]
+		if f.kind != kindHistogram {
+			fmt.Fprintf(sb, "%s%s %s\n", f.name, labelPairs(f.labels, s.values, "", ""), formatValue(s.value))
+			continue
+		}
+
+		cumulative := uint64(0)
+		for i, b := range f.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(sb, "%s_bucket%s %d\n", f.name, labelPairs(f.labels, s.values, "le", formatValue(b)), cumulative)
+		}
+		fmt.Fprintf(sb, "%s_bucket%s %d\n", f.name, labelPairs(f.labels, s.values, "le", "+Inf"), s.count)
+		fmt.Fprintf(sb, "%s_sum%s %s\n", f.name, labelPairs(f.labels, s.values, "", ""), formatValue(s.sum))
+		fmt.Fprintf(sb, "%s_count%s %d\n", f.name, labelPairs(f.labels, s.values, "", ""), s.count)
+	}
+}
+
+func labelPairs(labels []string, values []string, extraLabel string, extraValue string) string {
+	pairs := []string{}
+	for i, l := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", l, values[i]))
+	}
+	if extraLabel != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", extraLabel, extraValue))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Handler serves the registry for Prometheus scrapes.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
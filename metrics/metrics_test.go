@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryWriteTo(t *testing.T) {
+	r := NewRegistry()
+
+	runs := r.NewCounter("runs_total", "Runs.", "result")
+	next := r.NewGauge("next_run", "Next run.")
+	latency := r.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1}, "endpoint")
+	r.NewGaugeFunc("answer", "Answer.", func() float64 { return 42 })
+
+	runs.Inc("skipped")
+	runs.Add(2, "ok")
+	next.Set(1.5)
+	latency.Observe(0.05, "/orders")
+	latency.Observe(0.5, "/orders")
+	latency.Observe(5, "/orders")
+
+	var buf bytes.Buffer
+	_, err := r.WriteTo(&buf)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `# HELP runs_total Runs.
+# TYPE runs_total counter
+runs_total{result="ok"} 2
+runs_total{result="skipped"} 1
+# HELP next_run Next run.
+# TYPE next_run gauge
+next_run 1.5
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{endpoint="/orders",le="0.1"} 1
+latency_seconds_bucket{endpoint="/orders",le="1"} 2
+latency_seconds_bucket{endpoint="/orders",le="+Inf"} 3
+latency_seconds_sum{endpoint="/orders"} 5.55
+latency_seconds_count{endpoint="/orders"} 3
+# HELP answer Answer.
+# TYPE answer gauge
+answer 42
+`, buf.String())
+
+	assert.Equal(t, 2.0, runs.Value("ok"))
+	assert.Equal(t, uint64(3), latency.Count("/orders"))
+}
+
+func TestGaugeMin(t *testing.T) {
+	r := NewRegistry()
+	next := r.NewGauge("next_run", "Next run.", "profile")
+
+	assert.Equal(t, 0.0, next.Min())
+
+	next.Set(20, "alice")
+	next.Set(10, "bob")
+	next.Set(0, "carol")
+
+	assert.Equal(t, 10.0, next.Min())
+	assert.Equal(t, 20.0, next.Value("alice"))
+}
+
+func TestRegistryPanics(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("runs_total", "Runs.", "result")
+
+	assert.Panics(t, func() { r.NewGauge("runs_total", "Runs.") })
+	assert.Panics(t, func() { c.Inc() })
+	assert.Panics(t, func() { c.Add(-1, "ok") })
+}
+
+func TestObserveRequest(t *testing.T) {
+	start := time.Now()
+
+	ObserveRequest("test", "/ok", start, nil)
+	ObserveRequest("test", "/fail", start, errors.New("boom"))
+
+	assert.Equal(t, uint64(1), exchangeRequestDuration.Count("test", "/ok"))
+	assert.Equal(t, 0.0, exchangeRequestErrors.Value("test", "/ok"))
+	assert.Equal(t, 1.0, exchangeRequestErrors.Value("test", "/fail"))
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("runs_total", "Runs.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, 200, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+	assert.Contains(t, rec.Body.String(), "runs_total 1\n")
+}
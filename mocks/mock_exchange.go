@@ -1,11 +1,11 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/sberserker/dcagdax/exchanges (interfaces: Exchange)
+// Source: github.com/sberserker/dcagdax/exchanges (interfaces: Exchange,MarketData,FillHistory,Previewer)
 
 // Package mocks is a generated GoMock package.
 package mocks
 
 import (
-	"context"
+	context "context"
 	reflect "reflect"
 	time "time"
 
@@ -48,7 +48,7 @@ func (m *MockExchange) CreateOrder(arg0 context.Context, arg1 string, arg2 float
 // CreateOrder indicates an expected call of CreateOrder.
 func (mr *MockExchangeMockRecorder) CreateOrder(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrder", reflect.TypeOf((*MockExchange)(nil).CreateOrder), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrder", reflect.TypeOf((*MockExchange)(nil).CreateOrder), arg0, arg1, arg2, arg3, arg4)
 }
 
 // Deposit mocks base method.
@@ -63,7 +63,7 @@ func (m *MockExchange) Deposit(arg0 context.Context, arg1 string, arg2 float64)
 // Deposit indicates an expected call of Deposit.
 func (mr *MockExchangeMockRecorder) Deposit(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deposit", reflect.TypeOf((*MockExchange)(nil).Deposit), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deposit", reflect.TypeOf((*MockExchange)(nil).Deposit), arg0, arg1, arg2)
 }
 
 // GetFiatAccount mocks base method.
@@ -76,24 +76,24 @@ func (m *MockExchange) GetFiatAccount(arg0 context.Context, arg1 string) (*excha
 }
 
 // GetFiatAccount indicates an expected call of GetFiatAccount.
-func (mr *MockExchangeMockRecorder) GetFiatAccount(arg0 context.Context, arg1 interface{}) *gomock.Call {
+func (mr *MockExchangeMockRecorder) GetFiatAccount(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFiatAccount", reflect.TypeOf((*MockExchange)(nil).GetFiatAccount), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFiatAccount", reflect.TypeOf((*MockExchange)(nil).GetFiatAccount), arg0, arg1)
 }
 
 // GetPendingTransfers mocks base method.
-func (m *MockExchange) GetPendingTransfers(arg0 string) ([]exchanges.PendingTransfer, error) {
+func (m *MockExchange) GetPendingTransfers(arg0 context.Context, arg1 string) ([]exchanges.PendingTransfer, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPendingTransfers", arg0)
+	ret := m.ctrl.Call(m, "GetPendingTransfers", arg0, arg1)
 	ret0, _ := ret[0].([]exchanges.PendingTransfer)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPendingTransfers indicates an expected call of GetPendingTransfers.
-func (mr *MockExchangeMockRecorder) GetPendingTransfers(arg0 interface{}) *gomock.Call {
+func (mr *MockExchangeMockRecorder) GetPendingTransfers(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingTransfers", reflect.TypeOf((*MockExchange)(nil).GetPendingTransfers), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingTransfers", reflect.TypeOf((*MockExchange)(nil).GetPendingTransfers), arg0, arg1)
 }
 
 // GetProduct mocks base method.
@@ -106,9 +106,9 @@ func (m *MockExchange) GetProduct(arg0 context.Context, arg1 string) (*exchanges
 }
 
 // GetProduct indicates an expected call of GetProduct.
-func (mr *MockExchangeMockRecorder) GetProduct(arg0 interface{}) *gomock.Call {
+func (mr *MockExchangeMockRecorder) GetProduct(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProduct", reflect.TypeOf((*MockExchange)(nil).GetProduct), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProduct", reflect.TypeOf((*MockExchange)(nil).GetProduct), arg0, arg1)
 }
 
 // GetTicker mocks base method.
@@ -121,9 +121,9 @@ func (m *MockExchange) GetTicker(arg0 context.Context, arg1 string) (*exchanges.
 }
 
 // GetTicker indicates an expected call of GetTicker.
-func (mr *MockExchangeMockRecorder) GetTicker(arg0 interface{}) *gomock.Call {
+func (mr *MockExchangeMockRecorder) GetTicker(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTicker", reflect.TypeOf((*MockExchange)(nil).GetTicker), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTicker", reflect.TypeOf((*MockExchange)(nil).GetTicker), arg0, arg1)
 }
 
 // GetTickerSymbol mocks base method.
@@ -150,7 +150,195 @@ func (m *MockExchange) LastPurchaseTime(arg0 context.Context, arg1, arg2 string,
 }
 
 // LastPurchaseTime indicates an expected call of LastPurchaseTime.
-func (mr *MockExchangeMockRecorder) LastPurchaseTime(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockExchangeMockRecorder) LastPurchaseTime(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastPurchaseTime", reflect.TypeOf((*MockExchange)(nil).LastPurchaseTime), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastPurchaseTime", reflect.TypeOf((*MockExchange)(nil).LastPurchaseTime), arg0, arg1, arg2, arg3)
+}
+
+// MockMarketData is a mock of MarketData interface.
+type MockMarketData struct {
+	ctrl     *gomock.Controller
+	recorder *MockMarketDataMockRecorder
+}
+
+// MockMarketDataMockRecorder is the mock recorder for MockMarketData.
+type MockMarketDataMockRecorder struct {
+	mock *MockMarketData
+}
+
+// NewMockMarketData creates a new mock instance.
+func NewMockMarketData(ctrl *gomock.Controller) *MockMarketData {
+	mock := &MockMarketData{ctrl: ctrl}
+	mock.recorder = &MockMarketDataMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMarketData) EXPECT() *MockMarketDataMockRecorder {
+	return m.recorder
+}
+
+// GetBestBidAsk mocks base method.
+func (m *MockMarketData) GetBestBidAsk(arg0 context.Context, arg1 string) (*exchanges.BidAsk, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBestBidAsk", arg0, arg1)
+	ret0, _ := ret[0].(*exchanges.BidAsk)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBestBidAsk indicates an expected call of GetBestBidAsk.
+func (mr *MockMarketDataMockRecorder) GetBestBidAsk(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBestBidAsk", reflect.TypeOf((*MockMarketData)(nil).GetBestBidAsk), arg0, arg1)
+}
+
+// GetCandles mocks base method.
+func (m *MockMarketData) GetCandles(arg0 context.Context, arg1 string, arg2 int) ([]exchanges.Candle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCandles", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]exchanges.Candle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCandles indicates an expected call of GetCandles.
+func (mr *MockMarketDataMockRecorder) GetCandles(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCandles", reflect.TypeOf((*MockMarketData)(nil).GetCandles), arg0, arg1, arg2)
+}
+
+// GetSpotPrice mocks base method.
+func (m *MockMarketData) GetSpotPrice(arg0 context.Context, arg1 string) (*exchanges.Ticker, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSpotPrice", arg0, arg1)
+	ret0, _ := ret[0].(*exchanges.Ticker)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSpotPrice indicates an expected call of GetSpotPrice.
+func (mr *MockMarketDataMockRecorder) GetSpotPrice(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSpotPrice", reflect.TypeOf((*MockMarketData)(nil).GetSpotPrice), arg0, arg1)
+}
+
+// MockFillHistory is a mock of FillHistory interface.
+type MockFillHistory struct {
+	ctrl     *gomock.Controller
+	recorder *MockFillHistoryMockRecorder
+}
+
+// MockFillHistoryMockRecorder is the mock recorder for MockFillHistory.
+type MockFillHistoryMockRecorder struct {
+	mock *MockFillHistory
+}
+
+// NewMockFillHistory creates a new mock instance.
+func NewMockFillHistory(ctrl *gomock.Controller) *MockFillHistory {
+	mock := &MockFillHistory{ctrl: ctrl}
+	mock.recorder = &MockFillHistoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFillHistory) EXPECT() *MockFillHistoryMockRecorder {
+	return m.recorder
+}
+
+// GetFills mocks base method.
+func (m *MockFillHistory) GetFills(arg0 context.Context, arg1 string, arg2, arg3 time.Time) ([]exchanges.Fill, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFills", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]exchanges.Fill)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFills indicates an expected call of GetFills.
+func (mr *MockFillHistoryMockRecorder) GetFills(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFills", reflect.TypeOf((*MockFillHistory)(nil).GetFills), arg0, arg1, arg2, arg3)
+}
+
+// GetTickerSymbol mocks base method.
+func (m *MockFillHistory) GetTickerSymbol(arg0, arg1 string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTickerSymbol", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetTickerSymbol indicates an expected call of GetTickerSymbol.
+func (mr *MockFillHistoryMockRecorder) GetTickerSymbol(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTickerSymbol", reflect.TypeOf((*MockFillHistory)(nil).GetTickerSymbol), arg0, arg1)
+}
+
+// MockPreviewer is a mock of Previewer interface.
+type MockPreviewer struct {
+	ctrl     *gomock.Controller
+	recorder *MockPreviewerMockRecorder
+}
+
+// MockPreviewerMockRecorder is the mock recorder for MockPreviewer.
+type MockPreviewerMockRecorder struct {
+	mock *MockPreviewer
+}
+
+// NewMockPreviewer creates a new mock instance.
+func NewMockPreviewer(ctrl *gomock.Controller) *MockPreviewer {
+	mock := &MockPreviewer{ctrl: ctrl}
+	mock.recorder = &MockPreviewerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPreviewer) EXPECT() *MockPreviewerMockRecorder {
+	return m.recorder
+}
+
+// GetDepositMethod mocks base method.
+func (m *MockPreviewer) GetDepositMethod(arg0 context.Context, arg1 string) (*exchanges.PaymentMethod, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDepositMethod", arg0, arg1)
+	ret0, _ := ret[0].(*exchanges.PaymentMethod)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDepositMethod indicates an expected call of GetDepositMethod.
+func (mr *MockPreviewerMockRecorder) GetDepositMethod(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDepositMethod", reflect.TypeOf((*MockPreviewer)(nil).GetDepositMethod), arg0, arg1)
+}
+
+// GetFeeRates mocks base method.
+func (m *MockPreviewer) GetFeeRates(arg0 context.Context) (*exchanges.FeeRates, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFeeRates", arg0)
+	ret0, _ := ret[0].(*exchanges.FeeRates)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFeeRates indicates an expected call of GetFeeRates.
+func (mr *MockPreviewerMockRecorder) GetFeeRates(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFeeRates", reflect.TypeOf((*MockPreviewer)(nil).GetFeeRates), arg0)
+}
+
+// PreviewOrder mocks base method.
+func (m *MockPreviewer) PreviewOrder(arg0 context.Context, arg1 string, arg2 float64, arg3 exchanges.OrderTypeType, arg4 exchanges.CalcLimitOrder) (*exchanges.OrderPreview, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PreviewOrder", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(*exchanges.OrderPreview)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PreviewOrder indicates an expected call of PreviewOrder.
+func (mr *MockPreviewerMockRecorder) PreviewOrder(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreviewOrder", reflect.TypeOf((*MockPreviewer)(nil).PreviewOrder), arg0, arg1, arg2, arg3, arg4)
 }
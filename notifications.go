@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/sberserker/dcagdax/notify"
+)
+
+// defaultNotifyTemplate renders the title and body of a run notification.
+// --notify-template replaces it with a file defining the same templates.
+const defaultNotifyTemplate = `{{define "title"}}dcagdax{{with .Profile}} {{.}}{{end}} {{.Status}}{{if .Reason}}: {{.Reason}}{{end}}{{end}}
+{{define "body"}}{{if .Error}}Error: {{.Error}}
+{{end}}{{if .Paused}}Purchases are paused{{with .ResumeAt}} until {{.Format "2006-01-02 15:04"}}{{end}}, orders and deposits were only logged
+{{end}}{{if .Message}}{{.Message}}
+{{end}}{{range .Orders}}{{.Coin}} {{printf "%.2f" .Amount}} {{$.Currency}}{{if .OrderID}} order {{.OrderID}}{{end}}{{if .Error}} {{.Status}}: {{.Error}}{{end}}
+{{end}}{{with .Deposit}}Deposit {{printf "%.2f" .Amount}} {{$.Currency}}
+{{end}}{{end}}`
+
+// severity keeps waiting for the next window quiet and raises runs which
+// need attention.
+func (r *runResult) severity() notify.Severity {
+	switch r.Status {
+	case statusFailed:
+		return notify.Error
+	case statusPartial:
+		return notify.Warning
+	case statusSkipped:
+		switch r.Reason {
+		case skipRecentPurchase, skipNotStarted, skipOutsideHours, skipDeadline:
+			return notify.Debug
+		}
+		return notify.Warning
+	}
+	return notify.Info
+}
+
+type notifier struct {
+	router *notify.Router
+	tmpl   *template.Template
+}
+
+// newNotifier builds a notifier from --notify specs, nil when none is given.
+func newNotifier(specs []string, templatePath string) (*notifier, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	n := &notifier{router: &notify.Router{}}
+	for _, spec := range specs {
+		min, sink, err := notify.Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		n.router.Add(min, sink)
+	}
+
+	text := defaultNotifyTemplate
+	if templatePath != "" {
+		b, err := os.ReadFile(templatePath)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+	}
+
+	tmpl, err := template.New("notification").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"title", "body"} {
+		if tmpl.Lookup(name) == nil {
+			return nil, errors.New("notification template must define \"title\" and \"body\"")
+		}
+	}
+	n.tmpl = tmpl
+
+	return n, nil
+}
+
+func (n *notifier) render(r *runResult) (notify.Message, error) {
+	var title, body bytes.Buffer
+	if err := n.tmpl.ExecuteTemplate(&title, "title", r); err != nil {
+		return notify.Message{}, err
+	}
+	if err := n.tmpl.ExecuteTemplate(&body, "body", r); err != nil {
+		return notify.Message{}, err
+	}
+
+	return notify.Message{
+		Severity: r.severity(),
+		Title:    strings.TrimSpace(title.String()),
+		Body:     strings.TrimSpace(body.String()),
+		Data:     r,
+	}, nil
+}
+
+// notify sends the outcome of a Sync run to the configured sinks.
+func (s *gdaxSchedule) notify(ctx context.Context, r *runResult) {
+	if s.notifier == nil {
+		return
+	}
+
+	m, err := s.notifier.render(r)
+	if err != nil {
+		s.logger.Warnw(
+			"Notification template failed",
+			"error", err,
+		)
+		return
+	}
+
+	if err := s.notifier.router.Send(ctx, m); err != nil {
+		s.logger.Warnw(
+			"Notification failed",
+			"error", err,
+		)
+	}
+}
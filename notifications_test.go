@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sberserker/dcagdax/exchanges"
+	"github.com/sberserker/dcagdax/mocks"
+	"github.com/sberserker/dcagdax/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunResultSeverity(t *testing.T) {
+	type test struct {
+		result   runResult
+		severity notify.Severity
+	}
+
+	tests := []test{
+		{result: runResult{Status: statusSucceeded}, severity: notify.Info},
+		{result: runResult{Status: statusDryRun}, severity: notify.Info},
+		{result: runResult{Status: statusPartial}, severity: notify.Warning},
+		{result: runResult{Status: statusSkipped, Reason: skipRecentPurchase}, severity: notify.Debug},
+		{result: runResult{Status: statusSkipped, Reason: skipPendingTransfers}, severity: notify.Warning},
+		{result: runResult{Status: statusFailed}, severity: notify.Error},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.severity, tc.result.severity(), tc.result.Status)
+	}
+}
+
+func TestNotifierRender(t *testing.T) {
+	n, err := newNotifier([]string{"webhook+https://example.com"}, "")
+	assert.Nil(t, err)
+
+	r := &runResult{Currency: "USD", Deposit: &depositOutcome{Amount: 25}, Orders: []orderOutcome{
+		{Coin: "ETH", Amount: 20, Status: orderRejected, Error: "price guard"},
+		{Coin: "BTC", Amount: 30, Status: orderPlaced, OrderID: "1"},
+	}}
+	r.finish(nil)
+
+	m, err := n.render(r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, notify.Warning, m.Severity)
+	assert.Equal(t, "dcagdax partially failed", m.Title)
+	assert.Equal(t, "BTC 30.00 USD order 1\nETH 20.00 USD rejected: price guard\nDeposit 25.00 USD", m.Body)
+
+	t.Run("when template is customized", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "notify.tmpl")
+		os.WriteFile(path, []byte(`{{define "title"}}DCA {{.Status}}{{end}}{{define "body"}}{{len .Orders}} orders{{end}}`), 0644)
+
+		n, err := newNotifier([]string{"webhook+https://example.com"}, path)
+		assert.Nil(t, err)
+
+		m, err := n.render(r)
+		assert.Nil(t, err)
+		assert.Equal(t, "DCA partially failed", m.Title)
+		assert.Equal(t, "2 orders", m.Body)
+	})
+
+	t.Run("when template is incomplete", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "notify.tmpl")
+		os.WriteFile(path, []byte(`{{define "title"}}DCA{{end}}`), 0644)
+
+		_, err := newNotifier([]string{"webhook+https://example.com"}, path)
+		assert.EqualError(t, err, "notification template must define \"title\" and \"body\"")
+	})
+
+	n, err = newNotifier(nil, "")
+	assert.Nil(t, err)
+	assert.Nil(t, n)
+}
+
+func TestSyncNotifiesOutcome(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	received := make(chan notify.Message, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var m notify.Message
+		json.Unmarshal(body, &m)
+		received <- m
+	}))
+	defer srv.Close()
+
+	n, err := newNotifier([]string{"warning:webhook+" + srv.URL}, "")
+	assert.Nil(t, err)
+
+	m := mocks.NewMockExchange(ctrl)
+
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, currency: "USD", usd: 50}
+	s.coins = map[string]orderDetails{"BTC": {symbol: "btcusd", amount: 50}}
+	s.markerCoin = "BTC"
+	s.sleepFunc = func(ctx context.Context, d time.Duration) error { return nil }
+	s.exchange = m
+	s.notifier = n
+
+	m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(nil, nil)
+	m.EXPECT().GetFiatAccount(ctx, "USD").Return(&exchanges.Account{Available: 25}, nil)
+	m.EXPECT().GetPendingTransfers(ctx, "USD").Return([]exchanges.PendingTransfer{}, nil)
+
+	_, err = s.Sync()
+
+	assert.ErrorIs(t, err, errInsufficientFunds)
+
+	msg := <-received
+	assert.Equal(t, notify.Error, msg.Severity)
+	assert.Equal(t, "dcagdax failed", msg.Title)
+	assert.Equal(t, "Error: No sufficient amount for trade and autofund is disabled. Deposit money to proceed", msg.Body)
+}
@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Webhook posts the message with its structured data as JSON.
+type Webhook struct {
+	URL string
+}
+
+func (w *Webhook) Send(ctx context.Context, m Message) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return send(req)
+}
+
+// Ntfy publishes the message body to an ntfy style topic URL with the title
+// and priority in headers.
+type Ntfy struct {
+	URL string
+}
+
+var ntfyPriorities = map[Severity]string{
+	Debug:   "min",
+	Info:    "default",
+	Warning: "high",
+	Error:   "urgent",
+}
+
+func (n *Ntfy) Send(ctx context.Context, m Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, strings.NewReader(m.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", m.Title)
+	req.Header.Set("Priority", ntfyPriorities[m.Severity])
+	req.Header.Set("Tags", m.Severity.String())
+
+	return send(req)
+}
+
+// Telegram sends the message through the Telegram bot API.
+type Telegram struct {
+	Token   string
+	ChatID  string
+	BaseURL string // defaults to https://api.telegram.org
+}
+
+func (t *Telegram) Send(ctx context.Context, m Message) error {
+	base := t.BaseURL
+	if base == "" {
+		base = "https://api.telegram.org"
+	}
+
+	form := url.Values{}
+	form.Set("chat_id", t.ChatID)
+	form.Set("text", m.Title+"\n\n"+m.Body)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/bot%s/sendMessage", base, t.Token), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	err = send(req)
+	// the url holds the bot token, which must not end up in the logs
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		uerr.URL = strings.ReplaceAll(uerr.URL, t.Token, "REDACTED")
+	}
+	return err
+}
+
+// SMTP emails the message as plain text. Auth is optional.
+type SMTP struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+func (s *SMTP) Send(ctx context.Context, m Message) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", m.Title)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&msg, "\r\n%s\r\n", strings.ReplaceAll(m.Body, "\n", "\r\n"))
+
+	// net/smtp has no context support
+	return smtp.SendMail(s.Addr, s.Auth, s.From, s.To, msg.Bytes())
+}
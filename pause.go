@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pauseState is the content of the pause file. An empty file, e.g. created
+// with touch, pauses until it is removed.
+type pauseState struct {
+	PausedAt time.Time  `json:"paused_at"`
+	ResumeAt *time.Time `json:"resume_at,omitempty"`
+}
+
+// pauseSwitch is the kill switch refusing orders and deposits. With a pause
+// file the state is shared with the pause and resume commands, otherwise it
+// only lives in memory.
+type pauseSwitch struct {
+	path string
+
+	mu    sync.Mutex
+	state *pauseState // without a pause file
+}
+
+// pause turns the switch on, until resumeAt when it is not zero.
+func (p *pauseSwitch) pause(now time.Time, resumeAt time.Time) error {
+	state := &pauseState{PausedAt: now}
+	if !resumeAt.IsZero() {
+		state.ResumeAt = &resumeAt
+	}
+
+	if p.path == "" {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.state = state
+		return nil
+	}
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.path, append(b, '\n'), 0o644)
+}
+
+func (p *pauseSwitch) resume() error {
+	if p.path == "" {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.state = nil
+		return nil
+	}
+
+	err := os.Remove(p.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// status returns the active pause or nil. A pause past its resume time is
+// turned off.
+func (p *pauseSwitch) status(now time.Time) (*pauseState, error) {
+	state, err := p.load()
+	if err != nil || state == nil {
+		return state, err
+	}
+
+	if state.ResumeAt != nil && !now.Before(*state.ResumeAt) {
+		return nil, p.resume()
+	}
+
+	return state, nil
+}
+
+func (p *pauseSwitch) load() (*pauseState, error) {
+	if p.path == "" {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.state, nil
+	}
+
+	b, err := os.ReadFile(p.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := &pauseState{}
+	if strings.TrimSpace(string(b)) == "" {
+		return state, nil
+	}
+
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// checkPause decides whether the run starting at now is paused. An
+// unreadable pause file pauses the run rather than risking orders.
+func (s *gdaxSchedule) checkPause(now time.Time) *pauseState {
+	state, err := s.pause.status(now)
+	if err != nil {
+		s.logger.Warnw(
+			"Pause file is unreadable, treating purchases as paused",
+			"error", err,
+		)
+		state = &pauseState{}
+	}
+
+	if state == nil {
+		pausedGauge.Set(0, s.profile)
+		return nil
+	}
+
+	pausedGauge.Set(1, s.profile)
+
+	if state.ResumeAt != nil {
+		s.logger.Warnw(
+			"Purchases are paused, orders and deposits are only logged",
+			"resume", state.ResumeAt.Local(),
+		)
+	} else {
+		s.logger.Warnw("Purchases are paused, orders and deposits are only logged")
+	}
+
+	return state
+}
+
+// pausedNow checks the kill switch again right before an order or a deposit,
+// a pause turned on since the run started, e.g. during the jitter delay,
+// turns the rest of the run into a dry run.
+func (s *gdaxSchedule) pausedNow(now time.Time) bool {
+	if !s.paused {
+		s.paused = s.checkPause(now) != nil
+	}
+	return s.paused
+}
+
+// dryRun reports whether orders and deposits are only logged, without
+// --trade or while paused.
+func (s *gdaxSchedule) dryRun() bool {
+	return s.debug || s.paused
+}
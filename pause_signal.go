@@ -0,0 +1,38 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// watchPauseSignals pauses purchases on SIGUSR1 and resumes them on SIGUSR2
+// until ctx is cancelled.
+func watchPauseSignals(ctx context.Context, s *gdaxSchedule) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(c)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-c:
+			var err error
+			if sig == syscall.SIGUSR1 {
+				err = s.pause.pause(time.Now(), time.Time{})
+				s.logger.Warnw("Purchases paused by SIGUSR1")
+			} else {
+				err = s.pause.resume()
+				s.logger.Infow("Purchases resumed by SIGUSR2")
+			}
+			if err != nil {
+				s.logger.Error(err)
+			}
+		}
+	}
+}
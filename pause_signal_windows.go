@@ -0,0 +1,6 @@
+package main
+
+import "context"
+
+// watchPauseSignals does nothing on Windows which has no SIGUSR1.
+func watchPauseSignals(ctx context.Context, s *gdaxSchedule) {}
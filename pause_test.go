@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sberserker/dcagdax/exchanges"
+	"github.com/sberserker/dcagdax/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPauseSwitch(t *testing.T) {
+	now := time.Date(2023, 3, 6, 14, 0, 0, 0, time.UTC)
+	resume := now.Add(24 * time.Hour)
+
+	t.Run("with pause file", func(t *testing.T) {
+		p := pauseSwitch{path: filepath.Join(t.TempDir(), "pause")}
+
+		state, err := p.status(now)
+		assert.Nil(t, err)
+		assert.Nil(t, state)
+
+		assert.Nil(t, p.pause(now, resume))
+
+		state, err = p.status(now)
+		assert.Nil(t, err)
+		assert.Equal(t, now, state.PausedAt)
+		assert.Equal(t, resume, *state.ResumeAt)
+
+		// auto resume removes the file
+		state, err = p.status(resume)
+		assert.Nil(t, err)
+		assert.Nil(t, state)
+		assert.NoFileExists(t, p.path)
+
+		assert.Nil(t, p.resume())
+	})
+
+	t.Run("when pause file is empty", func(t *testing.T) {
+		p := pauseSwitch{path: filepath.Join(t.TempDir(), "pause")}
+		os.WriteFile(p.path, nil, 0644)
+
+		state, err := p.status(now)
+		assert.Nil(t, err)
+		assert.NotNil(t, state)
+		assert.Nil(t, state.ResumeAt)
+	})
+
+	t.Run("when pause file is corrupt", func(t *testing.T) {
+		p := pauseSwitch{path: filepath.Join(t.TempDir(), "pause")}
+		os.WriteFile(p.path, []byte("{"), 0644)
+
+		_, err := p.status(now)
+		assert.NotNil(t, err)
+
+		s := gdaxSchedule{logger: loggerStub(t).Sugar()}
+		s.pause.path = p.path
+		assert.NotNil(t, s.checkPause(now))
+		assert.Equal(t, 1.0, pausedGauge.Value(""))
+	})
+
+	t.Run("in memory", func(t *testing.T) {
+		p := pauseSwitch{}
+
+		assert.Nil(t, p.pause(now, time.Time{}))
+		state, _ := p.status(now)
+		assert.NotNil(t, state)
+
+		assert.Nil(t, p.resume())
+		state, _ = p.status(now)
+		assert.Nil(t, state)
+	})
+}
+
+func TestSyncWhenPaused(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	m := mocks.NewMockExchange(ctrl)
+
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, autoFund: true, currency: "USD", usd: 50}
+	s.markerCoin = "BTC"
+	s.coins = map[string]orderDetails{"BTC": {symbol: "btcusd", amount: 50}}
+	s.sleepFunc = func(ctx context.Context, d time.Duration) error { return nil }
+	s.exchange = m
+	s.pause.pause(time.Now(), time.Time{})
+
+	// no deposit and no order
+	m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(nil, nil)
+	m.EXPECT().GetFiatAccount(ctx, "USD").Return(&exchanges.Account{Available: 25}, nil)
+	m.EXPECT().GetPendingTransfers(ctx, "USD").Return([]exchanges.PendingTransfer{}, nil)
+	m.EXPECT().GetTicker(ctx, "btcusd").Return(&exchanges.Ticker{Price: 20000}, nil)
+
+	r, err := s.Sync()
+
+	assert.Nil(t, err)
+	assert.True(t, r.Paused)
+	assert.Equal(t, statusPaused, r.Status)
+	assert.Equal(t, exitSkipped, r.exitCode())
+	assert.Nil(t, r.Deposit)
+	assert.Equal(t, orderDryRun, r.Orders[0].Status)
+	assert.Equal(t, 25.0, r.Plan.Deposit.Amount)
+
+	s.pause.resume()
+	s.req.after = time.Now().Add(time.Hour)
+
+	r, _ = s.Sync()
+	assert.False(t, r.Paused)
+	assert.Equal(t, 0.0, pausedGauge.Value(""))
+}
+
+func TestPausedGaugePerProfile(t *testing.T) {
+	now := time.Now()
+
+	alice := gdaxSchedule{profile: "alice", logger: loggerStub(t).Sugar()}
+	assert.Nil(t, alice.pause.pause(now, time.Time{}))
+	bob := gdaxSchedule{profile: "bob", logger: loggerStub(t).Sugar()}
+
+	assert.NotNil(t, alice.checkPause(now))
+	assert.Nil(t, bob.checkPause(now))
+
+	assert.Equal(t, 1.0, pausedGauge.Value("alice"))
+	assert.Equal(t, 0.0, pausedGauge.Value("bob"))
+}
+
+func TestSyncPausedDuringJitter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	m := mocks.NewMockExchange(ctrl)
+
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, currency: "USD", usd: 50, jitter: time.Hour}
+	s.markerCoin = "BTC"
+	s.coins = map[string]orderDetails{"BTC": {symbol: "btcusd", amount: 50}}
+	s.jitterFunc = func(d time.Duration) time.Duration { return d }
+	s.exchange = m
+
+	// the pause comes in while the run waits to place the order
+	s.sleepFunc = func(ctx context.Context, d time.Duration) error {
+		return s.pause.pause(time.Now(), time.Time{})
+	}
+
+	m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(nil, nil)
+	m.EXPECT().GetFiatAccount(ctx, "USD").Return(&exchanges.Account{Available: 100}, nil)
+	m.EXPECT().GetTicker(ctx, "btcusd").Return(&exchanges.Ticker{Price: 20000}, nil).AnyTimes()
+
+	r, err := s.Sync()
+
+	assert.Nil(t, err)
+	assert.True(t, r.Paused)
+	assert.Equal(t, statusPaused, r.Status)
+	assert.Equal(t, orderDryRun, r.Orders[0].Status)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sberserker/dcagdax/exchanges"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	planFormatTable = "table"
+	planFormatJSON  = "json"
+)
+
+// runPlan is what a run without --trade, or while paused, would have sent to
+// the exchange.
+type runPlan struct {
+	FeeTier string          `json:"fee_tier,omitempty"`
+	FeeRate float64         `json:"fee_rate"` // expected fraction of the order value
+	Orders  []plannedOrder  `json:"orders"`
+	Deposit *plannedDeposit `json:"deposit,omitempty"`
+}
+
+type plannedOrder struct {
+	Coin       string   `json:"coin"`
+	ProductID  string   `json:"product_id"`
+	Type       string   `json:"type"`
+	Amount     float64  `json:"amount"` // quote currency
+	Price      float64  `json:"price,omitempty"`
+	LimitPrice float64  `json:"limit_price,omitempty"`
+	Size       float64  `json:"size,omitempty"`
+	Fee        float64  `json:"fee"`
+	Previewed  bool     `json:"previewed"`
+	Total      float64  `json:"total,omitempty"` // previewed total including fees
+	Errors     []string `json:"errors,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+type plannedDeposit struct {
+	Amount        float64 `json:"amount"`
+	Currency      string  `json:"currency"`
+	PaymentMethod string  `json:"payment_method,omitempty"`
+	Error         string  `json:"error,omitempty"`
+}
+
+func validPlanFormat(format string) bool {
+	return format == planFormatTable || format == planFormatJSON
+}
+
+// plan returns the plan of the run, starting it with the fee rate of the run.
+func (s *gdaxSchedule) plan(ctx context.Context, r *runResult) *runPlan {
+	if r.Plan != nil {
+		return r.Plan
+	}
+
+	fees := s.loadFees(ctx)
+	r.Plan = &runPlan{FeeTier: fees.tier, FeeRate: fees.rate, Orders: []plannedOrder{}}
+
+	return r.Plan
+}
+
+// planOrder computes the order makePurchase would place and validates it
+// with the exchange preview when supported.
+func (s *gdaxSchedule) planOrder(ctx context.Context, r *runResult, coin string, order orderDetails) {
+	plan := s.plan(ctx, r)
+
+	o := plannedOrder{
+		Coin:      coin,
+		ProductID: order.symbol,
+		Type:      "market",
+		Amount:    order.amount,
+	}
+
+	amount := decimal.NewFromFloat(order.amount)
+	rate := decimal.NewFromFloat(plan.FeeRate)
+
+	ticker, err := s.exchange.GetTicker(ctx, order.symbol)
+	if err != nil {
+		o.Errors = append(o.Errors, err.Error())
+	} else {
+		o.Price = ticker.Price
+		price := decimal.NewFromFloat(ticker.Price)
+
+		if s.req.orderType == exchanges.Limit {
+			limitPrice, size := s.calcLimitOrder(price, amount)
+			o.Type = "limit"
+			o.LimitPrice, _ = limitPrice.Float64()
+			o.Size, _ = size.Float64()
+			o.Fee, _ = limitPrice.Mul(size).Mul(rate).Round(2).Float64()
+		} else if price.IsPositive() {
+			// fees are taken from the quote amount of market orders
+			fee := amount.Mul(rate)
+			o.Size, _ = amount.Sub(fee).Div(price).Truncate(8).Float64()
+			o.Fee, _ = fee.Round(2).Float64()
+		}
+	}
+
+	if p, ok := s.exchange.(exchanges.Previewer); ok {
+		preview, err := p.PreviewOrder(ctx, order.symbol, order.amount, s.req.orderType, s.calcLimitOrder)
+		if err != nil {
+			o.Errors = append(o.Errors, err.Error())
+		} else {
+			o.Previewed = true
+			o.Total = preview.Total
+			o.Fee = preview.Commission
+			if preview.BaseSize > 0 {
+				o.Size = preview.BaseSize
+			}
+			o.Errors = append(o.Errors, preview.Errors...)
+			o.Warnings = preview.Warnings
+		}
+	}
+
+	s.logger.Infow(
+		"Planned order",
+		"productId", o.ProductID,
+		"type", o.Type,
+		"amount", o.Amount,
+		"limitPrice", o.LimitPrice,
+		"size", o.Size,
+		"fee", o.Fee,
+		"previewed", o.Previewed,
+		"errors", o.Errors,
+	)
+
+	plan.Orders = append(plan.Orders, o)
+}
+
+// planDeposit records the deposit fund would initiate and where from.
+func (s *gdaxSchedule) planDeposit(ctx context.Context, r *runResult, amount float64) {
+	d := &plannedDeposit{Amount: amount, Currency: s.req.currency}
+
+	if p, ok := s.exchange.(exchanges.Previewer); ok {
+		method, err := p.GetDepositMethod(ctx, s.req.currency)
+		if err != nil {
+			d.Error = err.Error()
+		} else {
+			d.PaymentMethod = fmt.Sprintf("%s (%s)", method.Name, method.Type)
+		}
+	}
+
+	s.logger.Infow(
+		"Planned deposit",
+		"amount", d.Amount,
+		"paymentMethod", d.PaymentMethod,
+	)
+
+	s.plan(ctx, r).Deposit = d
+}
+
+func writePlan(w io.Writer, format string, p *runPlan) error {
+	switch format {
+	case planFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(p)
+	case planFormatTable:
+	default:
+		return fmt.Errorf("unsupported plan format %s, use one of table, json", format)
+	}
+
+	optional := func(v float64, format string) string {
+		if v == 0 {
+			return "-"
+		}
+		return fmt.Sprintf(format, v)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Coin\tProduct\tType\tAmount\tPrice\tLimit price\tSize\tFee\tPreview")
+	for _, o := range p.Orders {
+		preview := "-"
+		switch {
+		case len(o.Errors) > 0:
+			preview = strings.Join(o.Errors, ", ")
+		case o.Previewed:
+			preview = "ok"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.2f\t%s\t%s\t%s\t%.2f\t%s\n",
+			o.Coin, o.ProductID, o.Type, o.Amount,
+			optional(o.Price, "%.2f"), optional(o.LimitPrice, "%.2f"), optional(o.Size, "%.8f"),
+			o.Fee, preview,
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	rate := fmt.Sprintf("%.2f%%", p.FeeRate*100)
+	if p.FeeTier != "" {
+		rate += " (" + p.FeeTier + ")"
+	}
+	fmt.Fprintf(w, "\nFee rate: %s\n", rate)
+
+	if d := p.Deposit; d != nil {
+		fmt.Fprintf(w, "Deposit: %.2f %s", d.Amount, d.Currency)
+		if d.PaymentMethod != "" {
+			fmt.Fprintf(w, " from %s", d.PaymentMethod)
+		}
+		if d.Error != "" {
+			fmt.Fprintf(w, " (%s)", d.Error)
+		}
+		fmt.Fprintln(w)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sberserker/dcagdax/exchanges"
+	"github.com/sberserker/dcagdax/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+type previewExchange struct {
+	*mocks.MockExchange
+	*mocks.MockPreviewer
+}
+
+func TestSyncPlanWithPreview(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	m := mocks.NewMockExchange(ctrl)
+	p := mocks.NewMockPreviewer(ctrl)
+
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Limit, autoFund: true, currency: "USD", usd: 100, fee: 0.5, orderSpread: 1}
+	s.debug = true
+	s.markerCoin = "BTC"
+	s.coins = map[string]orderDetails{"BTC": {symbol: "BTC-USD", amount: 100}}
+	s.sleepFunc = func(ctx context.Context, d time.Duration) error { return nil }
+	s.exchange = previewExchange{m, p}
+
+	m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(nil, nil)
+	m.EXPECT().GetFiatAccount(ctx, "USD").Return(&exchanges.Account{Available: 40}, nil)
+	m.EXPECT().GetPendingTransfers(ctx, "USD").Return([]exchanges.PendingTransfer{}, nil)
+	m.EXPECT().GetTicker(ctx, "BTC-USD").Return(&exchanges.Ticker{Price: 20000}, nil)
+	p.EXPECT().GetFeeRates(ctx).Return(&exchanges.FeeRates{Tier: "Advanced 1", Maker: 0.004, Taker: 0.006}, nil)
+	p.EXPECT().GetDepositMethod(ctx, "USD").Return(&exchanges.PaymentMethod{ID: "1", Name: "Bank", Type: "ACH"}, nil)
+	p.EXPECT().PreviewOrder(ctx, "BTC-USD", 100.0, exchanges.Limit, gomock.Any()).Return(&exchanges.OrderPreview{
+		BaseSize:   0.00492574,
+		Total:      100,
+		Commission: 0.6,
+		Warnings:   []string{"BIG_ORDER_SLIPPAGE"},
+	}, nil)
+
+	r, err := s.Sync()
+
+	assert.Nil(t, err)
+	assert.Equal(t, &runPlan{
+		FeeTier: "Advanced 1",
+		FeeRate: 0.004,
+		Orders: []plannedOrder{{
+			Coin:       "BTC",
+			ProductID:  "BTC-USD",
+			Type:       "limit",
+			Amount:     100,
+			Price:      20000,
+			LimitPrice: 20200,
+			Size:       0.00492574,
+			Fee:        0.6,
+			Previewed:  true,
+			Total:      100,
+			Warnings:   []string{"BIG_ORDER_SLIPPAGE"},
+		}},
+		Deposit: &plannedDeposit{Amount: 60, Currency: "USD", PaymentMethod: "Bank (ACH)"},
+	}, r.Plan)
+}
+
+func TestPlanOrderWhenPreviewFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	m := mocks.NewMockExchange(ctrl)
+	p := mocks.NewMockPreviewer(ctrl)
+
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{orderType: exchanges.Market, fee: 0.5}
+	s.exchange = previewExchange{m, p}
+
+	m.EXPECT().GetTicker(ctx, "BTC-USD").Return(&exchanges.Ticker{Price: 20000}, nil)
+	p.EXPECT().GetFeeRates(ctx).Return(nil, errors.New("unauthorized"))
+	p.EXPECT().PreviewOrder(ctx, "BTC-USD", 50.0, exchanges.Market, gomock.Any()).Return(nil, errors.New("invalid product"))
+
+	r := &runResult{}
+	s.planOrder(ctx, r, "BTC", orderDetails{symbol: "BTC-USD", amount: 50})
+
+	assert.Equal(t, 0.005, r.Plan.FeeRate)
+	assert.Equal(t, plannedOrder{Coin: "BTC", ProductID: "BTC-USD", Type: "market", Amount: 50, Price: 20000, Size: 0.0024875, Fee: 0.25, Errors: []string{"invalid product"}}, r.Plan.Orders[0])
+}
+
+func TestWritePlan(t *testing.T) {
+	p := &runPlan{
+		FeeTier: "Advanced 1",
+		FeeRate: 0.006,
+		Orders: []plannedOrder{
+			{Coin: "BTC", ProductID: "BTC-USD", Type: "limit", Amount: 100, Price: 20000, LimitPrice: 20200, Size: 0.00492574, Fee: 0.6, Previewed: true},
+			{Coin: "ETH", ProductID: "ETH-USD", Type: "limit", Amount: 50, Errors: []string{"PREVIEW_INSUFFICIENT_FUND"}},
+		},
+		Deposit: &plannedDeposit{Amount: 60, Currency: "USD", PaymentMethod: "Bank (ACH)"},
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, writePlan(&buf, planFormatTable, p))
+	assert.Equal(t, `Coin  Product  Type   Amount  Price     Limit price  Size        Fee   Preview
+BTC   BTC-USD  limit  100.00  20000.00  20200.00     0.00492574  0.60  ok
+ETH   ETH-USD  limit  50.00   -         -            -           0.00  PREVIEW_INSUFFICIENT_FUND
+
+Fee rate: 0.60% (Advanced 1)
+Deposit: 60.00 USD from Bank (ACH)
+`, buf.String())
+
+	buf.Reset()
+	assert.Nil(t, writePlan(&buf, planFormatJSON, p))
+	assert.Contains(t, buf.String(), `"limit_price": 20200`)
+
+	assert.EqualError(t, writePlan(&buf, "csv", p), "unsupported plan format csv, use one of table, json")
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sberserker/dcagdax/calendar"
+	"github.com/sberserker/dcagdax/exchanges"
+)
+
+// profile is a named account running its own strategy in the same process.
+// The keys mirror the command line flags, unset ones keep the flag values.
+type profile struct {
+	Name string `json:"name"`
+
+	Exchange     string `json:"exchange"`
+	KeyEnv       string `json:"key_env"`
+	SecretEnv    string `json:"secret_env"`
+	PortfolioEnv string `json:"portfolio_env"`
+
+	Coins          []string `json:"coins"`
+	USD            float64  `json:"usd"`
+	Currency       string   `json:"currency"`
+	Every          string   `json:"every"`
+	Schedule       string   `json:"schedule"`
+	OrderType      string   `json:"type"`
+	AutoFund       *bool    `json:"autofund"`
+	MaxPrices      []string `json:"max_price"`
+	MinPrices      []string `json:"min_price"`
+	CarryCap       *float64 `json:"carry_cap"`
+	Ledger         string   `json:"ledger"`
+	PauseFile      string   `json:"pause_file"`
+	Notify         []string `json:"notify"`
+	NotifyTemplate string   `json:"notify_template"`
+}
+
+type profileConfig struct {
+	Profiles []profile `json:"profiles"`
+}
+
+var profileNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// loadProfiles reads the profiles of path, only the named ones when names is
+// not empty.
+func loadProfiles(path string, names []string) ([]profile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f profileConfig
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("profiles file %s: %w", path, err)
+	}
+
+	byName := map[string]profile{}
+	for _, p := range f.Profiles {
+		if !profileNameRegex.MatchString(p.Name) {
+			return nil, fmt.Errorf("profile name %q must only contain letters, digits, - and _", p.Name)
+		}
+		if _, ok := byName[p.Name]; ok {
+			return nil, fmt.Errorf("profile %s is defined twice", p.Name)
+		}
+		byName[p.Name] = p
+	}
+
+	if len(names) == 0 {
+		if len(f.Profiles) == 0 {
+			return nil, fmt.Errorf("profiles file %s has no profiles", path)
+		}
+		return f.Profiles, nil
+	}
+
+	selected := []profile{}
+	for _, name := range names {
+		p, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("profile %s is not defined in %s", name, path)
+		}
+		selected = append(selected, p)
+	}
+
+	return selected, nil
+}
+
+// credentials defaults to the environment variables of the single account
+// suffixed with the profile name, e.g. COINBASE_KEY_ALICE.
+func (p profile) credentials() exchanges.CoinbaseCredentials {
+	suffix := "_" + strings.ToUpper(strings.ReplaceAll(p.Name, "-", "_"))
+
+	creds := exchanges.CoinbaseCredentials{
+		KeyEnv:       exchanges.DefaultCoinbaseCredentials.KeyEnv + suffix,
+		SecretEnv:    exchanges.DefaultCoinbaseCredentials.SecretEnv + suffix,
+		PortfolioEnv: exchanges.DefaultCoinbaseCredentials.PortfolioEnv + suffix,
+	}
+
+	if p.KeyEnv != "" {
+		creds.KeyEnv = p.KeyEnv
+	}
+	if p.SecretEnv != "" {
+		creds.SecretEnv = p.SecretEnv
+	}
+	if p.PortfolioEnv != "" {
+		creds.PortfolioEnv = p.PortfolioEnv
+	}
+
+	return creds
+}
+
+func (p profile) exchange() (exchanges.Exchange, error) {
+	switch p.Exchange {
+	case "", "coinbase":
+		return exchanges.NewCoinbaseV3Account(p.credentials())
+	default:
+		return nil, fmt.Errorf("profile %s: unsupported exchange %s", p.Name, p.Exchange)
+	}
+}
+
+// apply returns req with the strategy of the profile.
+func (p profile) apply(req syncRequest) (syncRequest, error) {
+	var err error
+
+	if len(p.Coins) > 0 {
+		req.coins = p.Coins
+	}
+	if p.USD > 0 {
+		req.usd = p.USD
+	}
+	if p.Currency != "" {
+		req.currency = p.Currency
+	}
+
+	switch {
+	case p.Every != "" && p.Schedule != "":
+		return req, fmt.Errorf("profile %s: use either every or schedule", p.Name)
+	case p.Every != "":
+		var every generousDuration
+		if err := every.Set(p.Every); err != nil {
+			return req, fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+		req.every = time.Duration(every)
+		req.schedule = nil
+	case p.Schedule != "":
+		req.schedule, err = calendar.Parse(p.Schedule)
+		if err != nil {
+			return req, fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+		req.every = 0
+	}
+
+	if p.OrderType != "" {
+		req.orderType, err = parseOrderType(p.OrderType)
+		if err != nil {
+			return req, fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+	}
+	if p.AutoFund != nil {
+		req.autoFund = *p.AutoFund
+	}
+	if len(p.MaxPrices) > 0 || len(p.MinPrices) > 0 {
+		req.bands, err = parseBands(p.MaxPrices, p.MinPrices)
+		if err != nil {
+			return req, fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+	}
+	if p.CarryCap != nil {
+		req.carryCap = *p.CarryCap
+	}
+	if p.Ledger != "" {
+		req.ledger = p.Ledger
+	}
+	if p.PauseFile != "" {
+		req.pauseFile = p.PauseFile
+	}
+	if len(p.Notify) > 0 {
+		req.notify = p.Notify
+	}
+	if p.NotifyTemplate != "" {
+		req.template = p.NotifyTemplate
+	}
+
+	return req, nil
+}
+
+// profileRequests applies the profiles to the request built from the flags.
+// Profiles record purchases in their own ledger, a shared one would make
+// each profile see the purchases of the others. Likewise a shared pause file
+// would pause every profile when one is paused.
+func profileRequests(base syncRequest, profiles []profile) ([]syncRequest, error) {
+	reqs := []syncRequest{}
+	ledgers := map[string]string{}
+	pauseFiles := map[string]string{}
+
+	for _, p := range profiles {
+		req, err := p.apply(base)
+		if err != nil {
+			return nil, err
+		}
+
+		if req.every == 0 && req.schedule == nil {
+			return nil, fmt.Errorf("profile %s: every or schedule is required", p.Name)
+		}
+
+		if req.ledger != "" {
+			if other, ok := ledgers[req.ledger]; ok {
+				return nil, fmt.Errorf("profiles %s and %s share the ledger %s", other, p.Name, req.ledger)
+			}
+			ledgers[req.ledger] = p.Name
+		}
+
+		if req.pauseFile != "" {
+			if other, ok := pauseFiles[req.pauseFile]; ok {
+				return nil, fmt.Errorf("profiles %s and %s share the pause file %s", other, p.Name, req.pauseFile)
+			}
+			pauseFiles[req.pauseFile] = p.Name
+		}
+
+		reqs = append(reqs, req)
+	}
+
+	return reqs, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sberserker/dcagdax/exchanges"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeProfiles(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	os.WriteFile(path, []byte(content), 0600)
+	return path
+}
+
+func TestLoadProfiles(t *testing.T) {
+	path := writeProfiles(t, `{"profiles": [
+		{"name": "alice", "coins": ["BTC:100"]},
+		{"name": "bob", "coins": ["ETH:100"], "portfolio_env": "BOB_PORTFOLIO"}
+	]}`)
+
+	profiles, err := loadProfiles(path, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []profile{
+		{Name: "alice", Coins: []string{"BTC:100"}},
+		{Name: "bob", Coins: []string{"ETH:100"}, PortfolioEnv: "BOB_PORTFOLIO"},
+	}, profiles)
+
+	profiles, err = loadProfiles(path, []string{"bob"})
+	assert.Nil(t, err)
+	assert.Equal(t, "bob", profiles[0].Name)
+	assert.Len(t, profiles, 1)
+
+	_, err = loadProfiles(path, []string{"carol"})
+	assert.EqualError(t, err, "profile carol is not defined in "+path)
+}
+
+func TestLoadProfilesInvalid(t *testing.T) {
+	type test struct {
+		content string
+		err     string
+	}
+
+	tests := []test{
+		{content: `{"profiles": [{"coins": ["BTC:100"]}]}`, err: `profile name "" must only contain letters, digits, - and _`},
+		{content: `{"profiles": [{"name": "alice smith"}]}`, err: `profile name "alice smith" must only contain letters, digits, - and _`},
+		{content: `{"profiles": [{"name": "alice"}, {"name": "alice"}]}`, err: "profile alice is defined twice"},
+	}
+
+	for _, tc := range tests {
+		path := writeProfiles(t, tc.content)
+
+		_, err := loadProfiles(path, nil)
+		assert.EqualError(t, err, tc.err)
+	}
+
+	path := writeProfiles(t, `{"profiles": []}`)
+	_, err := loadProfiles(path, nil)
+	assert.EqualError(t, err, "profiles file "+path+" has no profiles")
+}
+
+func TestProfileCredentials(t *testing.T) {
+	assert.Equal(t, exchanges.CoinbaseCredentials{
+		KeyEnv:       "COINBASE_KEY_MARY_JANE",
+		SecretEnv:    "COINBASE_SECRET_MARY_JANE",
+		PortfolioEnv: "PORTFOLIO_ID_MARY_JANE",
+	}, profile{Name: "mary-jane"}.credentials())
+
+	assert.Equal(t, exchanges.CoinbaseCredentials{
+		KeyEnv:       "FAMILY_KEY",
+		SecretEnv:    "FAMILY_SECRET",
+		PortfolioEnv: "PORTFOLIO_ID_BOB",
+	}, profile{Name: "bob", KeyEnv: "FAMILY_KEY", SecretEnv: "FAMILY_SECRET"}.credentials())
+
+	_, err := profile{Name: "bob", Exchange: "kraken"}.exchange()
+	assert.EqualError(t, err, "profile bob: unsupported exchange kraken")
+}
+
+func TestProfileApply(t *testing.T) {
+	autoFund := false
+	carryCap := 20.0
+
+	base := syncRequest{
+		every:     24 * time.Hour,
+		currency:  "USD",
+		usd:       100,
+		coins:     []string{"BTC:100"},
+		orderType: exchanges.Market,
+		autoFund:  true,
+		ledger:    "ledger.jsonl",
+		notify:    []string{"ntfy+https://ntfy.sh/family"},
+	}
+
+	req, err := profile{
+		Name:      "alice",
+		Coins:     []string{"BTC:50", "ETH:50"},
+		USD:       40,
+		Currency:  "EUR",
+		Every:     "1w",
+		OrderType: "limit",
+		AutoFund:  &autoFund,
+		MaxPrices: []string{"BTC:70000"},
+		CarryCap:  &carryCap,
+		Ledger:    "alice.jsonl",
+		PauseFile: "alice.pause",
+	}.apply(base)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"BTC:50", "ETH:50"}, req.coins)
+	assert.Equal(t, 40.0, req.usd)
+	assert.Equal(t, "EUR", req.currency)
+	assert.Equal(t, 7*24*time.Hour, req.every)
+	assert.Equal(t, exchanges.Limit, req.orderType)
+	assert.False(t, req.autoFund)
+	assert.Equal(t, 70000.0, req.bands["BTC"].max)
+	assert.Equal(t, 20.0, req.carryCap)
+	assert.Equal(t, "alice.jsonl", req.ledger)
+	assert.Equal(t, "alice.pause", req.pauseFile)
+	assert.Equal(t, []string{"ntfy+https://ntfy.sh/family"}, req.notify)
+
+	req, err = profile{Name: "bob", Schedule: "every friday 14:00"}.apply(base)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), req.every)
+	assert.NotNil(t, req.schedule)
+	assert.Equal(t, base.coins, req.coins)
+
+	_, err = profile{Name: "bob", Every: "1d", Schedule: "every friday 14:00"}.apply(base)
+	assert.EqualError(t, err, "profile bob: use either every or schedule")
+
+	_, err = profile{Name: "bob", OrderType: "stop"}.apply(base)
+	assert.EqualError(t, err, "profile bob: unsupported order type stop")
+}
+
+func TestProfileRequests(t *testing.T) {
+	base := syncRequest{every: 24 * time.Hour, ledger: "ledger.jsonl"}
+
+	reqs, err := profileRequests(base, []profile{{Name: "alice", Ledger: "alice.jsonl"}, {Name: "bob"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "alice.jsonl", reqs[0].ledger)
+	assert.Equal(t, "ledger.jsonl", reqs[1].ledger)
+
+	_, err = profileRequests(base, []profile{{Name: "alice"}, {Name: "bob"}})
+	assert.EqualError(t, err, "profiles alice and bob share the ledger ledger.jsonl")
+
+	base = syncRequest{every: 24 * time.Hour, pauseFile: "pause"}
+	reqs, err = profileRequests(base, []profile{{Name: "alice", PauseFile: "alice.pause"}, {Name: "bob"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "pause", reqs[1].pauseFile)
+
+	_, err = profileRequests(base, []profile{{Name: "alice"}, {Name: "bob"}})
+	assert.EqualError(t, err, "profiles alice and bob share the pause file pause")
+
+	_, err = profileRequests(syncRequest{}, []profile{{Name: "alice"}})
+	assert.EqualError(t, err, "profile alice: every or schedule is required")
+}
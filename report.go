@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sberserker/dcagdax/calendar"
+	"github.com/sberserker/dcagdax/exchanges"
+)
+
+const (
+	reportFormatTable    = "table"
+	reportFormatJSON     = "json"
+	reportFormatMarkdown = "markdown"
+)
+
+// reportRequest selects the purchases summarized by `dcagdax report`. The
+// cadence (every or schedule) is optional and used for adherence only.
+type reportRequest struct {
+	currency string
+	assets   []string
+	from     time.Time
+	every    time.Duration
+	schedule calendar.Schedule
+}
+
+// reportRow summarizes purchases of a coin, or of all coins for the total.
+// Percentages are nil when they are undefined.
+type reportRow struct {
+	Asset       string   `json:"asset"`
+	Invested    float64  `json:"invested"`
+	Quantity    float64  `json:"quantity,omitempty"`
+	AverageCost float64  `json:"average_cost,omitempty"`
+	Price       float64  `json:"price,omitempty"`
+	Value       float64  `json:"value"`
+	PnL         float64  `json:"pnl"`
+	PnLPercent  *float64 `json:"pnl_percent"`
+	XIRR        *float64 `json:"xirr_percent"`
+	TWR         *float64 `json:"twr_percent"`
+	Planned     int      `json:"planned_buys"`
+	Actual      int      `json:"actual_buys"`
+	Adherence   *float64 `json:"adherence_percent"`
+}
+
+type report struct {
+	Currency string      `json:"currency"`
+	Time     time.Time   `json:"time"`
+	Coins    []reportRow `json:"coins"`
+	Total    reportRow   `json:"total"`
+}
+
+// cashFlow is money put into (negative) or taken out of (positive) the portfolio.
+type cashFlow struct {
+	time   time.Time
+	amount float64
+}
+
+// buildReport values the purchase history at the current ticker prices.
+func buildReport(ctx context.Context, history exchanges.FillHistory, prices exchanges.Exchange, req reportRequest, now time.Time) (*report, error) {
+	productIds := []string{""}
+	if len(req.assets) > 0 {
+		productIds = productIds[:0]
+		for _, a := range req.assets {
+			productIds = append(productIds, history.GetTickerSymbol(strings.ToUpper(a), req.currency))
+		}
+	}
+
+	fills := []exchanges.Fill{}
+	for _, productId := range productIds {
+		f, err := history.GetFills(ctx, productId, req.from, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		fills = append(fills, f...)
+	}
+
+	lots := lotsFromFills("", fills, req.from, time.Time{}, req.assets, req.currency)
+	// sold quantities are no longer held, only the open lots are valued
+	open := openLots(lots, fills)
+
+	byAsset := map[string][]taxLot{}
+	for _, l := range lots {
+		byAsset[l.Asset] = append(byAsset[l.Asset], l)
+	}
+	openByAsset := map[string][]taxLot{}
+	for _, l := range open {
+		openByAsset[l.Asset] = append(openByAsset[l.Asset], l)
+	}
+
+	assets := make([]string, 0, len(byAsset))
+	for a := range byAsset {
+		assets = append(assets, a)
+	}
+	sort.Strings(assets)
+
+	current := map[string]float64{}
+	for _, a := range assets {
+		ticker, err := prices.GetTicker(ctx, prices.GetTickerSymbol(a, req.currency))
+		if err != nil {
+			return nil, err
+		}
+		current[a] = ticker.Price
+	}
+
+	r := &report{Currency: req.currency, Time: now, Coins: []reportRow{}}
+	for _, a := range assets {
+		row := summarize(a, byAsset[a], openByAsset[a], current, req, now)
+		row.Price = current[a]
+		if row.Quantity > 0 {
+			row.AverageCost = row.Invested / row.Quantity
+		}
+		r.Coins = append(r.Coins, row)
+	}
+
+	r.Total = summarize("TOTAL", lots, open, current, req, now)
+	r.Total.Quantity = 0 // quantities of different coins don't add up
+
+	// every coin is planned to be bought at each window
+	r.Total.Planned, r.Total.Actual = 0, 0
+	for _, row := range r.Coins {
+		r.Total.Planned += row.Planned
+		r.Total.Actual += row.Actual
+	}
+	r.Total.Adherence = adherence(r.Total.Planned, r.Total.Actual)
+
+	return r, nil
+}
+
+// summarize computes the totals and returns of the open lots and the
+// adherence of all bought lots, both sorted by acquisition time.
+func summarize(asset string, lots []taxLot, open []taxLot, prices map[string]float64, req reportRequest, now time.Time) reportRow {
+	row := reportRow{Asset: asset}
+
+	flows := []cashFlow{}
+	for _, l := range open {
+		cost, _ := l.Cost.Float64()
+		quantity, _ := l.Quantity.Float64()
+
+		row.Invested += cost
+		row.Quantity += quantity
+		row.Value += quantity * prices[l.Asset]
+
+		flows = append(flows, cashFlow{time: l.Acquired, amount: -cost})
+	}
+
+	orders := map[string]bool{}
+	for _, l := range lots {
+		// fills of one order are a single purchase
+		id := l.OrderID
+		if id == "" {
+			id = l.TradeID
+		}
+		orders[id] = true
+	}
+
+	row.PnL = row.Value - row.Invested
+	if row.Invested > 0 {
+		row.PnLPercent = percent(row.PnL / row.Invested)
+	}
+
+	flows = append(flows, cashFlow{time: now, amount: row.Value})
+	if rate, ok := xirr(flows); ok {
+		row.XIRR = percent(rate)
+	}
+
+	if growth, ok := timeWeightedReturn(open, prices); ok {
+		row.TWR = percent(growth)
+	}
+
+	row.Actual = len(orders)
+	if len(lots) > 0 && (req.every > 0 || req.schedule != nil) {
+		start := req.from
+		if start.IsZero() {
+			start = lots[0].Acquired
+		}
+		row.Planned = countWindows(req.every, req.schedule, start, now)
+		row.Adherence = adherence(row.Planned, row.Actual)
+	}
+
+	return row
+}
+
+func percent(v float64) *float64 {
+	p := v * 100
+	return &p
+}
+
+func adherence(planned int, actual int) *float64 {
+	if planned == 0 {
+		return nil
+	}
+	return percent(float64(actual) / float64(planned))
+}
+
+// xirr returns the annualized internal rate of return of irregular cash
+// flows. It is undefined without both investments and returns.
+func xirr(flows []cashFlow) (float64, bool) {
+	if len(flows) < 2 {
+		return 0, false
+	}
+
+	first := flows[0].time
+	for _, f := range flows {
+		if f.time.Before(first) {
+			first = f.time
+		}
+	}
+
+	year := 365 * 24 * time.Hour
+	npv := func(rate float64) float64 {
+		sum := 0.0
+		for _, f := range flows {
+			sum += f.amount / math.Pow(1+rate, float64(f.time.Sub(first))/float64(year))
+		}
+		return sum
+	}
+
+	low, high := -0.9999, 1.0
+	for npv(high) > 0 && high < 1e6 {
+		high *= 2
+	}
+
+	nLow, nHigh := npv(low), npv(high)
+	if nLow == nHigh || (nLow > 0) == (nHigh > 0) {
+		return 0, false
+	}
+
+	for i := 0; i < 200; i++ {
+		mid := (low + high) / 2
+		if (npv(mid) > 0) == (nLow > 0) {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+
+	return (low + high) / 2, true
+}
+
+// timeWeightedReturn chains the returns between purchases so the amount
+// invested doesn't skew the result. Holdings are valued at the latest known
+// price: the fill price of each purchase and the current price at the end.
+func timeWeightedReturn(lots []taxLot, prices map[string]float64) (float64, bool) {
+	last := map[string]float64{}
+	quantities := map[string]float64{}
+
+	value := func() float64 {
+		sum := 0.0
+		for a, q := range quantities {
+			sum += q * last[a]
+		}
+		return sum
+	}
+
+	growth := 1.0
+	start := 0.0 // value right after the previous purchase
+	for _, l := range lots {
+		last[l.Asset], _ = l.Price.Float64()
+
+		before := value()
+		if start > 0 {
+			growth *= before / start
+		}
+
+		cost, _ := l.Cost.Float64()
+		quantity, _ := l.Quantity.Float64()
+		quantities[l.Asset] += quantity
+		start = before + cost
+	}
+
+	if start == 0 {
+		return 0, false
+	}
+
+	for a := range quantities {
+		last[a] = prices[a]
+	}
+	growth *= value() / start
+
+	return growth - 1, true
+}
+
+func validReportFormat(format string) bool {
+	return format == reportFormatTable || format == reportFormatJSON || format == reportFormatMarkdown
+}
+
+// writeReport writes the report as a terminal table, JSON or Markdown.
+func writeReport(w io.Writer, format string, r *report) error {
+	header := []string{"Asset", "Invested", "Quantity", "Avg cost", "Price", "Value", "P&L", "P&L %", "XIRR %", "TWR %", "Buys", "Adherence %"}
+
+	rows := [][]string{}
+	for _, row := range r.Coins {
+		rows = append(rows, reportCells(row))
+	}
+	rows = append(rows, reportCells(r.Total))
+
+	switch format {
+	case reportFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case reportFormatTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, strings.Join(header, "\t")+"\t")
+		for _, cells := range rows {
+			fmt.Fprintln(tw, strings.Join(cells, "\t")+"\t")
+		}
+		return tw.Flush()
+	case reportFormatMarkdown:
+		fmt.Fprintf(w, "| %s |\n", strings.Join(header, " | "))
+		fmt.Fprintf(w, "|%s\n", strings.Repeat("---:|", len(header)))
+		for _, cells := range rows {
+			fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unsupported report format %s, use one of table, json, markdown", format)
+}
+
+func reportCells(row reportRow) []string {
+	optional := func(v float64, format string) string {
+		if v == 0 {
+			return "-"
+		}
+		return fmt.Sprintf(format, v)
+	}
+	pct := func(v *float64) string {
+		if v == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%.2f", *v)
+	}
+
+	buys := fmt.Sprintf("%d", row.Actual)
+	if row.Planned > 0 {
+		buys = fmt.Sprintf("%d/%d", row.Actual, row.Planned)
+	}
+
+	return []string{
+		row.Asset,
+		fmt.Sprintf("%.2f", row.Invested),
+		optional(row.Quantity, "%.8f"),
+		optional(row.AverageCost, "%.2f"),
+		optional(row.Price, "%.2f"),
+		fmt.Sprintf("%.2f", row.Value),
+		fmt.Sprintf("%.2f", row.PnL),
+		pct(row.PnLPercent),
+		pct(row.XIRR),
+		pct(row.TWR),
+		buys,
+		pct(row.Adherence),
+	}
+}
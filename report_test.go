@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sberserker/dcagdax/exchanges"
+	"github.com/sberserker/dcagdax/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXirr(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	year := 365 * 24 * time.Hour
+
+	rate, ok := xirr([]cashFlow{{start, -1000}, {start.Add(year), 1100}})
+	assert.True(t, ok)
+	assert.InDelta(t, 0.1, rate, 1e-6)
+
+	rate, ok = xirr([]cashFlow{{start, -1000}, {start.Add(year / 2), -1000}, {start.Add(year), 1800}})
+	assert.True(t, ok)
+	assert.InDelta(t, -0.1317, rate, 1e-3)
+
+	_, ok = xirr([]cashFlow{{start, -1000}, {start.Add(year), 0}})
+	assert.False(t, ok)
+
+	_, ok = xirr([]cashFlow{{start, 0}})
+	assert.False(t, ok)
+}
+
+func TestTimeWeightedReturn(t *testing.T) {
+	jan := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	// price doubles, then halves: the amount invested on the way doesn't matter
+	lots := lotsFromFills("", []exchanges.Fill{
+		testFill("1", exchanges.FillBuy, "BTC", jan, "100", "1", "0"),
+		testFill("2", exchanges.FillBuy, "BTC", feb, "200", "5", "0"),
+	}, time.Time{}, time.Time{}, nil, "USD")
+
+	growth, ok := timeWeightedReturn(lots, map[string]float64{"BTC": 100})
+	assert.True(t, ok)
+	assert.InDelta(t, 0, growth, 1e-9)
+
+	_, ok = timeWeightedReturn(nil, nil)
+	assert.False(t, ok)
+}
+
+func TestBuildReport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	history := mocks.NewMockFillHistory(ctrl)
+	exchange := mocks.NewMockExchange(ctrl)
+
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2023, 1, 8, 12, 0, 0, 0, time.UTC)
+
+	history.EXPECT().GetFills(ctx, "", from, time.Time{}).Return([]exchanges.Fill{
+		testFill("1", exchanges.FillBuy, "BTC", from, "20000", "0.0025", "0.5"),
+		testFill("2", exchanges.FillBuy, "BTC", from.Add(48*time.Hour), "25000", "0.002", "0.5"),
+		testFill("3", exchanges.FillBuy, "ETH", from, "1000", "0.05", "0.5"),
+		testFill("4", exchanges.FillSell, "ETH", from.Add(time.Hour), "1000", "0.02", "0.5"),
+	}, nil)
+	exchange.EXPECT().GetTickerSymbol("BTC", "USD").Return("BTC-USD")
+	exchange.EXPECT().GetTickerSymbol("ETH", "USD").Return("ETH-USD")
+	exchange.EXPECT().GetTicker(ctx, "BTC-USD").Return(&exchanges.Ticker{Price: 30000}, nil)
+	exchange.EXPECT().GetTicker(ctx, "ETH-USD").Return(&exchanges.Ticker{Price: 900}, nil)
+
+	req := reportRequest{currency: "USD", from: from, every: 24 * time.Hour}
+	r, err := buildReport(ctx, history, exchange, req, now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(r.Coins))
+
+	btc := r.Coins[0]
+	assert.Equal(t, "BTC", btc.Asset)
+	assert.InDelta(t, 101, btc.Invested, 1e-9)
+	assert.InDelta(t, 0.0045, btc.Quantity, 1e-12)
+	assert.InDelta(t, 135, btc.Value, 1e-9)
+	assert.InDelta(t, 34, btc.PnL, 1e-9)
+	assert.InDelta(t, 33.66, *btc.PnLPercent, 1e-2)
+	assert.InDelta(t, 22444.44, btc.AverageCost, 1e-2)
+	assert.Equal(t, 8, btc.Planned)
+	assert.Equal(t, 2, btc.Actual)
+	assert.InDelta(t, 25, *btc.Adherence, 1e-9)
+
+	// 0.02 of the ETH purchase were sold, the rest is valued with its share of the cost
+	eth := r.Coins[1]
+	assert.InDelta(t, 30.3, eth.Invested, 1e-9)
+	assert.InDelta(t, 0.03, eth.Quantity, 1e-12)
+	assert.InDelta(t, 27, eth.Value, 1e-9)
+	assert.InDelta(t, 1010, eth.AverageCost, 1e-9)
+	assert.Equal(t, 1, eth.Actual)
+
+	assert.InDelta(t, 131.3, r.Total.Invested, 1e-9)
+	assert.InDelta(t, 162, r.Total.Value, 1e-9)
+	assert.Equal(t, 16, r.Total.Planned)
+	assert.Equal(t, 3, r.Total.Actual)
+	assert.NotNil(t, r.Total.XIRR)
+	assert.NotNil(t, r.Total.TWR)
+
+	t.Run("when written", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.Nil(t, writeReport(&buf, reportFormatMarkdown, r))
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Equal(t, 5, len(lines))
+		assert.Equal(t, "| Asset | Invested | Quantity | Avg cost | Price | Value | P&L | P&L % | XIRR % | TWR % | Buys | Adherence % |", lines[0])
+		assert.True(t, strings.HasPrefix(lines[2], "| BTC | 101.00 | 0.00450000 | 22444.44 | 30000.00 | 135.00 | 34.00 | 33.66 |"))
+		assert.True(t, strings.HasSuffix(lines[4], "| 3/16 | 18.75 |"))
+
+		buf.Reset()
+		assert.Nil(t, writeReport(&buf, reportFormatJSON, r))
+		assert.Contains(t, buf.String(), `"asset": "TOTAL"`)
+
+		buf.Reset()
+		assert.Nil(t, writeReport(&buf, reportFormatTable, r))
+		assert.Equal(t, 4, len(strings.Split(strings.TrimSpace(buf.String()), "\n")))
+
+		assert.EqualError(t, writeReport(&buf, "html", r), "unsupported report format html, use one of table, json, markdown")
+	})
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// runStatus is the overall outcome of a Sync run.
+type runStatus string
+
+const (
+	statusSucceeded runStatus = "succeeded"
+	statusDryRun    runStatus = "dry run"
+	statusPartial   runStatus = "partially failed"
+	statusSkipped   runStatus = "skipped"
+	statusPaused    runStatus = "paused"
+	statusFailed    runStatus = "failed"
+)
+
+// Outcomes of a single order.
+const (
+	orderPlaced   = "placed"
+	orderDryRun   = "dry_run"
+	orderSkipped  = "skipped"  // price band
+	orderRejected = "rejected" // price guard
+	orderFailed   = "failed"
+)
+
+// Reasons of failed orders the scheduler reacts to.
+const (
+	failureInsufficientFunds = "insufficient_funds"
+	failureInvalidProduct    = "invalid_product"
+	failureUnauthorized      = "unauthorized"
+	failureRateLimited       = "rate_limited"
+)
+
+// Process exit codes of a single run so cron and alerting can tell a skipped
+// window from a failure. Invalid configuration exits with exitFailed too.
+const (
+	exitSucceeded = 0
+	exitFailed    = 1
+	exitPartial   = 2
+	exitSkipped   = 3 // also while paused
+)
+
+// Skip reasons, also used as metric labels.
+const (
+	skipPaused           = "paused"
+	skipDeadline         = "deadline"
+	skipNotStarted       = "not_started"
+	skipOutsideHours     = "outside_hours"
+	skipRecentPurchase   = "recent_purchase"
+	skipRejected         = "rejected"
+	skipPendingTransfers = "pending_transfers"
+)
+
+// Sentinel skip errors. Skips with a more specific message match them with
+// errors.Is by reason.
+var (
+	errDeadlinePassed   = skipped(skipDeadline, "Deadline has passed, not taking any action")
+	errNotStarted       = skipped(skipNotStarted, "Configured start date is in the future, not taking any action")
+	errOutsideHours     = skipped(skipOutsideHours, "Outside of allowed hours, not taking any action")
+	errRecentPurchase   = skipped(skipRecentPurchase, "Detected a recent purchase, waiting for next purchase window")
+	errRejected         = skipped(skipRejected, "User rejected the trade")
+	errPendingTransfers = skipped(skipPendingTransfers, "Wait for transfers to settle")
+)
+
+// errInsufficientFunds fails the run, the window is lost until money is
+// deposited.
+var errInsufficientFunds = errors.New("No sufficient amount for trade and autofund is disabled. Deposit money to proceed")
+
+// skipError describes a window skipped for an expected reason rather than a
+// failure.
+type skipError struct {
+	reason string
+	msg    string
+}
+
+func (e *skipError) Error() string {
+	return e.msg
+}
+
+func (e *skipError) Is(target error) bool {
+	t, ok := target.(*skipError)
+	return ok && t.reason == e.reason
+}
+
+func skipped(reason string, msg string) error {
+	return &skipError{reason: reason, msg: msg}
+}
+
+// runResult describes the outcome of a Sync run.
+type runResult struct {
+	Profile  string          `json:"profile,omitempty"`
+	Start    time.Time       `json:"start"`
+	End      time.Time       `json:"end"`
+	Status   runStatus       `json:"status"`
+	Currency string          `json:"currency"`
+	DryRun   bool            `json:"dry_run"`
+	Paused   bool            `json:"paused"`
+	ResumeAt *time.Time      `json:"resume_at,omitempty"`
+	Orders   []orderOutcome  `json:"orders,omitempty"`
+	Deposit  *depositOutcome `json:"deposit,omitempty"`
+	Plan     *runPlan        `json:"plan,omitempty"`    // orders and deposit of a dry run
+	Reason   string          `json:"reason,omitempty"`  // skip reason
+	Message  string          `json:"message,omitempty"` // skip message
+	Error    string          `json:"error,omitempty"`
+
+	// Skip is the skip error of a skipped run, compare it with errors.Is.
+	Skip error `json:"-"`
+}
+
+type orderOutcome struct {
+	Coin      string  `json:"coin"`
+	ProductID string  `json:"product_id"`
+	Amount    float64 `json:"amount"`
+	Status    string  `json:"status"`
+	OrderID   string  `json:"order_id,omitempty"`
+	Reason    string  `json:"reason,omitempty"` // of a failed order
+	Error     string  `json:"error,omitempty"`
+
+	ExpectedFee float64  `json:"expected_fee,omitempty"`
+	Fee         *float64 `json:"fee,omitempty"` // paid according to the fills, nil until filled
+}
+
+type depositOutcome struct {
+	Amount   float64    `json:"amount"`
+	PayoutAt *time.Time `json:"payout_at,omitempty"`
+}
+
+func (r *runResult) addOrder(o orderOutcome) {
+	r.Orders = append(r.Orders, o)
+}
+
+// finish records the error the run stopped with and derives the status.
+func (r *runResult) finish(err error) {
+	r.End = time.Now()
+
+	sort.Slice(r.Orders, func(i, j int) bool {
+		return r.Orders[i].Coin < r.Orders[j].Coin
+	})
+
+	var skip *skipError
+	switch {
+	case err == nil:
+		r.Status = statusSucceeded
+		if r.DryRun {
+			r.Status = statusDryRun
+		}
+
+		failed, done := 0, 0
+		for _, o := range r.Orders {
+			switch o.Status {
+			case orderFailed, orderRejected:
+				failed++
+			case orderPlaced, orderDryRun:
+				done++
+			}
+		}
+		if failed > 0 {
+			r.Status = statusPartial
+			if done == 0 {
+				r.Status = statusFailed
+			}
+		} else if r.Paused {
+			r.Status = statusPaused
+		}
+	case errors.As(err, &skip):
+		r.Status = statusSkipped
+		r.Reason = skip.reason
+		r.Message = err.Error()
+		r.Skip = err
+	default:
+		r.Status = statusFailed
+		r.Error = err.Error()
+	}
+}
+
+// Duration is the time the run took, including deposits and jitter.
+func (r *runResult) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+func (r *runResult) exitCode() int {
+	switch r.Status {
+	case statusFailed:
+		return exitFailed
+	case statusPartial:
+		return exitPartial
+	case statusSkipped, statusPaused:
+		return exitSkipped
+	}
+	return exitSucceeded
+}
+
+// exitCode combines the results of several profiles: a common exit code is
+// kept, otherwise failures make the process partially failed and skips are
+// ignored next to successful runs.
+func exitCode(results []*runResult) int {
+	code := exitSucceeded
+	for i, r := range results {
+		c := r.exitCode()
+		switch {
+		case i == 0 || c == code:
+			code = c
+		case c == exitFailed || c == exitPartial || code == exitFailed || code == exitPartial:
+			return exitPartial
+		default:
+			code = exitSucceeded
+		}
+	}
+	return code
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunResultFinish(t *testing.T) {
+	type test struct {
+		result runResult
+		err    error
+		status runStatus
+		exit   int
+	}
+
+	placed := orderOutcome{Coin: "BTC", Status: orderPlaced, OrderID: "1"}
+	failed := orderOutcome{Coin: "ETH", Status: orderFailed, Error: "boom"}
+	rejected := orderOutcome{Coin: "LTC", Status: orderRejected, Error: "spread"}
+	bandSkipped := orderOutcome{Coin: "LTC", Status: orderSkipped}
+
+	tests := []test{
+		{result: runResult{Orders: []orderOutcome{placed}}, status: statusSucceeded, exit: exitSucceeded},
+		{result: runResult{Orders: []orderOutcome{placed, bandSkipped}}, status: statusSucceeded, exit: exitSucceeded},
+		{result: runResult{DryRun: true, Orders: []orderOutcome{{Coin: "BTC", Status: orderDryRun}}}, status: statusDryRun, exit: exitSucceeded},
+		{result: runResult{Orders: []orderOutcome{placed, failed}}, status: statusPartial, exit: exitPartial},
+		{result: runResult{Orders: []orderOutcome{failed, rejected}}, status: statusFailed, exit: exitFailed},
+		{err: errRecentPurchase, status: statusSkipped, exit: exitSkipped},
+		{err: errInsufficientFunds, status: statusFailed, exit: exitFailed},
+		{err: errors.New("boom"), status: statusFailed, exit: exitFailed},
+	}
+
+	for _, tc := range tests {
+		tc.result.finish(tc.err)
+
+		assert.Equal(t, tc.status, tc.result.Status)
+		assert.Equal(t, tc.exit, tc.result.exitCode())
+	}
+
+	r := runResult{}
+	r.finish(errors.New("boom"))
+	assert.Equal(t, "boom", r.Error)
+	assert.Nil(t, r.Skip)
+
+	r = runResult{}
+	r.finish(fmt.Errorf("%w: allowed hours 09:00-17:00", errOutsideHours))
+	assert.Equal(t, skipOutsideHours, r.Reason)
+	assert.Equal(t, "Outside of allowed hours, not taking any action: allowed hours 09:00-17:00", r.Message)
+	assert.ErrorIs(t, r.Skip, errOutsideHours)
+
+	r = runResult{}
+	r.finish(errInsufficientFunds)
+	assert.Equal(t, statusFailed, r.Status)
+	assert.Equal(t, errInsufficientFunds.Error(), r.Error)
+	assert.Nil(t, r.Skip)
+}
+
+func TestSkipErrors(t *testing.T) {
+	err := fmt.Errorf("run: %w", skipped(skipOutsideHours, "Outside of allowed hours 09:00-17:00, not taking any action"))
+
+	assert.ErrorIs(t, err, errOutsideHours)
+	assert.NotErrorIs(t, err, errNotStarted)
+	assert.NotErrorIs(t, errors.New("Outside of allowed hours"), errOutsideHours)
+}
+
+func TestCombinedExitCode(t *testing.T) {
+	type test struct {
+		statuses []runStatus
+		exit     int
+	}
+
+	tests := []test{
+		{statuses: []runStatus{statusSucceeded}, exit: exitSucceeded},
+		{statuses: []runStatus{statusSkipped, statusPaused}, exit: exitSkipped},
+		{statuses: []runStatus{statusSucceeded, statusSkipped}, exit: exitSucceeded},
+		{statuses: []runStatus{statusSkipped, statusSucceeded, statusDryRun}, exit: exitSucceeded},
+		{statuses: []runStatus{statusFailed, statusFailed}, exit: exitFailed},
+		{statuses: []runStatus{statusSucceeded, statusFailed}, exit: exitPartial},
+		{statuses: []runStatus{statusSkipped, statusFailed}, exit: exitPartial},
+		{statuses: []runStatus{statusPartial, statusSucceeded}, exit: exitPartial},
+	}
+
+	for _, tc := range tests {
+		results := []*runResult{}
+		for _, status := range tc.statuses {
+			results = append(results, &runResult{Status: status})
+		}
+
+		assert.Equal(t, tc.exit, exitCode(results), tc.statuses)
+	}
+}
@@ -14,41 +14,68 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/sberserker/dcagdax/calendar"
 	"github.com/sberserker/dcagdax/exchanges"
 	"github.com/shopspring/decimal"
 )
 
-var skippedForDebug = errors.New("Skipping because trades are not enabled")
+var (
+	skippedForDebug    = errors.New("Skipping because trades are not enabled")
+	skippedWhilePaused = errors.New("Skipping because purchases are paused")
+)
 
 type syncRequest struct {
 	usd         float64
 	orderSpread float64
 	orderType   exchanges.OrderTypeType
 	fee         float64
+	feeOverride bool // --fee was given, the exchange fee tier is not used
 	every       time.Duration
+	schedule    calendar.Schedule
 	until       time.Time
 	after       time.Time
 	autoFund    bool
 	force       bool
 	coins       []string
 	currency    string
+	guard       priceGuard
+	bands       map[string]priceBand
+	carryCap    float64
+	catchUp     catchUpPolicy
+	ledger      string
+	jitter      time.Duration
+	hours       tradingHours
+	notify      []string
+	template    string // notification template file
+	pauseFile   string
 }
 
 type orderDetails struct {
-	symbol string
-	amount float64
+	symbol  string
+	amount  float64
+	windows int // missed windows caught up by the order
 }
 
 type gdaxSchedule struct {
+	profile     string // name of the credential profile, empty without --profiles
 	logger      *zap.SugaredLogger
 	exchange    exchanges.Exchange
 	debug       bool
 	req         syncRequest
 	markerCoin  string // first coin which will be used as a marker if purchase was made recently
 	coins       map[string]orderDetails
-	sleepFunc   func(time.Duration)
+	sleepFunc   func(context.Context, time.Duration) error
+	jitterFunc  func(time.Duration) time.Duration
+	nowFunc     func() time.Time // time.Now when nil
 	confirmFunc func(string) bool
 	ctx         context.Context
+	ledger      *ledger
+	notifier    *notifier
+	pause       pauseSwitch
+	paused      bool     // orders and deposits of the current run are only logged
+	fees        *feeRate // fee rate of the current run once loaded
+	state       runState
+	triggers    chan runTrigger // manual runs requested through the control API
 }
 
 func newGdaxSchedule(
@@ -66,8 +93,29 @@ func newGdaxSchedule(
 		req:         syncRequest,
 		coins:       map[string]orderDetails{},
 		sleepFunc:   sleep,
+		jitterFunc:  randomDelay,
 		confirmFunc: askForConfirmation,
 		ctx:         ctx,
+		ledger:      newLedger(syncRequest.ledger),
+		pause:       pauseSwitch{path: syncRequest.pauseFile},
+	}
+
+	if err := syncRequest.guard.validate(exchange); err != nil {
+		return nil, err
+	}
+
+	notifier, err := newNotifier(syncRequest.notify, syncRequest.template)
+	if err != nil {
+		return nil, err
+	}
+	schedule.notifier = notifier
+
+	if syncRequest.carryCap > 0 && schedule.ledger == nil {
+		return nil, errors.New("--carry-cap requires --ledger")
+	}
+
+	if syncRequest.catchUp.enabled() && (schedule.ledger == nil || syncRequest.after.IsZero()) {
+		return nil, errors.New("--catch-up requires --ledger and --after")
 	}
 
 	total := 0
@@ -121,11 +169,58 @@ func newGdaxSchedule(
 	return &schedule, nil
 }
 
-// Sync initiates trades & funding with a DCA strategy.
-func (s *gdaxSchedule) Sync() error {
+// Sync initiates trades & funding with a DCA strategy. The result is never
+// nil, the error is returned only when the run failed. Cancelling the context
+// the schedule was created with aborts the run.
+func (s *gdaxSchedule) Sync() (*runResult, error) {
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return s.run(ctx, s.req.force, s.confirmFunc)
+}
+
+// run is Sync with the --force flag and its confirmation given explicitly so
+// the control API can force a run confirmed by a token. Cancelling ctx aborts
+// the exchange requests in flight.
+func (s *gdaxSchedule) run(ctx context.Context, force bool, confirm func(string) bool) (*runResult, error) {
+	r := &runResult{Profile: s.profile, Start: s.now(), Currency: s.req.currency, DryRun: s.debug}
+
+	s.fees = nil
+
+	pause := s.checkPause(r.Start)
+	s.paused = pause != nil
+	if pause != nil {
+		r.Paused = true
+		r.ResumeAt = pause.ResumeAt
+	}
+
+	err := s.sync(ctx, r, force, confirm)
+	// the switch may have been turned on during the run
+	r.Paused = s.paused
+	r.finish(err)
+
+	if r.Status == statusSkipped {
+		s.logger.Infow(
+			r.Message,
+			"reason", r.Reason,
+		)
+		err = nil
+	}
+
+	s.logger.Infow(
+		"Run finished",
+		"status", r.Status,
+		"duration", r.Duration().String(),
+	)
 
-	now := time.Now()
-	ctx := context.Background()
+	s.notify(ctx, r)
+
+	return r, err
+}
+
+func (s *gdaxSchedule) sync(ctx context.Context, r *runResult, force bool, confirm func(string) bool) error {
+	now := r.Start
 
 	until := s.req.until
 	if until.IsZero() {
@@ -133,34 +228,62 @@ func (s *gdaxSchedule) Sync() error {
 	}
 
 	if now.After(until) {
-		return errors.New("Deadline has passed, not taking any action")
+		return errDeadlinePassed
 	}
 
 	if !s.req.after.IsZero() && !now.After(s.req.after) {
-		return fmt.Errorf("Configured to start after %s, not taking any action", s.req.after)
+		return fmt.Errorf("%w: starts after %s", errNotStarted, s.req.after)
+	}
+
+	if err := s.checkHours(now); err != nil {
+		return err
 	}
 
 	s.logger.Infow("Dollar cost averaging",
 		s.req.currency, s.req.usd,
-		"every", every,
+		"every", s.cadence(),
 		"until", until.String(),
 	)
 
-	if s.req.force != true {
-		since := now.Add(-*every)
+	if !force {
+		since := s.windowStart(now)
 		if time, err := s.timeToPurchase(ctx, since); err != nil {
 			return err
 		} else if !time {
-			return errors.New("Detected a recent purchase, waiting for next purchase window")
+			return errRecentPurchase
 		}
 	} else {
-		c := s.confirmFunc("Force method is used proceed?")
+		c := confirm("Force method is used proceed?")
 		if !c {
-			return errors.New("User rejected the trade")
+			return errRejected
 		}
 	}
 
-	needed, err := s.additionalUsdNeeded()
+	planned, err := s.planPurchases(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for coin, order := range s.coins {
+		if _, ok := planned[coin]; !ok {
+			r.addOrder(orderOutcome{Coin: coin, ProductID: order.symbol, Amount: order.amount, Status: orderSkipped})
+		}
+	}
+
+	if len(planned) == 0 {
+		s.logger.Infow("Nothing to purchase in this window")
+		return nil
+	}
+
+	s.loadFees(ctx)
+
+	total := decimal.Zero
+	for _, order := range planned {
+		total = total.Add(decimal.NewFromFloat(order.amount))
+	}
+	totalf, _ := total.Float64()
+
+	needed, err := s.additionalUsdNeeded(ctx, totalf)
 	if err != nil {
 		return err
 	}
@@ -168,13 +291,13 @@ func (s *gdaxSchedule) Sync() error {
 	//check if there are pending transfers
 	//typically pending transfers means something is stuck, need to wait to settle or resolve the issue
 	if needed > 0 {
-		pending, err := s.pendingTransfers()
+		pending, err := s.pendingTransfers(ctx)
 		if err != nil {
 			return err
 		}
 
 		if pending > 0 {
-			return errors.New("Wait for transfers to settle")
+			return errPendingTransfers
 		}
 
 		s.logger.Infow(
@@ -183,14 +306,20 @@ func (s *gdaxSchedule) Sync() error {
 		)
 
 		if !s.req.autoFund {
-			return errors.New("No sufficient amount for trade and autofund is disabled. Deposit money to proceed")
+			return errInsufficientFunds
 		}
 
-		_, err = s.fund(ctx, needed)
+		payoutAt, err := s.fund(ctx, needed)
 		if err != nil {
 			return err
 		}
 
+		if s.dryRun() {
+			s.planDeposit(ctx, r, needed)
+		} else {
+			r.Deposit = &depositOutcome{Amount: needed, PayoutAt: payoutAt}
+		}
+
 		//calculate when money will available to buy and sleep
 		//waitTime := payoutAt.Add(1 * time.Minute).Sub(time.Now())
 		waitTime := 1 * time.Minute
@@ -199,7 +328,9 @@ func (s *gdaxSchedule) Sync() error {
 				"Sleeping for",
 				"minutes", waitTime.Minutes(),
 			)
-			s.sleepFunc(waitTime)
+			if err := s.sleepFunc(ctx, waitTime); err != nil {
+				return err
+			}
 		} else {
 			s.logger.Infow(
 				"Deposit money will be available in. Exiting now",
@@ -209,18 +340,79 @@ func (s *gdaxSchedule) Sync() error {
 		}
 	}
 
-	for _, order := range s.coins {
+	// the deposit and the jitter may have waited past the allowed hours
+	if err := s.checkHours(s.now()); err != nil {
+		return err
+	}
+	if err := s.waitJitter(ctx, s.now()); err != nil {
+		return err
+	}
+	if err := s.checkHours(s.now()); err != nil {
+		return err
+	}
+
+	for coin, order := range planned {
+		if err := s.checkPrice(ctx, coin, order.symbol); err != nil {
+			s.logger.Warnw(
+				"Price guard rejected the order",
+				"productId", order.symbol,
+				"error", err,
+			)
+			ordersFailed.Inc(s.profile, coin)
+			r.addOrder(orderOutcome{Coin: coin, ProductID: order.symbol, Amount: order.amount, Status: orderRejected, Error: err.Error()})
+			continue
+		}
+
 		s.logger.Infow(
 			"Placing an order",
 			"productId", order.symbol,
 			"amount", order.amount,
 		)
 
-		if err := s.makePurchase(ctx, order.symbol, order.amount); err != nil {
+		placed, err := s.makePurchase(ctx, order.symbol, order.amount)
+		if err == skippedForDebug || err == skippedWhilePaused {
+			s.logger.Warn(err)
+			s.planOrder(ctx, r, coin, order)
+			r.addOrder(orderOutcome{Coin: coin, ProductID: order.symbol, Amount: order.amount, Status: orderDryRun})
+			continue
+		}
+		if err != nil {
+			reason := s.orderFailed(order.symbol, err)
+			ordersFailed.Inc(s.profile, coin)
+			r.addOrder(orderOutcome{Coin: coin, ProductID: order.symbol, Amount: order.amount, Status: orderFailed, Reason: reason, Error: err.Error()})
+			if reason == failureUnauthorized {
+				// the remaining orders would be rejected too
+				return fmt.Errorf("exchange rejected the API key: %w", err)
+			}
+			continue
+		}
+
+		r.addOrder(orderOutcome{
+			Coin:        coin,
+			ProductID:   order.symbol,
+			Amount:      order.amount,
+			Status:      orderPlaced,
+			OrderID:     placed.OrderID,
+			ExpectedFee: s.expectedFee(order.amount),
+		})
+
+		ordersPlaced.Inc(s.profile, coin)
+		fiatSpent.Add(order.amount, s.profile, coin, s.req.currency)
+
+		if err := s.ledger.record(ledgerEntry{
+			Time:    now,
+			Coin:    coin,
+			Kind:    ledgerPurchase,
+			Amount:  order.amount,
+			OrderID: placed.OrderID,
+			Windows: order.windows + 1,
+		}); err != nil {
 			s.logger.Warn(err)
 		}
 	}
 
+	s.reportFees(ctx, r)
+
 	return nil
 }
 
@@ -230,6 +422,12 @@ func (s *gdaxSchedule) fund(ctx context.Context, needed float64) (*time.Time, er
 		"needed", needed,
 	)
 
+	if s.pausedNow(time.Now()) {
+		s.logger.Infow("Deposit skipped while paused")
+		now := time.Now()
+		return &now, nil
+	}
+
 	if s.debug {
 		s.logger.Infow("Deposit skipped for debug")
 		now := time.Now()
@@ -241,6 +439,9 @@ func (s *gdaxSchedule) fund(ctx context.Context, needed float64) (*time.Time, er
 		return nil, err
 	}
 
+	depositsInitiated.Inc(s.profile, s.req.currency)
+	fiatDeposited.Add(needed, s.profile, s.req.currency)
+
 	return payoutAt, nil
 }
 
@@ -277,7 +478,7 @@ func (s *gdaxSchedule) timeToPurchase(ctx context.Context, since time.Time) (boo
 		"hours", timeSinceLastPurchase.Hours(),
 	)
 
-	if timeSinceLastPurchase.Seconds() < s.req.every.Seconds() {
+	if timeSinceLastPurchase.Seconds() < time.Since(since).Seconds() {
 		// We purchased something recently, so hang tight.
 		return false, nil
 	}
@@ -285,13 +486,13 @@ func (s *gdaxSchedule) timeToPurchase(ctx context.Context, since time.Time) (boo
 	return true, nil
 }
 
-func (s *gdaxSchedule) additionalUsdNeeded() (float64, error) {
-	usdAccount, err := s.exchange.GetFiatAccount(s.ctx, s.req.currency)
+func (s *gdaxSchedule) additionalUsdNeeded(ctx context.Context, amount float64) (float64, error) {
+	usdAccount, err := s.exchange.GetFiatAccount(ctx, s.req.currency)
 	if err != nil {
 		return 0, err
 	}
 
-	if usdAccount.Available >= s.req.usd {
+	if usdAccount.Available >= amount {
 		return 0, nil
 	}
 
@@ -303,14 +504,14 @@ func (s *gdaxSchedule) additionalUsdNeeded() (float64, error) {
 	)
 
 	//account may have some fraction of cents from previous trading so cut everything after 0.01
-	//s.usd - availableBalance
-	dollarsNeeded, _ := decimal.NewFromFloat(s.req.usd).Sub(availableBalance).Truncate(2).Float64()
+	//amount - availableBalance
+	dollarsNeeded, _ := decimal.NewFromFloat(amount).Sub(availableBalance).Truncate(2).Float64()
 
 	return dollarsNeeded, nil
 }
 
-func (s *gdaxSchedule) pendingTransfers() (float64, error) {
-	transfers, err := s.exchange.GetPendingTransfers(s.req.currency)
+func (s *gdaxSchedule) pendingTransfers(ctx context.Context) (float64, error) {
+	transfers, err := s.exchange.GetPendingTransfers(ctx, s.req.currency)
 	if err != nil {
 		return 0, err
 	}
@@ -338,6 +539,17 @@ func (s *gdaxSchedule) timeSinceLastPurchase(ctx context.Context, since time.Tim
 		return nil, err
 	}
 
+	// a window skipped by a price band counts as handled
+	skipped, err := s.ledger.last(s.markerCoin)
+	if err != nil {
+		return nil, err
+	}
+
+	if skipped != nil && skipped.Kind == ledgerSkipped && skipped.Time.After(since) &&
+		(lastPurchaseTime == nil || skipped.Time.After(*lastPurchaseTime)) {
+		lastPurchaseTime = &skipped.Time
+	}
+
 	if lastPurchaseTime == nil {
 		s.logger.Infow(
 			"No transactions found since",
@@ -355,15 +567,19 @@ func (s *gdaxSchedule) timeSinceLastPurchase(ctx context.Context, since time.Tim
 	return &timeSinceLastPurchase, nil
 }
 
-func (s *gdaxSchedule) makePurchase(ctx context.Context, productId string, amount float64) error {
+func (s *gdaxSchedule) makePurchase(ctx context.Context, productId string, amount float64) (*exchanges.Order, error) {
+	if s.pausedNow(time.Now()) {
+		return nil, skippedWhilePaused
+	}
+
 	if s.debug {
-		return skippedForDebug
+		return nil, skippedForDebug
 	}
 
 	order, err := s.exchange.CreateOrder(ctx, productId, amount, s.req.orderType, s.calcLimitOrder)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	s.logger.Infow(
@@ -371,7 +587,28 @@ func (s *gdaxSchedule) makePurchase(ctx context.Context, productId string, amoun
 		"orderId", order.OrderID,
 	)
 
-	return nil
+	return order, nil
+}
+
+// orderFailed logs why an order failed and returns the failure reason, empty
+// for failures the scheduler does not tell apart.
+func (s *gdaxSchedule) orderFailed(productId string, err error) string {
+	switch {
+	case errors.Is(err, exchanges.ErrUnauthorized):
+		s.logger.Errorw("Exchange rejected the API key, check its permissions", "productId", productId, "error", err)
+		return failureUnauthorized
+	case errors.Is(err, exchanges.ErrInsufficientFunds):
+		s.logger.Warnw("Insufficient funds to place the order, deposit money or enable autofund", "productId", productId, "error", err)
+		return failureInsufficientFunds
+	case errors.Is(err, exchanges.ErrInvalidProduct):
+		s.logger.Warnw("Exchange does not trade the product, check the configured coin and currency", "productId", productId, "error", err)
+		return failureInvalidProduct
+	case errors.Is(err, exchanges.ErrRateLimited):
+		s.logger.Warnw("Exchange rate limit exceeded, the order is placed again in the next run", "productId", productId, "error", err)
+		return failureRateLimited
+	}
+	s.logger.Warn(err)
+	return ""
 }
 
 func (s *gdaxSchedule) makeDeposit(ctx context.Context, amount float64) (*time.Time, error) {
@@ -414,8 +651,8 @@ func askForConfirmation(s string) bool {
 func (s *gdaxSchedule) calcLimitOrder(askPrice decimal.Decimal, fiatAmount decimal.Decimal) (orderPrice decimal.Decimal, orderSize decimal.Decimal) {
 
 	//reduce fiat Amount to include fees %
-	//(1-fee)/100 * fiatAmount
-	fiatAmount = decimal.NewFromFloat((100 - s.req.fee) / 100).Mul(fiatAmount)
+	//(1-fee) * fiatAmount
+	fiatAmount = decimal.NewFromInt(1).Sub(s.feeFraction()).Mul(fiatAmount)
 
 	spread := decimal.NewFromFloat(s.req.orderSpread)
 
@@ -436,6 +673,16 @@ func (s *gdaxSchedule) calcLimitOrder(askPrice decimal.Decimal, fiatAmount decim
 	return orderPrice, orderSize
 }
 
-func sleep(waitTime time.Duration) {
-	time.Sleep(waitTime)
+// sleep waits for waitTime or until ctx is done, in which case it returns the
+// error of ctx.
+func sleep(ctx context.Context, waitTime time.Duration) error {
+	timer := time.NewTimer(waitTime)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
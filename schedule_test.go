@@ -2,12 +2,20 @@ package main
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
+	"flag"
 	"fmt"
+	"os"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/sberserker/dcagdax/clients/transport"
 	"github.com/sberserker/dcagdax/exchanges"
 	"github.com/sberserker/dcagdax/mocks"
 	"github.com/shopspring/decimal"
@@ -61,7 +69,7 @@ func TestSyncWhenNotAGoodTime(t *testing.T) {
 	}
 
 	afterTomorrow := time.Now().AddDate(0, 0, 1)
-	afterTomrrowMesage := fmt.Sprintf("Configured to start after %v, not taking any action", afterTomorrow)
+	afterTomrrowMesage := fmt.Sprintf("Configured start date is in the future, not taking any action: starts after %v", afterTomorrow)
 
 	tests := []test{
 		{until: time.Time{}, after: afterTomorrow, err: afterTomrrowMesage, message: "not yet time to run"},
@@ -73,9 +81,12 @@ func TestSyncWhenNotAGoodTime(t *testing.T) {
 		s.logger = loggerStub(t).Sugar()
 		s.req = syncRequest{until: tc.until, after: tc.after}
 
-		err := s.Sync()
+		r, err := s.Sync()
 
-		assert.Equal(t, tc.err, err.Error(), tc.message)
+		assert.Nil(t, err)
+		assert.Equal(t, statusSkipped, r.Status, tc.message)
+		assert.Equal(t, tc.err, r.Skip.Error(), tc.message)
+		assert.Equal(t, tc.err, r.Message, tc.message)
 	}
 }
 
@@ -94,21 +105,25 @@ func TestWhenRecentPurchase(t *testing.T) {
 	t.Run("when recent purchase", func(t *testing.T) {
 		//but last run was 12 hours ago
 		lastPurchaseTime := time.Now().Add(-12 * time.Hour)
-		m.EXPECT().LastPurchaseTime("BTC", "USD", gomock.Any()).Return(&lastPurchaseTime, nil)
+		m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(&lastPurchaseTime, nil)
 
-		err := s.Sync()
+		r, err := s.Sync()
 
-		assert.Equal(t, "Detected a recent purchase, waiting for next purchase window", err.Error())
+		assert.Nil(t, err)
+		assert.Equal(t, "Detected a recent purchase, waiting for next purchase window", r.Skip.Error())
+		assert.Equal(t, exitSkipped, r.exitCode())
 	})
 
 	t.Run("when recent purchase falsed", func(t *testing.T) {
 		//but last run was 12 hours ago
 		lastPurchaseTime := time.Now().Add(-12 * time.Hour)
-		m.EXPECT().LastPurchaseTime("BTC", "USD", gomock.Any()).Return(&lastPurchaseTime, errors.New("some error"))
+		m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(&lastPurchaseTime, errors.New("some error"))
 
-		err := s.Sync()
+		r, err := s.Sync()
 
 		assert.Equal(t, "some error", err.Error())
+		assert.Equal(t, statusFailed, r.Status)
+		assert.Equal(t, exitFailed, r.exitCode())
 	})
 }
 func TestTimeToPurchase(t *testing.T) {
@@ -126,7 +141,7 @@ func TestTimeToPurchase(t *testing.T) {
 
 	t.Run("when recent purchase", func(t *testing.T) {
 		lastPurchaseTime := time.Now().Add(-12 * time.Hour) //last purchase time 12 hrs ago
-		m.EXPECT().LastPurchaseTime("BTC", "USD", gomock.Any()).Return(&lastPurchaseTime, nil)
+		m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(&lastPurchaseTime, nil)
 
 		result, err := s.timeToPurchase(ctx, time.Now().Add(-24*time.Hour))
 
@@ -136,7 +151,7 @@ func TestTimeToPurchase(t *testing.T) {
 
 	t.Run("when no recent purchase", func(t *testing.T) {
 		lastPurchaseTime := time.Now().Add(-48 * time.Hour) //last purchase time 2 days ago
-		m.EXPECT().LastPurchaseTime("BTC", "USD", gomock.Any()).Return(&lastPurchaseTime, nil)
+		m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(&lastPurchaseTime, nil)
 
 		result, err := s.timeToPurchase(ctx, time.Now().Add(-24*time.Hour))
 
@@ -156,12 +171,12 @@ func TestNewSchedule(t *testing.T) {
 		req := syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, autoFund: true, currency: "USD", usd: 50, coins: []string{"BTC:50", "ETH:50"}} // setup run every 24 hrs
 
 		m.EXPECT().GetTickerSymbol("BTC", "USD").Return("BTC:USD")
-		m.EXPECT().GetProduct("BTC:USD").Return(&exchanges.Product{BaseMinSize: 0.001}, nil)
-		m.EXPECT().GetTicker("BTC:USD").Return(&exchanges.Ticker{Price: 1000}, nil)
+		m.EXPECT().GetProduct(ctx, "BTC:USD").Return(&exchanges.Product{BaseMinSize: 0.001}, nil)
+		m.EXPECT().GetTicker(ctx, "BTC:USD").Return(&exchanges.Ticker{Price: 1000}, nil)
 
 		m.EXPECT().GetTickerSymbol("ETH", "USD").Return("ETH:USD")
-		m.EXPECT().GetProduct("ETH:USD").Return(&exchanges.Product{BaseMinSize: 0.5}, nil)
-		m.EXPECT().GetTicker("ETH:USD").Return(&exchanges.Ticker{Price: 10}, nil)
+		m.EXPECT().GetProduct(ctx, "ETH:USD").Return(&exchanges.Product{BaseMinSize: 0.5}, nil)
+		m.EXPECT().GetTicker(ctx, "ETH:USD").Return(&exchanges.Ticker{Price: 10}, nil)
 
 		s, err := newGdaxSchedule(ctx, m, loggerStub(t).Sugar(), false, req)
 
@@ -177,12 +192,12 @@ func TestNewSchedule(t *testing.T) {
 		req := syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, autoFund: true, currency: "USD", usd: 50, coins: []string{"BTC:50", "ETH:49"}} // setup run every 24 hrs
 
 		m.EXPECT().GetTickerSymbol("BTC", "USD").Return("BTC:USD")
-		m.EXPECT().GetProduct("BTC:USD").Return(&exchanges.Product{BaseMinSize: 0.001}, nil)
-		m.EXPECT().GetTicker("BTC:USD").Return(&exchanges.Ticker{Price: 1000}, nil)
+		m.EXPECT().GetProduct(ctx, "BTC:USD").Return(&exchanges.Product{BaseMinSize: 0.001}, nil)
+		m.EXPECT().GetTicker(ctx, "BTC:USD").Return(&exchanges.Ticker{Price: 1000}, nil)
 
 		m.EXPECT().GetTickerSymbol("ETH", "USD").Return("ETH:USD")
-		m.EXPECT().GetProduct("ETH:USD").Return(&exchanges.Product{BaseMinSize: 0.5}, nil)
-		m.EXPECT().GetTicker("ETH:USD").Return(&exchanges.Ticker{Price: 10}, nil)
+		m.EXPECT().GetProduct(ctx, "ETH:USD").Return(&exchanges.Product{BaseMinSize: 0.5}, nil)
+		m.EXPECT().GetTicker(ctx, "ETH:USD").Return(&exchanges.Ticker{Price: 10}, nil)
 
 		s, err := newGdaxSchedule(ctx, m, loggerStub(t).Sugar(), false, req)
 
@@ -201,8 +216,8 @@ func TestNewScheduleWhenBelowMinSize(t *testing.T) {
 	req := syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, autoFund: true, currency: "USD", usd: 50, coins: []string{"BTC:50"}} // setup run every 24 hrs
 
 	m.EXPECT().GetTickerSymbol("BTC", "USD").Return("BTC:USD")
-	m.EXPECT().GetProduct("BTC:USD").Return(&exchanges.Product{BaseMinSize: 0.01}, nil)
-	m.EXPECT().GetTicker("BTC:USD").Return(&exchanges.Ticker{Price: 10000}, nil)
+	m.EXPECT().GetProduct(ctx, "BTC:USD").Return(&exchanges.Product{BaseMinSize: 0.01}, nil)
+	m.EXPECT().GetTicker(ctx, "BTC:USD").Return(&exchanges.Ticker{Price: 10000}, nil)
 
 	s, err := newGdaxSchedule(ctx, m, loggerStub(t).Sugar(), false, req)
 
@@ -223,21 +238,85 @@ func TestSyncWhenSuccessful(t *testing.T) {
 	s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, autoFund: true, currency: "USD", usd: 50} // setup run every 24 hrs
 	s.coins = map[string]orderDetails{"BTC": {symbol: "btcusd", amount: 50}}
 	s.markerCoin = "BTC"
-	s.sleepFunc = func(d time.Duration) {}
+	s.sleepFunc = func(ctx context.Context, d time.Duration) error { return nil }
 	s.exchange = m
 
 	now := time.Now()
 	result := exchanges.Order{OrderID: "1"}
 
-	m.EXPECT().LastPurchaseTime("BTC", "USD", gomock.Any()).Return(nil, nil)
+	m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(nil, nil)
 	m.EXPECT().GetFiatAccount(ctx, "USD").Return(&exchanges.Account{Available: 25}, nil)
-	m.EXPECT().GetPendingTransfers("USD").Return([]exchanges.PendingTransfer{}, nil)
+	m.EXPECT().GetPendingTransfers(ctx, "USD").Return([]exchanges.PendingTransfer{}, nil)
 	m.EXPECT().Deposit(ctx, "USD", 25.0).Return(&now, nil)
 	m.EXPECT().CreateOrder(ctx, "btcusd", 50.0, exchanges.Market, gomock.Any()).Return(&result, nil)
 
-	err := s.Sync()
+	r, err := s.Sync()
 
 	assert.Nil(t, err)
+	assert.Equal(t, statusSucceeded, r.Status)
+	assert.Equal(t, []orderOutcome{{Coin: "BTC", ProductID: "btcusd", Amount: 50, Status: orderPlaced, OrderID: "1"}}, r.Orders)
+	assert.Equal(t, &depositOutcome{Amount: 25, PayoutAt: &now}, r.Deposit)
+	assert.Equal(t, exitSucceeded, r.exitCode())
+}
+
+func TestSyncWhenOrdersFail(t *testing.T) {
+	newSchedule := func(m *mocks.MockExchange, coins map[string]orderDetails) *gdaxSchedule {
+		s := &gdaxSchedule{}
+		s.logger = loggerStub(t).Sugar()
+		s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, currency: "USD", usd: 100}
+		s.coins = coins
+		s.markerCoin = "BTC"
+		s.sleepFunc = func(ctx context.Context, d time.Duration) error { return nil }
+		s.exchange = m
+		return s
+	}
+
+	t.Run("the remaining orders are placed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := context.Background()
+
+		m := mocks.NewMockExchange(ctrl)
+		s := newSchedule(m, map[string]orderDetails{
+			"BTC": {symbol: "btcusd", amount: 50},
+			"ETH": {symbol: "ethusd", amount: 50},
+		})
+
+		insufficient := fmt.Errorf("order: %w", exchanges.ErrInsufficientFunds)
+		m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(nil, nil)
+		m.EXPECT().GetFiatAccount(ctx, "USD").Return(&exchanges.Account{Available: 100}, nil)
+		m.EXPECT().CreateOrder(ctx, "btcusd", 50.0, exchanges.Market, gomock.Any()).Return(nil, insufficient)
+		m.EXPECT().CreateOrder(ctx, "ethusd", 50.0, exchanges.Market, gomock.Any()).Return(&exchanges.Order{OrderID: "1"}, nil)
+
+		r, err := s.Sync()
+
+		assert.Nil(t, err)
+		assert.Equal(t, statusPartial, r.Status)
+		assert.Equal(t, []orderOutcome{
+			{Coin: "BTC", ProductID: "btcusd", Amount: 50, Status: orderFailed, Reason: failureInsufficientFunds, Error: insufficient.Error()},
+			{Coin: "ETH", ProductID: "ethusd", Amount: 50, Status: orderPlaced, OrderID: "1"},
+		}, r.Orders)
+	})
+
+	t.Run("a rejected API key stops the run", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := context.Background()
+
+		m := mocks.NewMockExchange(ctrl)
+		s := newSchedule(m, map[string]orderDetails{"BTC": {symbol: "btcusd", amount: 50}})
+
+		m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(nil, nil)
+		m.EXPECT().GetFiatAccount(ctx, "USD").Return(&exchanges.Account{Available: 100}, nil)
+		m.EXPECT().CreateOrder(ctx, "btcusd", 50.0, exchanges.Market, gomock.Any()).Return(nil, exchanges.ErrUnauthorized)
+
+		r, err := s.Sync()
+
+		assert.True(t, errors.Is(err, exchanges.ErrUnauthorized))
+		assert.Equal(t, statusFailed, r.Status)
+		assert.Equal(t, failureUnauthorized, r.Orders[0].Reason)
+		assert.Equal(t, exitFailed, r.exitCode())
+	})
 }
 
 func TestSyncWhenNotSufficientBalanceAndAutoFundIsOff(t *testing.T) {
@@ -252,17 +331,53 @@ func TestSyncWhenNotSufficientBalanceAndAutoFundIsOff(t *testing.T) {
 	s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, autoFund: false, currency: "USD", usd: 50} // setup run every 24 hrs
 	s.coins = map[string]orderDetails{"BTC": {symbol: "btcusd", amount: 50}}
 	s.markerCoin = "BTC"
-	s.sleepFunc = func(d time.Duration) {}
+	s.sleepFunc = func(ctx context.Context, d time.Duration) error { return nil }
 	s.exchange = m
 
-	m.EXPECT().LastPurchaseTime("BTC", "USD", gomock.Any()).Return(nil, nil)
+	m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(nil, nil)
 	m.EXPECT().GetFiatAccount(ctx, "USD").Return(&exchanges.Account{Available: 25}, nil)
-	m.EXPECT().GetPendingTransfers("USD").Return([]exchanges.PendingTransfer{}, nil)
+	m.EXPECT().GetPendingTransfers(ctx, "USD").Return([]exchanges.PendingTransfer{}, nil)
 
-	err := s.Sync()
+	r, err := s.Sync()
 
-	assert.NotNil(t, err)
-	assert.Equal(t, "No sufficient amount for trade and autofund is disabled. Deposit money to proceed", err.Error())
+	assert.ErrorIs(t, err, errInsufficientFunds)
+	assert.Equal(t, statusFailed, r.Status)
+	assert.Equal(t, exitFailed, r.exitCode())
+	assert.Equal(t, "No sufficient amount for trade and autofund is disabled. Deposit money to proceed", r.Error)
+	assert.Nil(t, r.Skip)
+}
+
+func TestSyncIsCancelledWithItsContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the context of the schedule reaches the exchange and aborts the run
+	ctx, cancel := context.WithCancel(context.Background())
+	m := mocks.NewMockExchange(ctrl)
+
+	s := gdaxSchedule{}
+	s.ctx = ctx
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, autoFund: true, currency: "USD", usd: 50}
+	s.coins = map[string]orderDetails{"BTC": {symbol: "btcusd", amount: 50}}
+	s.markerCoin = "BTC"
+	s.sleepFunc = sleep
+	s.exchange = m
+
+	m.EXPECT().LastPurchaseTime(ctx, "BTC", "USD", gomock.Any()).Return(nil, nil)
+	m.EXPECT().GetFiatAccount(ctx, "USD").Return(&exchanges.Account{Available: 25}, nil)
+	m.EXPECT().GetPendingTransfers(ctx, "USD").Return([]exchanges.PendingTransfer{}, nil)
+	m.EXPECT().Deposit(ctx, "USD", 25.0).DoAndReturn(func(ctx context.Context, currency string, amount float64) (*time.Time, error) {
+		cancel()
+		now := time.Now()
+		return &now, nil
+	})
+
+	r, err := s.Sync()
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, statusFailed, r.Status)
+	assert.Empty(t, r.Orders)
 }
 
 func TestSyncShouldAskForConfirmationWhenForceIsOn(t *testing.T) {
@@ -275,7 +390,7 @@ func TestSyncShouldAskForConfirmationWhenForceIsOn(t *testing.T) {
 	s.logger = loggerStub(t).Sugar()
 	s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, autoFund: false, currency: "USD", usd: 50, force: true} // setup run every 24 hrs
 	s.coins = map[string]orderDetails{"BTC": {symbol: "btcusd", amount: 50}}
-	s.sleepFunc = func(d time.Duration) {}
+	s.sleepFunc = func(ctx context.Context, d time.Duration) error { return nil }
 	s.exchange = m
 
 	t.Run("when rejected", func(t *testing.T) {
@@ -283,10 +398,10 @@ func TestSyncShouldAskForConfirmationWhenForceIsOn(t *testing.T) {
 			return false
 		}
 
-		err := s.Sync()
+		r, err := s.Sync()
 
-		assert.NotNil(t, err)
-		assert.Equal(t, "User rejected the trade", err.Error())
+		assert.Nil(t, err)
+		assert.Equal(t, "User rejected the trade", r.Skip.Error())
 	})
 
 	t.Run("when approved", func(t *testing.T) {
@@ -299,7 +414,7 @@ func TestSyncShouldAskForConfirmationWhenForceIsOn(t *testing.T) {
 		m.EXPECT().GetFiatAccount(ctx, "USD").Return(&exchanges.Account{Available: 50}, nil)
 		m.EXPECT().CreateOrder(ctx, "btcusd", 50.0, exchanges.Market, gomock.Any()).Return(&result, nil)
 
-		err := s.Sync()
+		_, err := s.Sync()
 
 		assert.Nil(t, err)
 	})
@@ -314,18 +429,217 @@ func TestSyncWhenDebugIsOn(t *testing.T) {
 
 	s := gdaxSchedule{}
 	s.logger = loggerStub(t).Sugar()
-	s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, autoFund: true, currency: "USD", usd: 50} // setup run every 24 hrs
+	s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, autoFund: true, currency: "USD", usd: 50, fee: 0.6} // setup run every 24 hrs
 	s.debug = true
 	s.markerCoin = "BTC"
 	s.coins = map[string]orderDetails{"BTC": {symbol: "btcusd", amount: 50}}
-	s.sleepFunc = func(d time.Duration) {}
+	s.sleepFunc = func(ctx context.Context, d time.Duration) error { return nil }
 	s.exchange = m
 
-	m.EXPECT().LastPurchaseTime("BTC", "USD", gomock.Any()).Return(nil, nil)
+	m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(nil, nil)
 	m.EXPECT().GetFiatAccount(ctx, "USD").Return(&exchanges.Account{Available: 25}, nil)
-	m.EXPECT().GetPendingTransfers("USD").Return([]exchanges.PendingTransfer{}, nil)
+	m.EXPECT().GetPendingTransfers(ctx, "USD").Return([]exchanges.PendingTransfer{}, nil)
+
+	m.EXPECT().GetTicker(ctx, "btcusd").Return(&exchanges.Ticker{Price: 20000}, nil)
+
+	r, err := s.Sync()
+
+	assert.Nil(t, err)
+	assert.Equal(t, statusDryRun, r.Status)
+	assert.Nil(t, r.Deposit)
+	assert.Equal(t, orderDryRun, r.Orders[0].Status)
+	assert.Equal(t, &runPlan{
+		FeeRate: 0.006,
+		Orders:  []plannedOrder{{Coin: "BTC", ProductID: "btcusd", Type: "market", Amount: 50, Price: 20000, Size: 0.002485, Fee: 0.3}},
+		Deposit: &plannedDeposit{Amount: 25, Currency: "USD"},
+	}, r.Plan)
+}
+
+func TestSyncWithJitter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	m := mocks.NewMockExchange(ctrl)
+
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, currency: "USD", usd: 50, jitter: 30 * time.Minute}
+	s.coins = map[string]orderDetails{"BTC": {symbol: "btcusd", amount: 50}}
+	s.markerCoin = "BTC"
+	s.exchange = m
+
+	var max, slept time.Duration
+	s.jitterFunc = func(d time.Duration) time.Duration {
+		max = d
+		return d / 2
+	}
+	s.sleepFunc = func(ctx context.Context, d time.Duration) error {
+		slept = d
+		return nil
+	}
 
-	err := s.Sync()
+	result := exchanges.Order{OrderID: "1"}
+
+	m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(nil, nil)
+	m.EXPECT().GetFiatAccount(ctx, "USD").Return(&exchanges.Account{Available: 50}, nil)
+	m.EXPECT().CreateOrder(ctx, "btcusd", 50.0, exchanges.Market, gomock.Any()).Return(&result, nil)
+
+	_, err := s.Sync()
+
+	assert.Nil(t, err)
+	assert.Equal(t, 30*time.Minute, max)
+	assert.Equal(t, 15*time.Minute, slept)
+}
+
+func TestSyncOutsideOfAllowedHours(t *testing.T) {
+	now := time.Now().UTC()
+	spec := fmt.Sprintf("%s-%s UTC", now.Add(time.Hour).Format("15:04"), now.Add(2*time.Hour).Format("15:04"))
+	hours, err := parseHours(spec)
+	assert.Nil(t, err)
+
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, currency: "USD", usd: 50, hours: hours}
+	s.markerCoin = "BTC"
+
+	r, err := s.Sync()
+
+	assert.Nil(t, err)
+	assert.ErrorIs(t, r.Skip, errOutsideHours)
+	assert.EqualError(t, r.Skip, "Outside of allowed hours, not taking any action: allowed hours "+spec)
+}
+
+var recordFixtures = flag.Bool("record", false, "record the fixtures in testdata against the live exchange with the credentials of the environment, this places real orders")
+
+// fixtureRecorder replays the fixture at path, or records it with -record. The
+// time windows of the queries move with every run and are not compared.
+func fixtureRecorder(t *testing.T, path string, secrets ...string) *transport.Recorder {
+	mode := transport.Replay
+	if *recordFixtures {
+		mode = transport.Record
+	}
+
+	recorder, err := transport.NewRecorder(path, transport.RecorderConfig{
+		Mode:         mode,
+		Secrets:      secrets,
+		IgnoreParams: []string{"start_date", "start_sequence_timestamp"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err := recorder.Save(); err != nil {
+			t.Error(err)
+		}
+		assert.Empty(t, recorder.Unused(), "every recorded request is replayed")
+	})
+	return recorder
+}
+
+func TestSyncReplay(t *testing.T) {
+	if !*recordFixtures {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.Nil(t, err)
+		der, err := x509.MarshalECPrivateKey(key)
+		assert.Nil(t, err)
+
+		t.Setenv("COINBASE_KEY", "organizations/test/apiKeys/test")
+		t.Setenv("COINBASE_SECRET", string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})))
+		t.Setenv("PORTFOLIO_ID", "test-portfolio")
+	}
+	recorder := fixtureRecorder(t, "testdata/coinbase_sync.json", os.Getenv("COINBASE_KEY"), os.Getenv("PORTFOLIO_ID"))
 
+	exchange, err := exchanges.NewCoinbaseV3()
 	assert.Nil(t, err)
+	exchange.SetTransport(recorder)
+
+	req := syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, currency: "USD", usd: 20, fee: 0.6, coins: []string{"BTC:100"}}
+	s, err := newGdaxSchedule(context.Background(), exchange, loggerStub(t).Sugar(), false, req)
+	assert.Nil(t, err)
+	s.sleepFunc = func(ctx context.Context, d time.Duration) error { return nil }
+
+	r, err := s.Sync()
+
+	assert.Nil(t, err)
+	if *recordFixtures {
+		return
+	}
+	assert.Equal(t, statusSucceeded, r.Status)
+	if assert.Len(t, r.Orders, 1) {
+		o := r.Orders[0]
+		assert.Equal(t, orderPlaced, o.Status)
+		assert.Equal(t, "BTC-USD", o.ProductID)
+		assert.Equal(t, 20.0, o.Amount)
+		assert.Equal(t, "0b8cf6a3-1d2e-4f5a-9b6c-7d8e9f0a1b2c", o.OrderID)
+		assert.Equal(t, 0.12, o.ExpectedFee)
+		if assert.NotNil(t, o.Fee, "the fee is read from the fills") {
+			assert.InDelta(t, 0.12, *o.Fee, 0.01)
+		}
+	}
+}
+
+func TestSyncLeavesAllowedHoursWhileWaiting(t *testing.T) {
+	hours, err := parseHours("14:00-15:00 UTC")
+	assert.Nil(t, err)
+
+	newSchedule := func(m *mocks.MockExchange, autoFund bool) *gdaxSchedule {
+		clock := time.Date(2024, 3, 6, 14, 50, 0, 0, time.UTC)
+
+		s := gdaxSchedule{}
+		s.logger = loggerStub(t).Sugar()
+		s.req = syncRequest{every: 24 * time.Hour, orderType: exchanges.Market, autoFund: autoFund, currency: "USD", usd: 50, hours: hours, jitter: time.Hour}
+		s.coins = map[string]orderDetails{"BTC": {symbol: "btcusd", amount: 50}}
+		s.markerCoin = "BTC"
+		s.exchange = m
+		s.nowFunc = func() time.Time { return clock }
+		s.jitterFunc = func(d time.Duration) time.Duration { return d }
+		s.sleepFunc = func(ctx context.Context, d time.Duration) error {
+			clock = clock.Add(d)
+			return nil
+		}
+		return &s
+	}
+
+	t.Run("when the jitter ends outside", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		m := mocks.NewMockExchange(ctrl)
+
+		m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(nil, nil)
+		m.EXPECT().GetFiatAccount(gomock.Any(), "USD").Return(&exchanges.Account{Available: 50}, nil)
+
+		r, err := newSchedule(m, false).Sync()
+
+		assert.Nil(t, err)
+		assert.ErrorIs(t, r.Skip, errOutsideHours)
+		assert.Empty(t, r.Orders)
+	})
+
+	t.Run("when the deposit wait ends outside", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		m := mocks.NewMockExchange(ctrl)
+
+		s := newSchedule(m, true)
+		s.nowFunc = func() time.Time { return time.Date(2024, 3, 6, 14, 59, 30, 0, time.UTC) }
+		s.sleepFunc = func(ctx context.Context, d time.Duration) error {
+			s.nowFunc = func() time.Time { return time.Date(2024, 3, 6, 15, 0, 30, 0, time.UTC) }
+			return nil
+		}
+
+		payoutAt := time.Now()
+		m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", gomock.Any()).Return(nil, nil)
+		m.EXPECT().GetFiatAccount(gomock.Any(), "USD").Return(&exchanges.Account{Available: 25}, nil)
+		m.EXPECT().GetPendingTransfers(gomock.Any(), "USD").Return([]exchanges.PendingTransfer{}, nil)
+		m.EXPECT().Deposit(gomock.Any(), "USD", 25.0).Return(&payoutAt, nil)
+
+		r, err := s.Sync()
+
+		assert.Nil(t, err)
+		assert.ErrorIs(t, r.Skip, errOutsideHours)
+		assert.Equal(t, &depositOutcome{Amount: 25, PayoutAt: &payoutAt}, r.Deposit)
+		assert.Empty(t, r.Orders)
+	})
 }
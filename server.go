@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sberserker/dcagdax/metrics"
+)
+
+// newStatusServer serves Prometheus metrics and health checks for
+// container orchestration. The daemon is ready once its first run finished.
+// The control API is served under /api/ when given.
+func newStatusServer(addr string, api http.Handler) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Default.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if runsAttempted.Value() == 0 {
+			http.Error(w, "waiting for the first run", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+
+	if api != nil {
+		mux.Handle("/api/", api)
+	}
+
+	return &http.Server{Addr: addr, Handler: mux}
+}
+
+// serveStatus runs the status server until ctx is cancelled.
+func serveStatus(ctx context.Context, addr string, api http.Handler, logger *zap.SugaredLogger) {
+	srv := newStatusServer(addr, api)
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	logger.Infow(
+		"Serving metrics",
+		"address", addr,
+	)
+
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Error(err)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordRun(t *testing.T) {
+	attempted := runsAttempted.Value()
+	succeeded := runsSucceeded.Value()
+	skippedTransfers := runsSkipped.Value(skipPendingTransfers)
+	failed := runsFailed.Value()
+
+	recordRun(&runResult{Status: statusSucceeded})
+	recordRun(&runResult{Status: statusPartial})
+	recordRun(&runResult{Status: statusSkipped, Reason: skipPendingTransfers})
+	recordRun(&runResult{Status: statusFailed})
+
+	assert.Equal(t, attempted+4, runsAttempted.Value())
+	assert.Equal(t, succeeded+2, runsSucceeded.Value())
+	assert.Equal(t, skippedTransfers+1, runsSkipped.Value(skipPendingTransfers))
+	assert.Equal(t, failed+1, runsFailed.Value())
+}
+
+func TestStatusServer(t *testing.T) {
+	srv := newStatusServer(":0", nil)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	assert.Equal(t, 200, get("/healthz").Code)
+
+	recordRun(&runResult{Status: statusSucceeded})
+
+	assert.Equal(t, 200, get("/readyz").Code)
+
+	metrics := get("/metrics")
+	assert.Equal(t, 200, metrics.Code)
+	assert.Contains(t, metrics.Body.String(), "# TYPE dcagdax_runs_total counter")
+	assert.Contains(t, metrics.Body.String(), "# TYPE dcagdax_next_run_seconds gauge")
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"time"
+
+	"github.com/sberserker/dcagdax/calendar"
+)
+
+// daemonPollInterval is how often the daemon checks for a purchase window
+// when purchases are relative to the last one (--every).
+const daemonPollInterval = time.Hour
+
+// windowStart returns the start of the purchase window containing now. With
+// --every the window is relative to now, with --schedule it opens at the last
+// calendar activation.
+func (s *gdaxSchedule) windowStart(now time.Time) time.Time {
+	if s.req.schedule != nil {
+		return s.req.schedule.Prev(now)
+	}
+	return now.Add(-s.req.every)
+}
+
+// nextRun returns when the daemon should run next. A zero time means the
+// schedule has no further activations.
+func (s *gdaxSchedule) nextRun(now time.Time) time.Time {
+	if s.req.schedule != nil {
+		return s.req.schedule.Next(now)
+	}
+	return now.Add(daemonPollInterval)
+}
+
+// plannedWindowsUntil returns the number of purchase windows from --after up
+// to and including now.
+func (s *gdaxSchedule) plannedWindowsUntil(now time.Time) int {
+	return countWindows(s.req.every, s.req.schedule, s.req.after, now)
+}
+
+// countWindows returns the number of purchase windows from start up to and
+// including now of either a calendar schedule or a fixed interval.
+func countWindows(every time.Duration, schedule calendar.Schedule, start time.Time, now time.Time) int {
+	if schedule != nil {
+		if now.Before(start) {
+			return 0
+		}
+		// activations exactly at start belong to the plan
+		return calendar.Count(schedule, start.Add(-time.Nanosecond), now)
+	}
+	return plannedWindows(start, now, every)
+}
+
+func (s *gdaxSchedule) cadence() string {
+	if s.req.schedule != nil {
+		return s.req.schedule.String()
+	}
+	return s.req.every.String()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sberserker/dcagdax/calendar"
+	"github.com/sberserker/dcagdax/exchanges"
+	"github.com/sberserker/dcagdax/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustParseSchedule(t *testing.T, spec string) calendar.Schedule {
+	s, err := calendar.Parse(spec)
+	if err != nil {
+		t.Fatalf("calendar.Parse: %v", err)
+	}
+	return s
+}
+
+func TestWindowStart(t *testing.T) {
+	now := time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC) // wednesday
+
+	s := gdaxSchedule{}
+	s.req = syncRequest{every: 24 * time.Hour}
+
+	assert.Equal(t, now.Add(-24*time.Hour), s.windowStart(now))
+	assert.Equal(t, now.Add(daemonPollInterval), s.nextRun(now))
+	assert.Equal(t, "24h0m0s", s.cadence())
+
+	s.req = syncRequest{schedule: mustParseSchedule(t, "every friday 14:00 UTC")}
+
+	assert.Equal(t, time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC), s.windowStart(now))
+	assert.Equal(t, time.Date(2024, 3, 8, 14, 0, 0, 0, time.UTC), s.nextRun(now))
+	assert.Equal(t, "every friday 14:00 UTC", s.cadence())
+}
+
+func TestPlannedWindowsUntil(t *testing.T) {
+	after := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	s := gdaxSchedule{}
+	s.req = syncRequest{after: after, schedule: mustParseSchedule(t, "every 1st and 15th 00:00 UTC")}
+
+	assert.Equal(t, 0, s.plannedWindowsUntil(after.Add(-time.Hour)))
+	assert.Equal(t, 1, s.plannedWindowsUntil(after))
+	assert.Equal(t, 3, s.plannedWindowsUntil(time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestSyncWithCalendarSchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockExchange(ctrl)
+
+	s := gdaxSchedule{}
+	s.logger = loggerStub(t).Sugar()
+	s.req = syncRequest{schedule: mustParseSchedule(t, "@daily"), orderType: exchanges.Market, currency: "USD", usd: 50}
+	s.markerCoin = "BTC"
+	s.exchange = m
+
+	since := s.windowStart(time.Now())
+	lastPurchaseTime := since.Add(time.Second)
+	m.EXPECT().LastPurchaseTime(gomock.Any(), "BTC", "USD", since).Return(&lastPurchaseTime, nil)
+
+	r, err := s.Sync()
+
+	assert.Nil(t, err)
+	assert.ErrorIs(t, r.Skip, errRecentPurchase)
+}
+
+func TestRunDaemon(t *testing.T) {
+	t.Run("when deadline has passed", func(t *testing.T) {
+		s := gdaxSchedule{}
+		s.logger = loggerStub(t).Sugar()
+		s.req = syncRequest{every: time.Hour, until: time.Now().Add(-time.Hour)}
+
+		runDaemon(context.Background(), &s)
+	})
+
+	t.Run("when cancelled", func(t *testing.T) {
+		s := gdaxSchedule{}
+		s.logger = loggerStub(t).Sugar()
+		s.req = syncRequest{every: time.Hour, after: time.Now().Add(time.Hour)}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		runDaemon(ctx, &s)
+	})
+}